@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/goten4/ucerts/pkg/tls"
+)
+
+func newCACmd() *cobra.Command {
+	caCmd := &cobra.Command{
+		Use:   "ca",
+		Short: "manage certificate authorities",
+	}
+
+	bundleCmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "write the public keys of one or more issuers to a single trust bundle and exit",
+		Run:   caBundle,
+	}
+	bundleCmd.Flags().StringSlice("issuer", nil, "issuer directory containing the public key, may be repeated")
+	bundleCmd.Flags().String("public-key", "ca.crt", "public key file name in the issuer directories")
+	bundleCmd.Flags().String("out", "", "trust bundle file")
+	_ = bundleCmd.MarkFlagRequired("issuer")
+	_ = bundleCmd.MarkFlagRequired("out")
+
+	caCmd.AddCommand(bundleCmd)
+	return caCmd
+}
+
+func caBundle(cmd *cobra.Command, _ []string) {
+	issuers, _ := cmd.Flags().GetStringSlice("issuer")
+	publicKey, _ := cmd.Flags().GetString("public-key")
+	out, _ := cmd.Flags().GetString("out")
+
+	files := make([]string, 0, len(issuers))
+	for _, issuer := range issuers {
+		files = append(files, filepath.Join(issuer, publicKey))
+	}
+	certs, err := tls.LoadTrustedCertificates(files)
+	if err != nil {
+		logrus.Fatalf("Failed to load issuers: %v", err)
+	}
+	if err := tls.WriteTrustBundle(certs, out); err != nil {
+		logrus.Fatalf("Failed to write trust bundle: %v", err)
+	}
+	logrus.Infof("Write %d certificate(s) to %s", len(certs), out)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/goten4/ucerts/pkg/tls"
+)
+
+func newConvertCmd() *cobra.Command {
+	convertCmd := &cobra.Command{
+		Use:   "convert",
+		Short: "convert a certificate request file to another format and exit",
+		Args:  cobra.NoArgs,
+		Run:   convert,
+	}
+	convertCmd.Flags().String("in", "", "certificate request file to convert")
+	convertCmd.Flags().String("out", "", "converted file, its extension gives the format (yaml, json, toml...)")
+	_ = convertCmd.MarkFlagRequired("in")
+	_ = convertCmd.MarkFlagRequired("out")
+	return convertCmd
+}
+
+func convert(cmd *cobra.Command, _ []string) {
+	in, _ := cmd.Flags().GetString("in")
+	out, _ := cmd.Flags().GetString("out")
+
+	if err := tls.ConvertCertificateRequest(in, out); err != nil {
+		logrus.Fatalf("Failed to convert certificate request %s: %v", in, err)
+	}
+	logrus.Infof("Certificate request %s converted to %s", in, out)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/goten4/ucerts/pkg/tls"
+)
+
+func newDevCertsCmd() *cobra.Command {
+	devCertsCmd := &cobra.Command{
+		Use:   "dev-certs",
+		Short: "generate a throwaway CA and a server certificate signed by it for local TLS and exit",
+		Args:  cobra.NoArgs,
+		Run:   devCerts,
+	}
+	devCertsCmd.Flags().String("dir", "", "output directory")
+	devCertsCmd.Flags().StringSlice("dns", []string{"localhost"}, "DNS name of the server certificate, may be repeated")
+	devCertsCmd.Flags().StringSlice("ip", []string{"127.0.0.1"}, "IP address of the server certificate, may be repeated")
+	_ = devCertsCmd.MarkFlagRequired("dir")
+	return devCertsCmd
+}
+
+func devCerts(cmd *cobra.Command, _ []string) {
+	dir, _ := cmd.Flags().GetString("dir")
+	dnsNames, _ := cmd.Flags().GetStringSlice("dns")
+	ipAddresses, _ := cmd.Flags().GetStringSlice("ip")
+
+	if err := tls.GenerateDevCerts(dir, dnsNames, ipAddresses); err != nil {
+		logrus.Fatalf("Failed to generate development certificates: %v", err)
+	}
+	logrus.Infof("Development certificates written to %s", dir)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/goten4/ucerts/pkg/tls"
+)
+
+func newGenerateCmd() *cobra.Command {
+	generateCmd := &cobra.Command{
+		Use:   "generate <certificate request file>",
+		Short: "generate the files of a single certificate request and exit",
+		Args:  cobra.ExactArgs(1),
+		// Keep stdout for the PEM output
+		PersistentPreRun: func(_ *cobra.Command, _ []string) { initialize(os.Stderr) },
+		Run:              generate,
+	}
+	generateCmd.Flags().Bool("stdout", false, "write the certificate to stdout instead of the out files")
+	generateCmd.Flags().Bool("with-key", false, "also write the private key to stdout")
+	return generateCmd
+}
+
+func generate(cmd *cobra.Command, args []string) {
+	toStdout, _ := cmd.Flags().GetBool("stdout")
+	withKey, _ := cmd.Flags().GetBool("with-key")
+	if withKey && !toStdout {
+		logrus.Fatal("--with-key requires --stdout")
+	}
+
+	req, err := tls.LoadCertificateRequest(args[0])
+	if err != nil {
+		logrus.Fatalf("Failed to load certificate request: %v", err)
+	}
+	issuer, err := tls.LoadIssuer(req.IssuerPath)
+	if err != nil {
+		logrus.Fatalf("Invalid issuer: %v", err)
+	}
+
+	if toStdout {
+		if err := tls.GenerateToWriter(req, issuer, cmd.OutOrStdout(), withKey); err != nil {
+			logrus.Fatalf("Failed to generate certificate: %v", err)
+		}
+		return
+	}
+
+	if err := tls.GenerateFiles(req, issuer); err != nil {
+		logrus.Fatalf("Failed to generate certificate: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/pkg/tls"
+)
+
+func newPlanCmd() *cobra.Command {
+	planCmd := &cobra.Command{
+		Use:   "plan",
+		Short: "print the actions that would be taken for each certificate request and exit (exit code 2 if changes are pending)",
+		// Keep stdout for the plan itself
+		PersistentPreRun: func(_ *cobra.Command, _ []string) { initialize(os.Stderr) },
+		Run:              plan,
+	}
+	planCmd.Flags().Bool("json", false, "print the plan as JSON")
+	return planCmd
+}
+
+func plan(cmd *cobra.Command, _ []string) {
+	plans := tls.PlanCertificateRequests(config.CertificateRequestsPaths, config.CertificateRequestsInline)
+
+	var err error
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		err = printPlansJSON(cmd.OutOrStdout(), plans)
+	} else {
+		err = printPlansTable(cmd.OutOrStdout(), plans)
+	}
+	if err != nil {
+		logrus.Fatalf("Failed to print plan: %v", err)
+	}
+
+	os.Exit(tls.PlanExitCode(plans))
+}
+
+func printPlansJSON(w io.Writer, plans []tls.Plan) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plans)
+}
+
+func printPlansTable(w io.Writer, plans []tls.Plan) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "REQUEST\tCERTIFICATE\tACTION\tREASON")
+	for _, p := range plans {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Request, p.OutCertPath, p.Action, p.Reason)
+	}
+	return tw.Flush()
+}
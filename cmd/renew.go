@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/goten4/ucerts/pkg/tls"
+)
+
+func newRenewCmd() *cobra.Command {
+	renewCmd := &cobra.Command{
+		Use:   "renew",
+		Short: "regenerate the certificate written to the given path by one of the certificate requests and exit",
+		Args:  cobra.NoArgs,
+		Run:   renew,
+	}
+	renewCmd.Flags().String("path", "", "certificate file (out.cert) of the certificate request to renew")
+	_ = renewCmd.MarkFlagRequired("path")
+	return renewCmd
+}
+
+func renew(cmd *cobra.Command, _ []string) {
+	path, _ := cmd.Flags().GetString("path")
+
+	if err := tls.RenewCertificate(path); err != nil {
+		logrus.Fatalf("Failed to renew certificate %s: %v", path, err)
+	}
+	logrus.Infof("Certificate %s renewed", path)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/sirupsen/logrus"
@@ -16,19 +17,16 @@ import (
 )
 
 func Execute() {
-	cobra.OnInitialize(func() {
-		logrus.RegisterExitHandler(daemon.GracefulStop)
-		logrus.SetOutput(os.Stdout)
-		config.Init()
-	})
-
 	rootCmd := &cobra.Command{
-		Use: build.Name,
-		Run: run,
+		Use:              build.Name,
+		PersistentPreRun: func(_ *cobra.Command, _ []string) { initialize(os.Stdout) },
+		Run:              run,
 	}
 
 	rootCmd.PersistentFlags().StringP("config", "c", "", "provides the configuration file")
 	_ = viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	rootCmd.Flags().Bool("once", false, "handle all certificate requests once and exit (non-zero exit code on failure)")
+	_ = viper.BindPFlag(config.KeyOneShot, rootCmd.Flags().Lookup("once"))
 
 	versionCmd := &cobra.Command{
 		Use:   "version",
@@ -37,12 +35,25 @@ func Execute() {
 	}
 
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(newStatusCmd())
+	rootCmd.AddCommand(newPlanCmd())
+	rootCmd.AddCommand(newCACmd())
+	rootCmd.AddCommand(newGenerateCmd())
+	rootCmd.AddCommand(newConvertCmd())
+	rootCmd.AddCommand(newRenewCmd())
+	rootCmd.AddCommand(newDevCertsCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		logrus.Fatal(err.Error())
 	}
 }
 
+func initialize(out io.Writer) {
+	logrus.RegisterExitHandler(daemon.GracefulStop)
+	logrus.SetOutput(out)
+	config.Init()
+}
+
 func version(_ *cobra.Command, _ []string) {
 	_, _ = fmt.Fprintf(os.Stdout, "Version: %s\n", build.Version)
 	_, _ = fmt.Fprintf(os.Stdout, "Date: %s\n", build.BuiltAt)
@@ -50,10 +61,25 @@ func version(_ *cobra.Command, _ []string) {
 }
 
 func run(_ *cobra.Command, _ []string) {
+	if err := tls.ValidateOnStart(); err != nil {
+		logrus.Fatalf("Refuse to start: %v", err)
+	}
+	if err := tls.CheckWritableOnStart(); err != nil {
+		logrus.Fatalf("Refuse to start: %v", err)
+	}
+
+	if config.OneShot {
+		if err := tls.RunOnce(); err != nil {
+			logrus.Fatalf("Failed to handle certificate requests: %v", err)
+		}
+		return
+	}
+
 	defer daemon.GracefulStop()
 
 	daemon.PushGracefulStop(tls.Start())
 	daemon.PushGracefulStop(watcher.Start())
+	daemon.SetStatusDumper(func() { tls.LogCertificateStatuses(config.CertificateRequestsPaths, config.CertificateRequestsInline) })
 
 	daemon.WaitForStop()
 }
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/pkg/tls"
+)
+
+func newStatusCmd() *cobra.Command {
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "print the status of managed certificates and exit",
+		// Keep stdout for the status itself
+		PersistentPreRun: func(_ *cobra.Command, _ []string) { initialize(os.Stderr) },
+		Run:              status,
+	}
+	statusCmd.Flags().Bool("json", false, "print the status as JSON")
+	return statusCmd
+}
+
+func status(cmd *cobra.Command, _ []string) {
+	statuses := tls.GetCertificateStatuses(config.CertificateRequestsPaths, config.CertificateRequestsInline)
+
+	var err error
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		err = printStatusesJSON(cmd.OutOrStdout(), statuses)
+	} else {
+		err = printStatusesTable(cmd.OutOrStdout(), statuses)
+	}
+	if err != nil {
+		logrus.Fatalf("Failed to print status: %v", err)
+	}
+}
+
+func printStatusesJSON(w io.Writer, statuses []tls.CertificateStatus) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(statuses)
+}
+
+func printStatusesTable(w io.Writer, statuses []tls.CertificateStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "REQUEST\tCERTIFICATE\tEXISTS\tSELF-SIGNED\tNOT AFTER\tRENEW IN\tERROR")
+	for _, s := range statuses {
+		selfSigned, notAfter, renewIn := "-", "-", "-"
+		if s.NotAfter != nil {
+			selfSigned = strconv.FormatBool(s.SelfSigned)
+			notAfter = s.NotAfter.Format(time.DateTime)
+			renewIn = s.RenewIn.String()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\t%s\t%s\n", s.Request, s.OutCertPath, s.Exists, selfSigned, notAfter, renewIn, s.Error)
+	}
+	return tw.Flush()
+}
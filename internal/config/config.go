@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
@@ -10,16 +11,31 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+
+	"github.com/goten4/ucerts/internal/cron"
+	"github.com/goten4/ucerts/internal/format"
+	"github.com/goten4/ucerts/internal/logfile"
 )
 
 const (
 	KeyShutdownTimeout            = "shutdown_timeout"
 	KeyInterval                   = "interval"
+	KeyIntervalJitter             = "interval_jitter"
+	KeySchedule                   = "schedule"
+	KeyOneShot                    = "oneShot"
 	KeyLogLevel                   = "log.level"
 	KeyLogFormat                  = "log.format"
 	KeyLogTimestampEnable         = "log.timestamp.enable"
 	KeyLogTimestampFormat         = "log.timestamp.format"
+	KeyLogFile                    = "log.file"
+	KeyLogRotationMaxSize         = "log.rotation.maxSize"
+	KeyLogRotationMaxAge          = "log.rotation.maxAge"
+	KeyLogRotationMaxBackups      = "log.rotation.maxBackups"
 	KeyCertificateRequestsPaths   = "certificateRequests.paths"
+	KeyCertificateRequestsInline  = "certificateRequests.inline"
+	KeyCertificateRequestsPattern = "certificateRequests.filePattern"
+	KeyCertificateRequestsIgnore  = "certificateRequests.ignore"
+	KeyDefaultFrom                = "default.from"
 	KeyDefaultCountries           = "default.countries"
 	KeyDefaultOrganizations       = "default.organizations"
 	KeyDefaultOrganizationalUnits = "default.organizationalUnits"
@@ -27,12 +43,41 @@ const (
 	KeyDefaultProvinces           = "default.provinces"
 	KeyDefaultStreetAddresses     = "default.streetAddresses"
 	KeyDefaultPostalCodes         = "default.postalCodes"
+	KeyDefaultExtKeyUsages        = "default.extKeyUsages"
+	KeyPolicyMinRSASize           = "policy.minRSASize"
+	KeyPolicyAllowedECDSACurves   = "policy.allowedECDSACurves"
+	KeyPolicyAllowedAlgorithms    = "policy.allowedAlgorithms"
+	KeyPolicyMaxDuration          = "policy.maxDuration"
+	KeyPolicyMaxDurationMode      = "policy.maxDurationMode"
+	KeyLegacySHA1Fingerprint      = "legacySHA1Fingerprint"
+	KeyAggregateValidationErrors  = "aggregateValidationErrors"
+	KeyValidateOnStart            = "validateOnStart"
+	KeyCheckWritableOnStart       = "checkWritableOnStart"
+)
+
+const (
+	ValidateOnStartFatal = "fatal"
+	ValidateOnStartWarn  = "warn"
+)
+
+const (
+	MaxDurationReject = "reject"
+	MaxDurationClamp  = "clamp"
 )
 
+// MinInterval prevents a misconfigured interval from handling the requests in a loop.
+const MinInterval = time.Second
+
 var (
 	ShutdownTimeout            time.Duration
 	Interval                   time.Duration
+	IntervalJitter             time.Duration
+	Schedule                   *cron.Schedule
+	OneShot                    bool
 	CertificateRequestsPaths   []string
+	CertificateRequestsInline  []map[string]any
+	CertificateRequestsPattern string
+	CertificateRequestsIgnore  []string
 	DefaultCountries           []string
 	DefaultOrganizations       []string
 	DefaultOrganizationalUnits []string
@@ -40,18 +85,62 @@ var (
 	DefaultProvinces           []string
 	DefaultStreetAddresses     []string
 	DefaultPostalCodes         []string
+	DefaultExtKeyUsages        []string
+	PolicyMinRSASize           int
+	PolicyAllowedECDSACurves   []string
+	PolicyAllowedAlgorithms    []string
+	PolicyMaxDuration          time.Duration
+	PolicyMaxDurationMode      string
+	LegacySHA1Fingerprint      bool
+	AggregateValidationErrors  bool
+	ValidateOnStart            string
+	CheckWritableOnStart       bool
 
-	ErrInvalidExtension = errors.New("invalid extension")
+	ErrInvalidExtension       = errors.New("invalid extension")
+	ErrInvalidLogLevel        = errors.New("invalid log level")
+	ErrInvalidIntervalJitter  = errors.New("invalid interval jitter")
+	ErrInvalidSchedule        = errors.New("invalid schedule")
+	ErrInvalidObjectList      = errors.New("invalid object list")
+	ErrInvalidFilePattern     = errors.New("invalid file pattern")
+	ErrInvalidValidateOnStart = errors.New("invalid validate on start mode")
+	ErrInvalidMaxDuration     = errors.New("invalid max duration")
+	ErrReadDefaultsFile       = errors.New("read defaults file")
 )
 
-func Init() {
-	viper.SetDefault(KeyShutdownTimeout, 10*time.Second)
-	viper.SetDefault(KeyInterval, 5*time.Minute)
-	viper.SetDefault(KeyLogLevel, "info")
-	viper.SetDefault(KeyLogFormat, "text")
-	viper.SetDefault(KeyLogTimestampEnable, false)
-	viper.SetDefault(KeyLogTimestampFormat, time.DateTime)
+type Settings struct {
+	ShutdownTimeout            time.Duration
+	Interval                   time.Duration
+	IntervalJitter             time.Duration
+	Schedule                   *cron.Schedule
+	OneShot                    bool
+	LogLevel                   logrus.Level
+	LogFormatter               logrus.Formatter
+	LogFile                    string
+	LogRotation                logfile.Rotation
+	CertificateRequestsPaths   []string
+	CertificateRequestsInline  []map[string]any
+	CertificateRequestsPattern string
+	CertificateRequestsIgnore  []string
+	DefaultCountries           []string
+	DefaultOrganizations       []string
+	DefaultOrganizationalUnits []string
+	DefaultLocalities          []string
+	DefaultProvinces           []string
+	DefaultStreetAddresses     []string
+	DefaultPostalCodes         []string
+	DefaultExtKeyUsages        []string
+	PolicyMinRSASize           int
+	PolicyAllowedECDSACurves   []string
+	PolicyAllowedAlgorithms    []string
+	PolicyMaxDuration          time.Duration
+	PolicyMaxDurationMode      string
+	LegacySHA1Fingerprint      bool
+	AggregateValidationErrors  bool
+	ValidateOnStart            string
+	CheckWritableOnStart       bool
+}
 
+func Init() {
 	viper.SetEnvPrefix("UCERTS")
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -66,41 +155,228 @@ func Init() {
 		if err != nil {
 			logrus.Fatalf("Failed to load configuration file %s: %v", configFile, err)
 		}
+		viper.SetConfigFile(configFile)
 		viper.SetConfigType(ext)
 		if err := viper.ReadConfig(file); err != nil {
 			logrus.Fatalf("Failed to read configuration file %s: %v", configFile, err)
 		}
 	}
 
-	logLevel, err := logrus.ParseLevel(viper.GetString(KeyLogLevel))
+	settings, err := Load(viper.GetViper())
+	if err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
+	apply(settings)
+
+	if settings.LogFile != "" {
+		out, err := logfile.Open(settings.LogFile, settings.LogRotation)
+		if err != nil {
+			logrus.Fatalf("Failed to open log file %s: %v", settings.LogFile, err)
+		}
+		logrus.SetOutput(out)
+	}
+
+	logrus.Infof("Configuration file loaded: %s", configFile)
+}
+
+// Load reads the settings from v without touching the package globals nor the logrus configuration. The defaults file
+// referenced by default.from is resolved against the configuration file used by v.
+func Load(v *viper.Viper) (Settings, error) {
+	v.SetDefault(KeyShutdownTimeout, 10*time.Second)
+	v.SetDefault(KeyInterval, 5*time.Minute)
+	v.SetDefault(KeyLogLevel, "info")
+	v.SetDefault(KeyLogFormat, "text")
+	v.SetDefault(KeyLogTimestampEnable, false)
+	v.SetDefault(KeyLogTimestampFormat, time.DateTime)
+
+	if err := mergeDefaultsFile(v, filepath.Dir(v.ConfigFileUsed())); err != nil {
+		return Settings{}, err
+	}
+
+	logLevel, err := logrus.ParseLevel(v.GetString(KeyLogLevel))
 	if err != nil {
-		logrus.Fatalf("Invalid log level: %v", err)
+		return Settings{}, fmt.Errorf(format.WrapErrors, ErrInvalidLogLevel, err)
 	}
-	logrus.SetLevel(logLevel)
 
-	enableTimestamp := viper.GetBool(KeyLogTimestampEnable)
-	timestampFormat := viper.GetString(KeyLogTimestampFormat)
+	enableTimestamp := v.GetBool(KeyLogTimestampEnable)
+	timestampFormat := v.GetString(KeyLogTimestampFormat)
 	var formatter logrus.Formatter
-	switch viper.GetString(KeyLogFormat) {
+	switch v.GetString(KeyLogFormat) {
 	case "json":
 		formatter = &logrus.JSONFormatter{DisableTimestamp: !enableTimestamp, TimestampFormat: timestampFormat}
 	default:
 		formatter = &logrus.TextFormatter{DisableTimestamp: !enableTimestamp, FullTimestamp: true, TimestampFormat: timestampFormat}
 	}
-	logrus.SetFormatter(formatter)
 
-	ShutdownTimeout = viper.GetDuration(KeyShutdownTimeout)
-	Interval = viper.GetDuration(KeyInterval)
-	CertificateRequestsPaths = viper.GetStringSlice(KeyCertificateRequestsPaths)
-	DefaultCountries = viper.GetStringSlice(KeyDefaultCountries)
-	DefaultOrganizations = viper.GetStringSlice(KeyDefaultOrganizations)
-	DefaultOrganizationalUnits = viper.GetStringSlice(KeyDefaultOrganizationalUnits)
-	DefaultLocalities = viper.GetStringSlice(KeyDefaultLocalities)
-	DefaultProvinces = viper.GetStringSlice(KeyDefaultProvinces)
-	DefaultStreetAddresses = viper.GetStringSlice(KeyDefaultStreetAddresses)
-	DefaultPostalCodes = viper.GetStringSlice(KeyDefaultPostalCodes)
+	logRotation := logfile.Rotation{
+		MaxSize:    int64(v.GetSizeInBytes(KeyLogRotationMaxSize)),
+		MaxAge:     v.GetDuration(KeyLogRotationMaxAge),
+		MaxBackups: v.GetInt(KeyLogRotationMaxBackups),
+	}
+
+	interval := v.GetDuration(KeyInterval)
+	if interval < MinInterval {
+		logrus.Warnf("Interval %v lower than %v, using %v", interval, MinInterval, MinInterval)
+		interval = MinInterval
+	}
+	intervalJitter := v.GetDuration(KeyIntervalJitter)
+	if intervalJitter < 0 || intervalJitter >= interval {
+		return Settings{}, fmt.Errorf("%w %v: must be positive and lower than %s", ErrInvalidIntervalJitter, intervalJitter, KeyInterval)
+	}
 
-	logrus.Infof("Configuration file loaded: %s", configFile)
+	var schedule *cron.Schedule
+	if expression := v.GetString(KeySchedule); expression != "" {
+		if schedule, err = cron.Parse(expression); err != nil {
+			return Settings{}, fmt.Errorf(format.WrapErrors, ErrInvalidSchedule, err)
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return Settings{}, fmt.Errorf("%w %q: never matches", ErrInvalidSchedule, expression)
+		}
+	}
+
+	inline, err := getMapSlice(v, KeyCertificateRequestsInline)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	pattern := v.GetString(KeyCertificateRequestsPattern)
+	ignore := v.GetStringSlice(KeyCertificateRequestsIgnore)
+	for _, p := range append([]string{pattern}, ignore...) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return Settings{}, fmt.Errorf("%w %q: %v", ErrInvalidFilePattern, p, err)
+		}
+	}
+
+	validateOnStart := v.GetString(KeyValidateOnStart)
+	switch validateOnStart {
+	case "", ValidateOnStartFatal, ValidateOnStartWarn:
+	default:
+		return Settings{}, fmt.Errorf(format.WrapErrorString, ErrInvalidValidateOnStart, validateOnStart)
+	}
+
+	maxDuration := v.GetDuration(KeyPolicyMaxDuration)
+	if maxDuration < 0 {
+		return Settings{}, fmt.Errorf("%w: %v", ErrInvalidMaxDuration, maxDuration)
+	}
+	maxDurationMode := strings.ToLower(v.GetString(KeyPolicyMaxDurationMode))
+	switch maxDurationMode {
+	case "":
+		maxDurationMode = MaxDurationReject
+	case MaxDurationReject, MaxDurationClamp:
+	default:
+		return Settings{}, fmt.Errorf("%w: mode %s", ErrInvalidMaxDuration, maxDurationMode)
+	}
+
+	return Settings{
+		ShutdownTimeout:            v.GetDuration(KeyShutdownTimeout),
+		Interval:                   interval,
+		IntervalJitter:             intervalJitter,
+		Schedule:                   schedule,
+		OneShot:                    v.GetBool(KeyOneShot),
+		LogLevel:                   logLevel,
+		LogFormatter:               formatter,
+		LogFile:                    v.GetString(KeyLogFile),
+		LogRotation:                logRotation,
+		CertificateRequestsPaths:   v.GetStringSlice(KeyCertificateRequestsPaths),
+		CertificateRequestsInline:  inline,
+		CertificateRequestsPattern: pattern,
+		CertificateRequestsIgnore:  ignore,
+		DefaultCountries:           v.GetStringSlice(KeyDefaultCountries),
+		DefaultOrganizations:       v.GetStringSlice(KeyDefaultOrganizations),
+		DefaultOrganizationalUnits: v.GetStringSlice(KeyDefaultOrganizationalUnits),
+		DefaultLocalities:          v.GetStringSlice(KeyDefaultLocalities),
+		DefaultProvinces:           v.GetStringSlice(KeyDefaultProvinces),
+		DefaultStreetAddresses:     v.GetStringSlice(KeyDefaultStreetAddresses),
+		DefaultPostalCodes:         v.GetStringSlice(KeyDefaultPostalCodes),
+		DefaultExtKeyUsages:        v.GetStringSlice(KeyDefaultExtKeyUsages),
+		PolicyMinRSASize:           v.GetInt(KeyPolicyMinRSASize),
+		PolicyAllowedECDSACurves:   v.GetStringSlice(KeyPolicyAllowedECDSACurves),
+		PolicyAllowedAlgorithms:    v.GetStringSlice(KeyPolicyAllowedAlgorithms),
+		PolicyMaxDuration:          maxDuration,
+		PolicyMaxDurationMode:      maxDurationMode,
+		LegacySHA1Fingerprint:      v.GetBool(KeyLegacySHA1Fingerprint),
+		AggregateValidationErrors:  v.GetBool(KeyAggregateValidationErrors),
+		ValidateOnStart:            validateOnStart,
+		CheckWritableOnStart:       v.GetBool(KeyCheckWritableOnStart),
+	}, nil
+}
+
+// mergeDefaultsFile loads the file referenced by default.from, relative to dir, as the default values of the default.*
+// keys: the values set in the main configuration file take precedence.
+func mergeDefaultsFile(v *viper.Viper, dir string) error {
+	path := v.GetString(KeyDefaultFrom)
+	if path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	ext, err := GetExtension(path)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrReadDefaultsFile, path, err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrReadDefaultsFile, err)
+	}
+	defer func() { _ = file.Close() }()
+	defaults := viper.New()
+	defaults.SetConfigType(ext)
+	if err := defaults.ReadConfig(file); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrReadDefaultsFile, path, err)
+	}
+	for _, key := range defaults.AllKeys() {
+		v.SetDefault("default."+key, defaults.Get(key))
+	}
+	return nil
+}
+
+func apply(s Settings) {
+	logrus.SetLevel(s.LogLevel)
+	logrus.SetFormatter(s.LogFormatter)
+
+	ShutdownTimeout = s.ShutdownTimeout
+	Interval = s.Interval
+	IntervalJitter = s.IntervalJitter
+	Schedule = s.Schedule
+	OneShot = s.OneShot
+	CertificateRequestsPaths = s.CertificateRequestsPaths
+	CertificateRequestsInline = s.CertificateRequestsInline
+	CertificateRequestsPattern = s.CertificateRequestsPattern
+	CertificateRequestsIgnore = s.CertificateRequestsIgnore
+	DefaultCountries = s.DefaultCountries
+	DefaultOrganizations = s.DefaultOrganizations
+	DefaultOrganizationalUnits = s.DefaultOrganizationalUnits
+	DefaultLocalities = s.DefaultLocalities
+	DefaultProvinces = s.DefaultProvinces
+	DefaultStreetAddresses = s.DefaultStreetAddresses
+	DefaultPostalCodes = s.DefaultPostalCodes
+	DefaultExtKeyUsages = s.DefaultExtKeyUsages
+	PolicyMinRSASize = s.PolicyMinRSASize
+	PolicyAllowedECDSACurves = s.PolicyAllowedECDSACurves
+	PolicyAllowedAlgorithms = s.PolicyAllowedAlgorithms
+	PolicyMaxDuration = s.PolicyMaxDuration
+	PolicyMaxDurationMode = s.PolicyMaxDurationMode
+	LegacySHA1Fingerprint = s.LegacySHA1Fingerprint
+	AggregateValidationErrors = s.AggregateValidationErrors
+	ValidateOnStart = s.ValidateOnStart
+	CheckWritableOnStart = s.CheckWritableOnStart
+}
+
+func getMapSlice(v *viper.Viper, key string) ([]map[string]any, error) {
+	items, ok := v.Get(key).([]any)
+	if !ok {
+		return nil, nil
+	}
+	maps := make([]map[string]any, 0, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s item #%d is not an object", ErrInvalidObjectList, key, i)
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
 }
 
 func GetExtension(configFile string) (string, error) {
@@ -114,3 +390,20 @@ func GetExtension(configFile string) (string, error) {
 	}
 	return "", ErrInvalidExtension
 }
+
+// IsCertificateRequestFile tells whether a file found in a certificate requests path must be handled.
+func IsCertificateRequestFile(file string) bool {
+	if _, err := GetExtension(file); err != nil {
+		return false
+	}
+	name := filepath.Base(file)
+	if CertificateRequestsPattern != "" {
+		if matched, _ := filepath.Match(CertificateRequestsPattern, name); !matched {
+			return false
+		}
+	}
+	return !slices.ContainsFunc(CertificateRequestsIgnore, func(pattern string) bool {
+		matched, _ := filepath.Match(pattern, name)
+		return matched
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/logfile"
 )
 
 func TestInit(t *testing.T) {
@@ -24,8 +27,15 @@ func TestInit(t *testing.T) {
 
 	assert.Equal(t, 123*time.Second, ShutdownTimeout)
 	assert.Equal(t, 321*time.Second, Interval)
+	assert.Equal(t, 12*time.Second, IntervalJitter)
+	require.NotNil(t, Schedule)
+	assert.Equal(t, "0 */6 * * *", Schedule.String())
+	assert.True(t, OneShot)
 	assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
 	assert.Equal(t, []string{"test"}, CertificateRequestsPaths)
+	assert.Equal(t, "*.cert.yaml", CertificateRequestsPattern)
+	assert.Equal(t, []string{"*.example.yaml", "_*.yaml"}, CertificateRequestsIgnore)
+	assert.Equal(t, []map[string]any{{"out": map[string]any{"dir": "inline"}, "commonname": "inline"}}, CertificateRequestsInline)
 	assert.Equal(t, []string{"testC"}, DefaultCountries)
 	assert.Equal(t, []string{"testO"}, DefaultOrganizations)
 	assert.Equal(t, []string{"testOU"}, DefaultOrganizationalUnits)
@@ -33,6 +43,16 @@ func TestInit(t *testing.T) {
 	assert.Equal(t, []string{"testP"}, DefaultProvinces)
 	assert.Equal(t, []string{"testSA"}, DefaultStreetAddresses)
 	assert.Equal(t, []string{"testPC"}, DefaultPostalCodes)
+	assert.Equal(t, []string{"client auth"}, DefaultExtKeyUsages)
+	assert.Equal(t, 4096, PolicyMinRSASize)
+	assert.Equal(t, []string{"P-384"}, PolicyAllowedECDSACurves)
+	assert.Equal(t, []string{"rsa", "ecdsa"}, PolicyAllowedAlgorithms)
+	assert.Equal(t, 9552*time.Hour, PolicyMaxDuration)
+	assert.Equal(t, MaxDurationClamp, PolicyMaxDurationMode)
+	assert.True(t, LegacySHA1Fingerprint)
+	assert.True(t, AggregateValidationErrors)
+	assert.Equal(t, ValidateOnStartFatal, ValidateOnStart)
+	assert.True(t, CheckWritableOnStart)
 	var line map[string]string
 	err = json.Unmarshal(out.Bytes(), &line)
 	require.NoError(t, err)
@@ -53,8 +73,14 @@ func TestInit_WithDefaultValues(t *testing.T) {
 
 	assert.Equal(t, 10*time.Second, ShutdownTimeout)
 	assert.Equal(t, 5*time.Minute, Interval)
+	assert.Zero(t, IntervalJitter)
+	assert.Nil(t, Schedule)
+	assert.False(t, OneShot)
 	assert.Equal(t, logrus.InfoLevel, logrus.GetLevel())
 	assert.Empty(t, CertificateRequestsPaths)
+	assert.Empty(t, CertificateRequestsInline)
+	assert.Empty(t, CertificateRequestsPattern)
+	assert.Empty(t, CertificateRequestsIgnore)
 	assert.Empty(t, DefaultCountries)
 	assert.Empty(t, DefaultOrganizations)
 	assert.Empty(t, DefaultOrganizationalUnits)
@@ -62,9 +88,163 @@ func TestInit_WithDefaultValues(t *testing.T) {
 	assert.Empty(t, DefaultProvinces)
 	assert.Empty(t, DefaultStreetAddresses)
 	assert.Empty(t, DefaultPostalCodes)
+	assert.Empty(t, DefaultExtKeyUsages)
+	assert.Zero(t, PolicyMinRSASize)
+	assert.Empty(t, PolicyAllowedECDSACurves)
+	assert.Empty(t, PolicyAllowedAlgorithms)
+	assert.Zero(t, PolicyMaxDuration)
+	assert.Equal(t, MaxDurationReject, PolicyMaxDurationMode)
+	assert.False(t, LegacySHA1Fingerprint)
+	assert.False(t, AggregateValidationErrors)
+	assert.Empty(t, ValidateOnStart)
+	assert.False(t, CheckWritableOnStart)
 	assert.Equal(t, "level=info msg=\"Configuration file loaded: \"\n", out.String())
 }
 
+func TestInit_WithLogFile(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(func() { logrus.SetOutput(os.Stderr) })
+	var out bytes.Buffer
+	logrus.SetOutput(&out)
+	logFile := filepath.Join(t.TempDir(), "ucerts.log")
+	t.Setenv("UCERTS_CONFIG", "")
+	t.Setenv("UCERTS_LOG_FILE", logFile)
+
+	Init()
+	logrus.Info("Next line")
+
+	assert.Empty(t, out.String())
+	actual, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, "level=info msg=\"Configuration file loaded: \"\nlevel=info msg=\"Next line\"\n", string(actual))
+}
+
+func TestLoad_WithLogRotation(t *testing.T) {
+	v := viper.New()
+	v.Set(KeyLogFile, "/var/log/ucerts.log")
+	v.Set(KeyLogRotationMaxSize, "10MB")
+	v.Set(KeyLogRotationMaxAge, "168h")
+	v.Set(KeyLogRotationMaxBackups, 5)
+
+	settings, err := Load(v)
+
+	require.NoError(t, err)
+	assert.Equal(t, "/var/log/ucerts.log", settings.LogFile)
+	assert.Equal(t, logfile.Rotation{MaxSize: 10 << 20, MaxAge: 7 * 24 * time.Hour, MaxBackups: 5}, settings.LogRotation)
+}
+
+func TestLoad_WithIndependentConfigs(t *testing.T) {
+	viper.Reset()
+	Interval = 0
+	first := viper.New()
+	first.SetConfigFile("testdata/valid.yaml")
+	require.NoError(t, first.ReadInConfig())
+	second := viper.New()
+	second.Set(KeyInterval, "1m")
+	second.Set(KeyCertificateRequestsPaths, []string{"other"})
+
+	firstSettings, err := Load(first)
+	require.NoError(t, err)
+	secondSettings, err := Load(second)
+	require.NoError(t, err)
+
+	assert.Equal(t, 321*time.Second, firstSettings.Interval)
+	assert.Equal(t, []string{"test"}, firstSettings.CertificateRequestsPaths)
+	assert.Equal(t, 4096, firstSettings.PolicyMinRSASize)
+	assert.Equal(t, logrus.DebugLevel, firstSettings.LogLevel)
+	assert.Equal(t, time.Minute, secondSettings.Interval)
+	assert.Equal(t, []string{"other"}, secondSettings.CertificateRequestsPaths)
+	assert.Zero(t, secondSettings.PolicyMinRSASize)
+	assert.Equal(t, logrus.InfoLevel, secondSettings.LogLevel)
+	assert.Zero(t, Interval)
+	assert.False(t, viper.IsSet(KeyInterval))
+}
+
+func TestLoad_WithIntervalBelowMinimum(t *testing.T) {
+	for name, tt := range map[string]struct {
+		interval string
+	}{
+		"Zero": {interval: "0s"},
+		"Tiny": {interval: "1ms"},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			logrus.SetOutput(&out)
+			v := viper.New()
+			v.Set(KeyInterval, tc.interval)
+
+			settings, err := Load(v)
+
+			require.NoError(t, err)
+			assert.Equal(t, MinInterval, settings.Interval)
+			assert.Contains(t, out.String(), `level=warning msg="Interval `)
+		})
+	}
+}
+
+func TestLoad_WithErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		values        map[string]any
+		expectedError error
+	}{
+		"Invalid log level":       {values: map[string]any{KeyLogLevel: "invalid"}, expectedError: ErrInvalidLogLevel},
+		"Negative jitter":         {values: map[string]any{KeyIntervalJitter: "-1s"}, expectedError: ErrInvalidIntervalJitter},
+		"Jitter above interval":   {values: map[string]any{KeyInterval: "1m", KeyIntervalJitter: "2m"}, expectedError: ErrInvalidIntervalJitter},
+		"Invalid schedule":        {values: map[string]any{KeySchedule: "* * *"}, expectedError: ErrInvalidSchedule},
+		"Never matching schedule": {values: map[string]any{KeySchedule: "0 0 31 4 *"}, expectedError: ErrInvalidSchedule},
+		"Invalid inline requests": {values: map[string]any{KeyCertificateRequestsInline: []any{"invalid"}}, expectedError: ErrInvalidObjectList},
+		"Invalid file pattern":    {values: map[string]any{KeyCertificateRequestsPattern: "[a-"}, expectedError: ErrInvalidFilePattern},
+		"Invalid ignore pattern":  {values: map[string]any{KeyCertificateRequestsIgnore: []string{"*.yaml", "[a-"}}, expectedError: ErrInvalidFilePattern},
+		"Invalid validateOnStart": {values: map[string]any{KeyValidateOnStart: "invalid"}, expectedError: ErrInvalidValidateOnStart},
+		"Negative max duration":   {values: map[string]any{KeyPolicyMaxDuration: "-1h"}, expectedError: ErrInvalidMaxDuration},
+		"Invalid duration mode":   {values: map[string]any{KeyPolicyMaxDurationMode: "invalid"}, expectedError: ErrInvalidMaxDuration},
+		"Missing defaults file":   {values: map[string]any{KeyDefaultFrom: "unknown.yaml"}, expectedError: ErrReadDefaultsFile},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			v := viper.New()
+			for key, value := range tc.values {
+				v.Set(key, value)
+			}
+
+			_, err := Load(v)
+
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestIsCertificateRequestFile(t *testing.T) {
+	for name, tt := range map[string]struct {
+		pattern  string
+		ignore   []string
+		file     string
+		expected bool
+	}{
+		"Supported extension without pattern":   {pattern: "", file: "dir/server.yaml", expected: true},
+		"Unsupported extension without pattern": {pattern: "", file: "dir/server.txt", expected: false},
+		"Matching pattern":                      {pattern: "*.cert.yaml", file: "dir/server.cert.yaml", expected: true},
+		"Not matching pattern":                  {pattern: "*.cert.yaml", file: "dir/kustomization.yaml", expected: false},
+		"Matching pattern but unsupported":      {pattern: "*.cert*", file: "dir/server.cert", expected: false},
+		"Ignored":                               {ignore: []string{"*.example.yaml", "_*.yaml"}, file: "dir/_template.yaml", expected: false},
+		"Not ignored":                           {ignore: []string{"*.example.yaml", "_*.yaml"}, file: "dir/server.yaml", expected: true},
+		"Matching pattern but ignored":          {pattern: "*.cert.yaml", ignore: []string{"*.example.*"}, file: "dir/server.example.cert.yaml", expected: false},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			CertificateRequestsPattern = tc.pattern
+			CertificateRequestsIgnore = tc.ignore
+			t.Cleanup(func() {
+				CertificateRequestsPattern = ""
+				CertificateRequestsIgnore = nil
+			})
+
+			assert.Equal(t, tc.expected, IsCertificateRequestFile(tc.file))
+		})
+	}
+}
+
 func TestGetExtension(t *testing.T) {
 	for name, tt := range map[string]struct {
 		file     string
@@ -109,3 +289,50 @@ func TestGetExtension_WithError(t *testing.T) {
 		})
 	}
 }
+
+func TestInit_WithDefaultsFile(t *testing.T) {
+	viper.Reset()
+	logrus.SetOutput(&bytes.Buffer{})
+	t.Setenv("UCERTS_CONFIG", "testdata/with-defaults-file.yaml")
+
+	Init()
+
+	assert.Equal(t, []string{"fileC"}, DefaultCountries)
+	assert.Equal(t, []string{"inlineO"}, DefaultOrganizations)
+	assert.Equal(t, []string{"fileOU"}, DefaultOrganizationalUnits)
+	assert.Equal(t, []string{"server auth"}, DefaultExtKeyUsages)
+	assert.Empty(t, DefaultLocalities)
+}
+
+func TestLoad_WithDefaultsFile(t *testing.T) {
+	v := viper.New()
+	v.SetConfigFile("testdata/with-defaults-file.yaml")
+	require.NoError(t, v.ReadInConfig())
+
+	settings, err := Load(v)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fileC"}, settings.DefaultCountries)
+	assert.Equal(t, []string{"inlineO"}, settings.DefaultOrganizations)
+	assert.Equal(t, []string{"fileOU"}, settings.DefaultOrganizationalUnits)
+	assert.Equal(t, []string{"server auth"}, settings.DefaultExtKeyUsages)
+	assert.Empty(t, settings.DefaultLocalities)
+}
+
+func TestMergeDefaultsFile_WithErrors(t *testing.T) {
+	for name, path := range map[string]string{
+		"Missing file":      "unknown.yaml",
+		"Invalid extension": "defaults/subject.invalid",
+		"Invalid file":      "defaults/invalid.yaml",
+	} {
+		tc := path // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			v := viper.New()
+			v.Set(KeyDefaultFrom, tc)
+
+			err := mergeDefaultsFile(v, "testdata")
+
+			assert.ErrorIs(t, err, ErrReadDefaultsFile)
+		})
+	}
+}
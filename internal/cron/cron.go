@@ -0,0 +1,179 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidExpression = errors.New("invalid cron expression")
+
+// maxSearch bounds the search of the next activation, for expressions like "0 0 30 2 *" that never match.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Schedule is a standard 5-field cron expression: minute, hour, day of month, month and day of week.
+type Schedule struct {
+	expression           string
+	minutes, hours, days uint64
+	months, weekdays     uint64
+	anyDay, anyWeekday   bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var fields = []bounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses a 5-field cron expression, each field being *, a value, a range or a comma separated list of them,
+// optionally with a /step. The @hourly, @daily, @weekly, @monthly and @yearly macros are supported too.
+func Parse(expression string) (*Schedule, error) {
+	spec := strings.TrimSpace(expression)
+	if macro, ok := macros[spec]; ok {
+		spec = macro
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("%w %q: expected %d fields", ErrInvalidExpression, expression, len(fields))
+	}
+	sets := make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidExpression, expression, err)
+		}
+		sets[i] = set
+	}
+	weekdays := sets[4]
+	// 7 is Sunday too
+	if weekdays&(1<<7) != 0 {
+		weekdays |= 1
+	}
+	return &Schedule{
+		expression: expression,
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   weekdays,
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rangePart = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", b.name, item[i+1:])
+			}
+		}
+		lo, hi := b.min, b.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], b); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: invalid range %q", b.name, rangePart)
+			}
+		default:
+			value, err := parseValue(rangePart, b)
+			if err != nil {
+				return 0, err
+			}
+			lo = value
+			// A single value with a step runs up to the maximum, like "5/15"
+			if step == 1 {
+				hi = value
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < b.min || v > b.max {
+		return 0, fmt.Errorf("%s: invalid value %q, must be between %d and %d", b.name, s, b.min, b.max)
+	}
+	return v, nil
+}
+
+// Next returns the first activation strictly after t, in the location of t, or the zero time if the expression never
+// matches.
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for next.Before(limit) {
+		switch {
+		case !has(s.months, int(next.Month())):
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !s.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case !has(s.hours, next.Hour()):
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case !has(s.minutes, next.Minute()):
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay applies the cron rule: when both the day of month and the day of week are restricted, either matches.
+func (s *Schedule) matchesDay(t time.Time) bool {
+	day, weekday := has(s.days, t.Day()), has(s.weekdays, int(t.Weekday()))
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+func (s *Schedule) String() string {
+	return s.expression
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
@@ -0,0 +1,88 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2026, time.October, 16, 10, 40, 30, 0, time.UTC) // Friday
+	for name, tt := range map[string]struct {
+		expression string
+		expected   time.Time
+	}{
+		"Every minute":         {expression: "* * * * *", expected: time.Date(2026, time.October, 16, 10, 41, 0, 0, time.UTC)},
+		"Step":                 {expression: "*/15 * * * *", expected: time.Date(2026, time.October, 16, 10, 45, 0, 0, time.UTC)},
+		"Step from value":      {expression: "5/30 * * * *", expected: time.Date(2026, time.October, 16, 11, 5, 0, 0, time.UTC)},
+		"Daily":                {expression: "30 2 * * *", expected: time.Date(2026, time.October, 17, 2, 30, 0, 0, time.UTC)},
+		"List":                 {expression: "0 8,12,18 * * *", expected: time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC)},
+		"Range":                {expression: "0 9-17/4 * * *", expected: time.Date(2026, time.October, 16, 13, 0, 0, 0, time.UTC)},
+		"Weekdays":             {expression: "0 9 * * 1-5", expected: time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC)},
+		"Sunday as 7":          {expression: "0 0 * * 7", expected: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)},
+		"Monthly":              {expression: "0 0 1 * *", expected: time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)},
+		"Next year":            {expression: "0 0 1 3 *", expected: time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		"Day of month or week": {expression: "0 0 20 * 6", expected: time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)},
+		"Leap day":             {expression: "0 0 29 2 *", expected: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		"Macro":                {expression: "@weekly", expected: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			schedule, err := Parse(tc.expression)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, schedule.Next(from))
+		})
+	}
+}
+
+func TestNext_IsStrictlyAfter(t *testing.T) {
+	schedule, err := Parse("0 * * * *")
+	require.NoError(t, err)
+	from := time.Date(2026, time.October, 16, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, from.Add(time.Hour), schedule.Next(from))
+}
+
+func TestNext_WithLocation(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	schedule, err := Parse("0 3 * * *")
+	require.NoError(t, err)
+
+	next := schedule.Next(time.Date(2026, time.October, 16, 10, 0, 0, 0, location))
+
+	assert.Equal(t, time.Date(2026, time.October, 17, 3, 0, 0, 0, location), next)
+}
+
+func TestNext_NeverMatching(t *testing.T) {
+	schedule, err := Parse("0 0 30 2 *")
+	require.NoError(t, err)
+
+	assert.True(t, schedule.Next(time.Now()).IsZero())
+}
+
+func TestParse_WithErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		expression string
+	}{
+		"Empty":           {expression: ""},
+		"Missing field":   {expression: "* * * *"},
+		"Extra field":     {expression: "* * * * * *"},
+		"Unknown macro":   {expression: "@often"},
+		"Not a number":    {expression: "a * * * *"},
+		"Minute too high": {expression: "60 * * * *"},
+		"Day too low":     {expression: "* * 0 * *"},
+		"Reversed range":  {expression: "* 10-5 * * *"},
+		"Zero step":       {expression: "*/0 * * * *"},
+		"Invalid step":    {expression: "*/a * * * *"},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tc.expression)
+
+			assert.ErrorIs(t, err, ErrInvalidExpression)
+		})
+	}
+}
@@ -10,24 +10,45 @@ import (
 
 	"github.com/goten4/ucerts/internal/build"
 	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/internal/funcs"
 )
 
-var signals = make(chan os.Signal, 1)
+var (
+	signals      = make(chan os.Signal, 1)
+	statusDumps  = make(chan os.Signal, 1)
+	statusDumper = funcs.NoOp
+)
 
 var WaitForStop = func() {
 	logrus.Infof("%s %s started", build.Name, build.Version)
 	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
 	defer signal.Stop(signals)
-	for s := range signals {
-		logrus.Infof("Signal %s received", s)
-		go func() {
-			<-time.After(config.ShutdownTimeout)
-			os.Exit(1)
-		}()
-		return
+	if len(statusSignals) > 0 {
+		signal.Notify(statusDumps, statusSignals...)
+		defer signal.Stop(statusDumps)
+	}
+	for {
+		select {
+		case s := <-statusDumps:
+			logrus.Infof("Signal %s received, dump status", s)
+			statusDumper()
+		case s := <-signals:
+			logrus.Infof("Signal %s received", s)
+			timeout := time.After(config.ShutdownTimeout)
+			go func() {
+				<-timeout
+				os.Exit(1)
+			}()
+			return
+		}
 	}
 }
 
+// SetStatusDumper sets the function called when receiving SIGUSR1.
+func SetStatusDumper(f func()) {
+	statusDumper = f
+}
+
 var GracefulStop = func() {
 	callGracefulStops()
 	logrus.Infof("%s stopped", build.Name)
@@ -0,0 +1,59 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"bytes"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+func TestWaitForStop_WithStatusSignal(t *testing.T) {
+	var out bytes.Buffer
+	logrus.SetOutput(&out)
+	logrus.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	config.ShutdownTimeout = time.Hour
+	dumped := make(chan struct{})
+	SetStatusDumper(func() {
+		logrus.Info("Status dumped")
+		dumped <- struct{}{}
+	})
+	t.Cleanup(func() { SetStatusDumper(func() {}) })
+	stopped := make(chan struct{})
+
+	go func() {
+		WaitForStop()
+		close(stopped)
+	}()
+	statusDumps <- syscall.SIGUSR1
+	waitFor(t, dumped)
+	statusDumps <- syscall.SIGUSR1
+	waitFor(t, dumped)
+	signals <- syscall.SIGTERM
+	waitFor(t, stopped)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	assert.Equal(t, []string{
+		`level=info msg="Signal user defined signal 1 received, dump status"`,
+		`level=info msg="Status dumped"`,
+		`level=info msg="Signal user defined signal 1 received, dump status"`,
+		`level=info msg="Status dumped"`,
+		`level=info msg="Signal terminated received"`,
+	}, lines[1:])
+}
+
+func waitFor(t *testing.T, c <-chan struct{}) {
+	select {
+	case <-c:
+	case <-time.After(time.Second):
+		require.Fail(t, "timeout")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+var statusSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package daemon
+
+import "os"
+
+// No SIGUSR1 on Windows
+var statusSignals []os.Signal
@@ -0,0 +1,163 @@
+package logfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+var (
+	ErrOpenFile   = errors.New("open log file")
+	ErrRotateFile = errors.New("rotate log file")
+)
+
+var now = time.Now
+
+type Rotation struct {
+	// MaxSize is the size in bytes above which the file is rotated, no rotation when zero
+	MaxSize int64
+	// MaxAge is how long backups are kept, forever when zero
+	MaxAge time.Duration
+	// MaxBackups is how many backups are kept, all of them when zero
+	MaxBackups int
+}
+
+// File is a log file rotated by size: the current file is renamed with a timestamp suffix before being recreated.
+type File struct {
+	path     string
+	rotation Rotation
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+}
+
+func Open(path string, rotation Rotation) (*File, error) {
+	f := &File{path: path, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.rotation.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.rotation.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrOpenFile, err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrOpenFile, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf(format.WrapErrors, ErrOpenFile, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate leaves no file open when it fails to reopen the log file, which is then reopened on next write. A file which
+// cannot be renamed is reopened as is, so that the rotation is retried on next write.
+func (f *File) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrRotateFile, err)
+	}
+	if err := os.Rename(f.path, f.backupPath(now())); err != nil {
+		return errors.Join(fmt.Errorf(format.WrapErrors, ErrRotateFile, err), f.open())
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.removeOldBackups()
+	return nil
+}
+
+func (f *File) backupPath(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// removeOldBackups is best effort, a backup which cannot be removed is retried on next rotation.
+func (f *File) removeOldBackups() {
+	backups := f.backups()
+	// Most recent first
+	slices.Reverse(backups)
+	for i, backup := range backups {
+		tooMany := f.rotation.MaxBackups > 0 && i >= f.rotation.MaxBackups
+		tooOld := f.rotation.MaxAge > 0 && now().Sub(backup.time) > f.rotation.MaxAge
+		if tooMany || tooOld {
+			_ = os.Remove(backup.path)
+		}
+	}
+}
+
+type backup struct {
+	path string
+	time time.Time
+}
+
+// backups returns the backups of the file sorted from the oldest to the most recent.
+func (f *File) backups() []backup {
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return nil
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(f.path), name), time: t})
+	}
+	slices.SortFunc(backups, func(a, b backup) int { return a.time.Compare(b.time) })
+	return backups
+}
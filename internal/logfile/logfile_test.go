@@ -0,0 +1,152 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "ucerts.log")
+	f, err := Open(path, Rotation{})
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("first line\n"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("second line\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assertFileContent(t, "first line\nsecond line\n", path)
+}
+
+func TestFile_WriteAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ucerts.log")
+	require.NoError(t, os.WriteFile(path, []byte("existing\n"), 0644))
+	f, err := Open(path, Rotation{MaxSize: 20})
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("appended\n"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("rotated\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assertFileContent(t, "rotated\n", path)
+	assert.Len(t, backupFiles(t, path), 1)
+}
+
+func TestFile_WriteRotatesAtMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ucerts.log")
+	clock := mockNow(t)
+	f, err := Open(path, Rotation{MaxSize: 10})
+	require.NoError(t, err)
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n"} {
+		*clock = clock.Add(time.Second)
+		_, err = f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	assertFileContent(t, "line 3\n", path)
+	backups := backupFiles(t, path)
+	require.Len(t, backups, 2)
+	assertFileContent(t, "line 1\n", backups[0])
+	assertFileContent(t, "line 2\n", backups[1])
+}
+
+func TestFile_WriteWithMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ucerts.log")
+	clock := mockNow(t)
+	f, err := Open(path, Rotation{MaxSize: 10, MaxBackups: 2})
+	require.NoError(t, err)
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		*clock = clock.Add(time.Second)
+		_, err = f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	backups := backupFiles(t, path)
+	require.Len(t, backups, 2)
+	assertFileContent(t, "line 2\n", backups[0])
+	assertFileContent(t, "line 3\n", backups[1])
+}
+
+func TestFile_WriteWithMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ucerts.log")
+	clock := mockNow(t)
+	f, err := Open(path, Rotation{MaxSize: 10, MaxAge: time.Hour})
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("line 1\n"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("line 2\n"))
+	require.NoError(t, err)
+	*clock = clock.Add(2 * time.Hour)
+	_, err = f.Write([]byte("line 3\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	backups := backupFiles(t, path)
+	require.Len(t, backups, 1)
+	assertFileContent(t, "line 2\n", backups[0])
+}
+
+func TestFile_WriteWithRenameError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ucerts.log")
+	clock := mockNow(t)
+	f, err := Open(path, Rotation{MaxSize: 10})
+	require.NoError(t, err)
+	_, err = f.Write([]byte("line 1\n"))
+	require.NoError(t, err)
+	*clock = clock.Add(time.Second)
+	// A non-empty directory at the backup path makes the rename fail
+	require.NoError(t, os.MkdirAll(filepath.Join(f.backupPath(*clock), "dir"), 0755))
+
+	_, err = f.Write([]byte("line 2\n"))
+	assert.ErrorIs(t, err, ErrRotateFile)
+	*clock = clock.Add(time.Second)
+	_, err = f.Write([]byte("line 3\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assertFileContent(t, "line 3\n", path)
+	assertFileContent(t, "line 1\n", f.backupPath(*clock))
+}
+
+func TestOpen_WithError(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0644))
+
+	_, err := Open(filepath.Join(dir, "file", "ucerts.log"), Rotation{})
+
+	assert.ErrorIs(t, err, ErrOpenFile)
+}
+
+func mockNow(t *testing.T) *time.Time {
+	clock := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	origin := now
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = origin })
+	return &clock
+}
+
+func backupFiles(t *testing.T, path string) []string {
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(path), "ucerts-*.log"))
+	require.NoError(t, err)
+	return files
+}
+
+func assertFileContent(t *testing.T, expected, file string) {
+	actual, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(actual))
+}
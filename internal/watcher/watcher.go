@@ -1,6 +1,11 @@
 package watcher
 
 import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/sirupsen/logrus"
 
@@ -10,22 +15,25 @@ import (
 )
 
 var (
-	watcher *fsnotify.Watcher
+	rewatchInitialBackoff = time.Second
+	rewatchMaxBackoff     = time.Minute
 )
 
 func Start() funcs.Stop {
-	var err error
-	if watcher, err = fsnotify.NewWatcher(); err != nil {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
 		logrus.Fatalf("Failed to start TLS configs watcher: %v", err)
 		return funcs.NoOp
 	}
+	done := make(chan struct{})
 	stop := func() {
+		close(done)
 		if err := watcher.Close(); err != nil {
 			logrus.Errorf("Failed to close TLS configs watcher: %v", err)
 		}
 	}
 
-	go listenEvents()
+	go listenEvents(watcher, done)
 
 	// Add TLS configs paths
 	for _, path := range config.CertificateRequestsPaths {
@@ -38,13 +46,26 @@ func Start() funcs.Stop {
 	return stop
 }
 
-func listenEvents() {
+func listenEvents(watcher *fsnotify.Watcher, done <-chan struct{}) {
+	// A removal may come with several events, a single rewatch per path is enough
+	rewatching := &pendingPaths{paths: make(map[string]struct{})}
 	for {
 		select {
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return
 			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				if path, watched := watchedPath(event.Name); watched {
+					if rewatching.add(path) {
+						go func() {
+							defer rewatching.remove(path)
+							rewatch(watcher, done, path)
+						}()
+					}
+					continue
+				}
+			}
 			if event.Has(fsnotify.Write) {
 				tls.HandleCertificateRequestFile(event.Name)
 			}
@@ -56,3 +77,57 @@ func listenEvents() {
 		}
 	}
 }
+
+func watchedPath(name string) (string, bool) {
+	name = filepath.Clean(name)
+	i := slices.IndexFunc(config.CertificateRequestsPaths, func(path string) bool {
+		return filepath.Clean(path) == name
+	})
+	if i < 0 {
+		return "", false
+	}
+	return config.CertificateRequestsPaths[i], true
+}
+
+// pendingPaths is the set of the paths waiting to be watched again.
+type pendingPaths struct {
+	mu    sync.Mutex
+	paths map[string]struct{}
+}
+
+// add reports whether the path was added, false when it is already pending.
+func (p *pendingPaths) add(path string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.paths[path]; ok {
+		return false
+	}
+	p.paths[path] = struct{}{}
+	return true
+}
+
+func (p *pendingPaths) remove(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.paths, path)
+}
+
+// rewatch waits for a removed path to reappear, as fsnotify does not deliver events for a recreated directory.
+func rewatch(watcher *fsnotify.Watcher, done <-chan struct{}, path string) {
+	logrus.Warnf("Watched path %s removed, waiting for it to reappear", path)
+	backoff := rewatchInitialBackoff
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(backoff):
+		}
+		if err := watcher.Add(path); err == nil {
+			logrus.Infof("Watching for path %s again", path)
+			// Files may have been created while the path was not watched
+			tls.LoadCertificateRequests(path)
+			return
+		}
+		backoff = min(backoff*2, rewatchMaxBackoff)
+	}
+}
@@ -0,0 +1,95 @@
+package watcher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/pkg/tls"
+)
+
+func TestStart_WithWatchedPathRecreated(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "requests")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	config.CertificateRequestsPaths = []string{dir}
+	rewatchInitialBackoff = 10 * time.Millisecond
+	var mu sync.Mutex
+	var out bytes.Buffer
+	logrus.SetOutput(&out)
+	logrus.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	var loaded, handled []string
+	mockFunc(t, &tls.LoadCertificateRequests, func(dir string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		loaded = append(loaded, dir)
+		return true
+	})
+	mockFunc(t, &tls.HandleCertificateRequestFile, func(file string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, file)
+		return true
+	})
+
+	stop := Start()
+	defer stop()
+	require.NoError(t, os.Remove(dir))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.Mkdir(dir, 0755))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(loaded) == 1
+	}, time.Second, 10*time.Millisecond)
+	file := filepath.Join(dir, "test.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("commonName: test"), 0644))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) > 0 && handled[0] == file
+	}, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{dir}, loaded)
+	assert.Equal(t, 1, strings.Count(out.String(), `level=warning msg="Watched path `+dir+` removed, waiting for it to reappear"`))
+	assert.Contains(t, out.String(), `level=info msg="Watching for path `+dir+` again"`)
+}
+
+func TestWatchedPath(t *testing.T) {
+	config.CertificateRequestsPaths = []string{"testdata/requests/", "other"}
+
+	path, watched := watchedPath("testdata/requests")
+	assert.True(t, watched)
+	assert.Equal(t, "testdata/requests/", path)
+
+	_, watched = watchedPath("testdata/requests/file.yaml")
+	assert.False(t, watched)
+}
+
+func TestPendingPaths(t *testing.T) {
+	pending := &pendingPaths{paths: make(map[string]struct{})}
+
+	assert.True(t, pending.add("requests"))
+	assert.False(t, pending.add("requests"))
+	assert.True(t, pending.add("other"))
+	pending.remove("requests")
+	assert.True(t, pending.add("requests"))
+}
+
+func mockFunc[T any](t *testing.T, f1 *T, f2 T) {
+	origin := *f1
+	*f1 = f2
+	t.Cleanup(func() {
+		*f1 = origin
+	})
+}
@@ -0,0 +1,103 @@
+package tls
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var ErrWriteArchive = errors.New("write archive")
+
+// WriteArchive packages the key, certificate, CA, chain and fullchain written for the request in a tar archive, gzipped
+// when path ends with .gz or .tgz. Files are stored in this order under their base name, with their mode on disk.
+var WriteArchive = func(req CertificateRequest, issuer *Issuer, path string) (err error) {
+	file, err := FS.Create(path)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteArchive, err)
+	}
+	defer func() {
+		// A failed close may leave a truncated archive
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf(format.WrapErrors, ErrWriteArchive, closeErr)
+		}
+	}()
+
+	if err := writeArchive(file, req, issuer, isGzipPath(path)); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteArchive, err)
+	}
+	return nil
+}
+
+func writeArchive(w io.Writer, req CertificateRequest, issuer *Issuer, gzipped bool) error {
+	if !gzipped {
+		return writeTar(w, req, issuer)
+	}
+	gz := gzip.NewWriter(w)
+	if err := writeTar(gz, req, issuer); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeTar(w io.Writer, req CertificateRequest, issuer *Issuer) error {
+	tw := tar.NewWriter(w)
+	for _, path := range archivedFiles(req, issuer) {
+		if err := addToArchive(tw, path); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// archivedFiles returns the files written by the generation, leaving out those from a previous one: the CA and chain
+// are only written with an issuer, while the fullchain is written in any case.
+func archivedFiles(req CertificateRequest, issuer *Issuer) []string {
+	var paths []string
+	if !req.OutKeyInMemory {
+		paths = append(paths, req.OutKeyPath)
+	}
+	paths = append(paths, req.OutCertPath)
+	if issuer != nil {
+		paths = append(paths, req.OutCAPath, req.OutChainPath)
+	}
+	paths = append(paths, req.OutFullChainPath)
+	var files []string
+	for _, path := range paths {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+func addToArchive(tw *tar.Writer, path string) error {
+	info, err := FS.Stat(path)
+	if err != nil {
+		return err
+	}
+	b, err := FS.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    filepath.Base(path),
+		Mode:    int64(info.Mode().Perm()),
+		Size:    int64(len(b)),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
+
+func isGzipPath(path string) bool {
+	return strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz")
+}
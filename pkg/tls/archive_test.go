@@ -0,0 +1,131 @@
+package tls
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOutFilesFromRequest_WithArchive(t *testing.T) {
+	for name, tt := range map[string]struct {
+		archive string
+		gzipped bool
+	}{
+		"Tar":         {archive: "certs.tar"},
+		"Gzipped tar": {archive: "certs.tar.gz", gzipped: true},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			loggerOutput()
+			dir := t.TempDir()
+			req := CertificateRequest{
+				OutCAPath:        filepath.Join(dir, "ca.crt"),
+				OutCertPath:      filepath.Join(dir, "tls.crt"),
+				OutKeyPath:       filepath.Join(dir, "tls.key"),
+				OutChainPath:     filepath.Join(dir, "chain.pem"),
+				OutFullChainPath: filepath.Join(dir, "fullchain.pem"),
+				OutTarPath:       filepath.Join(dir, tc.archive),
+				Duration:         time.Hour,
+			}
+			issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+			require.NoError(t, err)
+
+			GenerateOutFilesFromRequest(req, issuer)
+
+			names, contents, modes := extractArchive(t, req.OutTarPath, tc.gzipped)
+			assert.Equal(t, []string{"tls.key", "tls.crt", "ca.crt", "chain.pem", "fullchain.pem"}, names)
+			for _, name := range names {
+				path := filepath.Join(dir, name)
+				b, err := os.ReadFile(path)
+				require.NoError(t, err)
+				info, err := os.Stat(path)
+				require.NoError(t, err)
+				assert.Equal(t, string(b), contents[name], name)
+				assert.Equal(t, int64(info.Mode().Perm()), modes[name], name)
+			}
+		})
+	}
+}
+
+func TestWriteArchive_WithoutIssuer(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:        filepath.Join(dir, "ca.crt"),
+		OutCertPath:      filepath.Join(dir, "tls.crt"),
+		OutKeyPath:       filepath.Join(dir, "tls.key"),
+		OutKeyInMemory:   true,
+		OutChainPath:     filepath.Join(dir, "chain.pem"),
+		OutFullChainPath: filepath.Join(dir, "fullchain.pem"),
+		OutTarPath:       filepath.Join(dir, "certs.tgz"),
+		Duration:         time.Hour,
+	}
+	// Left by a previous generation with an issuer
+	require.NoError(t, os.WriteFile(req.OutCAPath, []byte("stale"), 0600))
+	require.NoError(t, os.WriteFile(req.OutChainPath, []byte("stale"), 0600))
+	mock(t, &PrivateKeyHook, func(_ CertificateRequest, _ crypto.PrivateKey, _ []byte) error { return nil })
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	names, _, _ := extractArchive(t, req.OutTarPath, true)
+	assert.Equal(t, []string{"tls.crt", "fullchain.pem"}, names)
+}
+
+func TestWriteArchive_WithError(t *testing.T) {
+	memFS := newMemFileSystem(t)
+	memFS.errs["create"] = errors.New("create error")
+
+	err := WriteArchive(CertificateRequest{}, nil, "certs.tar")
+
+	assert.ErrorIs(t, err, ErrWriteArchive)
+}
+
+func TestWriteArchive_WithCloseError(t *testing.T) {
+	memFS := newMemFileSystem(t)
+	memFS.files["tls.crt"] = &fstest.MapFile{Data: []byte("cert"), Mode: 0644}
+	memFS.errs["close"] = errors.New("close error")
+
+	err := WriteArchive(CertificateRequest{OutCertPath: "tls.crt", OutKeyInMemory: true}, nil, "certs.tar")
+
+	assert.ErrorIs(t, err, ErrWriteArchive)
+	assert.ErrorContains(t, err, "close error")
+}
+
+func extractArchive(t *testing.T, path string, gzipped bool) ([]string, map[string]string, map[string]int64) {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	var r io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		r = gz
+	}
+	var names []string
+	contents := make(map[string]string)
+	modes := make(map[string]int64)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, header.Name)
+		contents[header.Name] = string(b)
+		modes[header.Name] = header.Mode
+	}
+	return names, contents, modes
+}
@@ -2,14 +2,17 @@ package tls
 
 import (
 	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
 	"github.com/goten4/ucerts/internal/config"
@@ -19,16 +22,56 @@ import (
 const (
 	KeyOutDir              = "out.dir"
 	KeyOutCert             = "out.cert"
+	KeyOutCertIncludeChain = "out.certIncludeChain"
 	KeyOutKey              = "out.key"
+	KeyOutKeyDER           = "out.keyDer"
+	KeyOutKeyFromCert      = "out.keyFromCert"
 	KeyOutCA               = "out.ca"
+	KeyOutChain            = "out.chain"
+	KeyOutFullChain        = "out.fullchain"
+	KeyOutLayout           = "out.layout"
+	KeyOutText             = "out.text"
+	KeyOutManifest         = "out.manifest"
+	KeyOutRequestHash      = "out.requestHash"
+	KeyOutBundle           = "out.bundle"
+	KeyOutTar              = "out.tar"
+	KeyOutP7B              = "out.p7b"
+	KeyOutBundleWithoutKey = "out.bundleWithoutKey"
+	KeyOutPublicKey        = "out.publicKey"
+	KeyOutStaging          = "out.staging"
+	KeyOutPEMHeaders       = "out.pemHeaders"
+	KeyOutKeepPrevious     = "out.keepPrevious"
+	KeyEnabled             = "enabled"
+	KeyRole                = "role"
 	KeyCommonName          = "commonName"
 	KeyIsCA                = "isCA"
 	KeyDuration            = "duration"
+	KeyNotBefore           = "notBefore"
+	KeyNotAfter            = "notAfter"
 	KeyRenewBefore         = "renewBefore"
+	KeyRenewBeforePercent  = "renewBeforePercent"
+	KeyRenewSpread         = "renewBeforeSpread"
+	KeyCheckInterval       = "checkInterval"
+	KeyMaxFailures         = "maxConsecutiveFailures"
 	KeyKeyUsages           = "keyUsages"
 	KeyExtKeyUsages        = "extKeyUsages"
 	KeyDNSNames            = "dnsNames"
+	KeyDNSNamesFile        = "dnsNamesFile"
 	KeyIPAddresses         = "ipAddresses"
+	KeyIPAddressesFile     = "ipAddressesFile"
+	KeySANCritical         = "sanCritical"
+	KeyBCCritical          = "basicConstraintsCritical"
+	KeyValidateDNS         = "validateDNSResolves"
+	KeyValidateDNSStrict   = "validateDNSResolvesStrict"
+	KeyAllowNoSAN          = "allowServerAuthWithoutSAN"
+	KeyOCSPNoCheck         = "ocspNoCheck"
+	KeyMustStaple          = "mustStaple"
+	KeySuppressExtensions  = "suppressExtensions"
+	KeyVerifyChain         = "verifyChainBeforeSkip"
+	KeyVerifySystemRoots   = "verifyChainSystemRoots"
+	KeyRegenerateOnIssuer  = "regenerateOnIssuerChange"
+	KeyRegenerate          = "regenerate"
+	KeyOnCorruptCert       = "onCorruptCert"
 	KeyCountries           = "subject.countries"
 	KeyOrganizations       = "subject.organizations"
 	KeyOrganizationalUnits = "subject.organizationalUnits"
@@ -38,9 +81,32 @@ const (
 	KeyPostalCodes         = "subject.postalCodes"
 	KeyPrivateKeyAlgorithm = "privateKey.algorithm"
 	KeyPrivateKeySize      = "privateKey.size"
+	KeyPrivateKeyReuse     = "privateKey.reuse"
+	KeyPrivateKeyFormat    = "privateKey.format"
+	KeyPrivateKeys         = "privateKeys"
+	KeyRequireIssuer       = "requireIssuer"
 	KeyIssuerDir           = "issuer.dir"
 	KeyIssuerPublicKey     = "issuer.publicKey"
 	KeyIssuerPrivateKey    = "issuer.privateKey"
+	KeyIssuerPublicKeyEnv  = "issuer.publicKeyEnv"
+	KeyIssuerPrivateKeyEnv = "issuer.privateKeyEnv"
+	KeyIssuerPKCS11Module  = "issuer.pkcs11.module"
+	KeyIssuerPKCS11Slot    = "issuer.pkcs11.slot"
+	KeyIssuerPKCS11Pin     = "issuer.pkcs11.pin"
+	KeyIssuerPKCS11PinEnv  = "issuer.pkcs11.pinEnv"
+	KeyIssuerPKCS11PinFile = "issuer.pkcs11.pinFile"
+	KeyIssuerPKCS11Label   = "issuer.pkcs11.keyLabel"
+	KeySerialNumberSource  = "serialNumber.source"
+	KeySerialNumberPath    = "serialNumber.path"
+	KeyQCStatementsEnable  = "qcStatements.enable"
+	KeyQCStatementsTypes   = "qcStatements.types"
+	KeyMSTemplateName      = "msTemplate.name"
+	KeyMSTemplateOID       = "msTemplate.oid"
+	KeyMSTemplateMajor     = "msTemplate.majorVersion"
+	KeyMSTemplateMinor     = "msTemplate.minorVersion"
+	KeyLabels              = "labels"
+	KeyCTLogs              = "ctLogs"
+	KeyCTRequired          = "ctRequired"
 )
 
 var (
@@ -50,22 +116,86 @@ var (
 	ErrInvalidExtKeyUsages        = errors.New("invalid ext key usages")
 	ErrInvalidIPAddress           = errors.New("invalid ip addresses")
 	ErrMissingMandatoryField      = errors.New("missing mandatory field")
+	ErrConflictingFields          = errors.New("conflicting fields")
+	ErrInvalidRenewBeforePercent  = errors.New("invalid renew before percent")
+	ErrInvalidRenewSpread         = errors.New("invalid renew before spread")
+	ErrInvalidLayout              = errors.New("invalid layout")
+	ErrReadSANFile                = errors.New("read SAN file")
+	ErrInvalidPrivateKeys         = errors.New("invalid private keys")
+	ErrInvalidTimestamp           = errors.New("invalid timestamp")
+	ErrInvalidCheckInterval       = errors.New("invalid check interval")
+	ErrInvalidMaxFailures         = errors.New("invalid max consecutive failures")
+	ErrInvalidRole                = errors.New("invalid role")
+	ErrInvalidOnCorruptCert       = errors.New("invalid on corrupt cert mode")
+	ErrOutputWouldOverwriteIssuer = errors.New("output would overwrite issuer file")
+	ErrServerAuthWithoutSAN       = errors.New("server auth certificate without DNS names nor IP addresses")
+	ErrInvalidRegenerate          = errors.New("invalid regenerate mode")
+	ErrInvalidPrivateKeyFormat    = errors.New("invalid private key format")
+)
+
+const (
+	LayoutDefault = "default"
+	LayoutCertbot = "certbot"
+)
+
+// Roles seed the usages of the certificate, explicit keys take precedence.
+const (
+	RoleServer = "server"
+	RoleClient = "client"
+	RoleCA     = "ca"
+)
+
+// RegenerateCertOnly re-signs the certificate with the existing key when the request changes.
+const RegenerateCertOnly = "certOnly"
+
+// Behaviors when the existing certificate cannot be parsed (onCorruptCert), regenerate being the default.
+const (
+	OnCorruptRegenerate = "regenerate"
+	OnCorruptQuarantine = "quarantine"
+	OnCorruptFail       = "fail"
 )
 
 type PrivateKey struct {
 	Algorithm string
 	Size      int
+	Reuse     bool
+	Format    string
 }
 
 type IssuerPath struct {
-	PublicKey  string
-	PrivateKey string
+	PublicKey     string
+	PrivateKey    string
+	PublicKeyEnv  string
+	PrivateKeyEnv string
+	PKCS11        PKCS11Config
+}
+
+type SerialNumberSource struct {
+	Type string
+	Path string
 }
 
 type CertificateRequest struct {
+	Disabled            bool
 	OutCertPath         string
+	OutCertIncludeChain bool
 	OutKeyPath          string
+	OutKeyInMemory      bool
+	OutKeyDERPath       string
 	OutCAPath           string
+	OutChainPath        string
+	OutFullChainPath    string
+	OutTextPath         string
+	OutManifestPath     string
+	OutRequestHashPath  string
+	OutBundlePath       string
+	OutTarPath          string
+	OutP7BPath          string
+	OutBundleWithoutKey bool
+	OutPublicKeyPath    string
+	OutStaging          bool
+	OutPEMHeaders       bool
+	OutKeepPrevious     bool
 	CommonName          string
 	IsCA                bool
 	Countries           []string
@@ -76,79 +206,455 @@ type CertificateRequest struct {
 	StreetAddresses     []string
 	PostalCodes         []string
 	Duration            time.Duration
+	NotBefore           time.Time
+	NotAfter            time.Time
 	RenewBefore         time.Duration
+	RenewSpread         time.Duration
+	CheckInterval       time.Duration
+	MaxFailures         int
 	KeyUsage            x509.KeyUsage
 	ExtKeyUsage         []x509.ExtKeyUsage
+	UnknownExtKeyUsage  []asn1.ObjectIdentifier
 	DNSNames            []string
 	IPAddresses         []net.IP
+	SANCritical         bool
+	BCCritical          bool
+	OCSPNoCheck         bool
+	MustStaple          bool
+	SuppressExtensions  bool
+	VerifyChain         bool
+	VerifySystemRoots   bool
+	RegenerateOnIssuer  bool
+	Regenerate          string
+	OnCorruptCert       string
 	PrivateKey          PrivateKey
+	PrivateKeys         []PrivateKey
 	IssuerPath          IssuerPath
+	RequireIssuer       bool
+	SerialNumber        SerialNumberSource
+	QCStatements        QCStatements
+	MSTemplate          MSTemplate
+	CTLogs              []string
+	CTRequired          bool
+	Labels              map[string]string
 }
 
 var LoadCertificateRequest = func(path string) (CertificateRequest, error) {
+	conf, err := readCertificateRequestFile(path)
+	if err != nil {
+		return CertificateRequest{}, err
+	}
+
+	return buildCertificateRequest(conf)
+}
+
+func readCertificateRequestFile(path string) (*viper.Viper, error) {
 	conf := viper.New()
 	file, err := os.Open(path)
 	if err != nil {
-		return CertificateRequest{}, fmt.Errorf(format.WrapErrors, ErrOpenCertificateRequestFile, err)
+		return nil, fmt.Errorf(format.WrapErrors, ErrOpenCertificateRequestFile, err)
 	}
+	defer func() { _ = file.Close() }()
 	ext, err := config.GetExtension(path)
 	if err != nil {
-		return CertificateRequest{}, err
+		return nil, err
 	}
 	conf.SetConfigType(ext)
 	if err := conf.ReadConfig(file); err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrReadCertificateRequestFile, err)
+	}
+	return conf, nil
+}
+
+var BuildCertificateRequest = func(values map[string]any) (CertificateRequest, error) {
+	conf := viper.New()
+	if err := conf.MergeConfigMap(values); err != nil {
 		return CertificateRequest{}, fmt.Errorf(format.WrapErrors, ErrReadCertificateRequestFile, err)
 	}
 
-	conf.SetDefault(KeyOutCert, "tls.crt")
-	conf.SetDefault(KeyOutKey, "tls.key")
+	return buildCertificateRequest(conf)
+}
+
+func buildCertificateRequest(conf *viper.Viper) (CertificateRequest, error) {
+	var errs []error
+	// invalid returns the validation error to fail fast with, or collects it when the errors are aggregated
+	invalid := func(err error) error {
+		if config.AggregateValidationErrors {
+			errs = append(errs, err)
+			return nil
+		}
+		return err
+	}
+
+	// Checked before the layout defaults, which IsSet would report too
+	keyFromCert := conf.GetBool(KeyOutKeyFromCert) && !conf.IsSet(KeyOutKey)
+	switch layout := conf.GetString(KeyOutLayout); strings.ToLower(layout) {
+	case "", LayoutDefault:
+		conf.SetDefault(KeyOutCert, "tls.crt")
+		conf.SetDefault(KeyOutKey, "tls.key")
+	case LayoutCertbot:
+		conf.SetDefault(KeyOutCert, "cert.pem")
+		conf.SetDefault(KeyOutKey, "privkey.pem")
+		conf.SetDefault(KeyOutChain, "chain.pem")
+		conf.SetDefault(KeyOutFullChain, "fullchain.pem")
+	default:
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidLayout, layout)); err != nil {
+			return CertificateRequest{}, err
+		}
+		// Validate the rest of the request with the default layout
+		conf.SetDefault(KeyOutCert, "tls.crt")
+		conf.SetDefault(KeyOutKey, "tls.key")
+	}
+	if keyFromCert {
+		// server.crt gives server.key
+		cert := conf.GetString(KeyOutCert)
+		conf.SetDefault(KeyOutKey, strings.TrimSuffix(cert, filepath.Ext(cert))+".key")
+	}
+	conf.SetDefault(KeyEnabled, true)
 	conf.SetDefault(KeyOutCA, "ca.crt")
-	conf.SetDefault(KeyCountries, config.DefaultCountries)
-	conf.SetDefault(KeyOrganizations, config.DefaultOrganizations)
-	conf.SetDefault(KeyOrganizationalUnits, config.DefaultOrganizationalUnits)
-	conf.SetDefault(KeyLocalities, config.DefaultLocalities)
-	conf.SetDefault(KeyProvinces, config.DefaultProvinces)
-	conf.SetDefault(KeyStreetAddresses, config.DefaultStreetAddresses)
-	conf.SetDefault(KeyPostalCodes, config.DefaultPostalCodes)
+	switch role := conf.GetString(KeyRole); strings.ToLower(role) {
+	case "":
+	case RoleServer:
+		conf.SetDefault(KeyKeyUsages, []string{"digital signature"})
+		conf.SetDefault(KeyExtKeyUsages, []string{"server auth"})
+	case RoleClient:
+		conf.SetDefault(KeyKeyUsages, []string{"digital signature"})
+		conf.SetDefault(KeyExtKeyUsages, []string{"client auth"})
+	case RoleCA:
+		conf.SetDefault(KeyIsCA, true)
+		conf.SetDefault(KeyKeyUsages, []string{"cert sign", "crl sign"})
+	default:
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidRole, role)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+	// A CA does not need ext key usages, which would restrict the certificates it can issue
+	if !conf.GetBool(KeyIsCA) && !conf.IsSet(KeyExtKeyUsages) {
+		conf.SetDefault(KeyExtKeyUsages, config.DefaultExtKeyUsages)
+	}
 	conf.SetDefault(KeyIssuerPublicKey, "ca.crt")
 	conf.SetDefault(KeyIssuerPrivateKey, "ca.key")
 
 	outDir := conf.GetString(KeyOutDir)
 	if outDir == "" {
-		return CertificateRequest{}, fmt.Errorf(format.WrapErrorString, ErrMissingMandatoryField, KeyOutDir)
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrMissingMandatoryField, KeyOutDir)); err != nil {
+			return CertificateRequest{}, err
+		}
 	}
 
 	issuerDir := conf.GetString(KeyIssuerDir)
+	issuerPubKey := conf.GetString(KeyIssuerPublicKey)
+	issuerPrivKey := conf.GetString(KeyIssuerPrivateKey)
 	var issuerPath IssuerPath
-	if issuerDir != "" {
-		issuerPubKeyPath := filepath.Join(issuerDir, conf.GetString(KeyIssuerPublicKey))
-		issuerPrivKeyPath := filepath.Join(issuerDir, conf.GetString(KeyIssuerPrivateKey))
-		issuerPath = IssuerPath{PublicKey: issuerPubKeyPath, PrivateKey: issuerPrivKeyPath}
+	// Absolute issuer key paths (e.g. a mounted Kubernetes secret) do not need issuer.dir
+	if issuerDir != "" || (filepath.IsAbs(issuerPubKey) && filepath.IsAbs(issuerPrivKey)) {
+		issuerPath = IssuerPath{
+			PublicKey:  issuerKeyPath(issuerDir, issuerPubKey),
+			PrivateKey: issuerKeyPath(issuerDir, issuerPrivKey),
+		}
+	} else if filepath.IsAbs(issuerPubKey) || filepath.IsAbs(issuerPrivKey) {
+		// A single absolute key means an issuer was intended, rather than a self-signed certificate
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrMissingMandatoryField, KeyIssuerDir)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	// Base64 encoded issuer keys injected in environment variables take precedence over the files
+	issuerPubKeyEnv := conf.GetString(KeyIssuerPublicKeyEnv)
+	issuerPrivKeyEnv := conf.GetString(KeyIssuerPrivateKeyEnv)
+	if issuerPubKeyEnv != "" || issuerPrivKeyEnv != "" {
+		for _, field := range []struct {
+			key   string
+			value string
+		}{
+			{KeyIssuerPublicKeyEnv, issuerPubKeyEnv},
+			{KeyIssuerPrivateKeyEnv, issuerPrivKeyEnv},
+		} {
+			if field.value == "" {
+				if err := invalid(fmt.Errorf(format.WrapErrorString, ErrMissingMandatoryField, field.key)); err != nil {
+					return CertificateRequest{}, err
+				}
+			}
+		}
+		if conf.GetString(KeyIssuerPKCS11Module) != "" {
+			if err := invalid(fmt.Errorf("%w: %s and %s", ErrConflictingFields, KeyIssuerPublicKeyEnv, KeyIssuerPKCS11Module)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+		issuerPath = IssuerPath{PublicKeyEnv: issuerPubKeyEnv, PrivateKeyEnv: issuerPrivKeyEnv}
+	}
+
+	if pkcs11Module := conf.GetString(KeyIssuerPKCS11Module); pkcs11Module != "" {
+		if issuerDir == "" {
+			if err := invalid(fmt.Errorf(format.WrapErrorString, ErrMissingMandatoryField, KeyIssuerDir)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+		var pinKeys []string
+		for _, key := range []string{KeyIssuerPKCS11Pin, KeyIssuerPKCS11PinEnv, KeyIssuerPKCS11PinFile} {
+			if conf.GetString(key) != "" {
+				pinKeys = append(pinKeys, key)
+			}
+		}
+		if len(pinKeys) > 1 {
+			if err := invalid(fmt.Errorf("%w: %s and %s", ErrConflictingFields, pinKeys[0], pinKeys[1])); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+		// Fail when loading the request rather than when signing with it
+		if !pkcs11Supported {
+			if err := invalid(fmt.Errorf(format.WrapErrorString, ErrPKCS11Unsupported, KeyIssuerPKCS11Module)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+		issuerPath.PKCS11 = PKCS11Config{
+			Module:   pkcs11Module,
+			Slot:     conf.GetUint(KeyIssuerPKCS11Slot),
+			Pin:      conf.GetString(KeyIssuerPKCS11Pin),
+			PinEnv:   conf.GetString(KeyIssuerPKCS11PinEnv),
+			KeyLabel: conf.GetString(KeyIssuerPKCS11Label),
+		}
+		if pinFile := conf.GetString(KeyIssuerPKCS11PinFile); pinFile != "" {
+			issuerPath.PKCS11.PinFile = issuerKeyPath(issuerDir, pinFile)
+		}
+	}
+
+	optionalOutPath := func(key string) string {
+		if name := conf.GetString(key); name != "" {
+			return filepath.Join(outDir, name)
+		}
+		return ""
 	}
 
 	req := CertificateRequest{
+		Disabled:            !conf.GetBool(KeyEnabled),
 		OutCertPath:         filepath.Join(outDir, conf.GetString(KeyOutCert)),
-		OutKeyPath:          filepath.Join(outDir, conf.GetString(KeyOutKey)),
+		OutCertIncludeChain: conf.GetBool(KeyOutCertIncludeChain),
+		OutKeyPath:          optionalOutPath(KeyOutKey),
+		OutKeyInMemory:      conf.GetString(KeyOutKey) == "",
+		OutKeyDERPath:       optionalOutPath(KeyOutKeyDER),
 		OutCAPath:           filepath.Join(outDir, conf.GetString(KeyOutCA)),
+		OutChainPath:        optionalOutPath(KeyOutChain),
+		OutFullChainPath:    optionalOutPath(KeyOutFullChain),
+		OutPublicKeyPath:    optionalOutPath(KeyOutPublicKey),
+		OutTextPath:         optionalOutPath(KeyOutText),
+		OutManifestPath:     optionalOutPath(KeyOutManifest),
+		OutRequestHashPath:  optionalOutPath(KeyOutRequestHash),
+		OutTarPath:          optionalOutPath(KeyOutTar),
+		OutP7BPath:          optionalOutPath(KeyOutP7B),
+		OutBundlePath:       optionalOutPath(KeyOutBundle),
+		OutBundleWithoutKey: conf.GetBool(KeyOutBundleWithoutKey),
+		OutStaging:          conf.GetBool(KeyOutStaging),
+		OutPEMHeaders:       conf.GetBool(KeyOutPEMHeaders),
+		OutKeepPrevious:     conf.GetBool(KeyOutKeepPrevious),
 		CommonName:          conf.GetString(KeyCommonName),
 		IsCA:                conf.GetBool(KeyIsCA),
-		Countries:           conf.GetStringSlice(KeyCountries),
-		Organizations:       conf.GetStringSlice(KeyOrganizations),
-		OrganizationalUnits: conf.GetStringSlice(KeyOrganizationalUnits),
-		Localities:          conf.GetStringSlice(KeyLocalities),
-		Provinces:           conf.GetStringSlice(KeyProvinces),
-		StreetAddresses:     conf.GetStringSlice(KeyStreetAddresses),
-		PostalCodes:         conf.GetStringSlice(KeyPostalCodes),
+		Countries:           subjectField(conf, KeyCountries, config.DefaultCountries),
+		Organizations:       subjectField(conf, KeyOrganizations, config.DefaultOrganizations),
+		OrganizationalUnits: subjectField(conf, KeyOrganizationalUnits, config.DefaultOrganizationalUnits),
+		Localities:          subjectField(conf, KeyLocalities, config.DefaultLocalities),
+		Provinces:           subjectField(conf, KeyProvinces, config.DefaultProvinces),
+		StreetAddresses:     subjectField(conf, KeyStreetAddresses, config.DefaultStreetAddresses),
+		PostalCodes:         subjectField(conf, KeyPostalCodes, config.DefaultPostalCodes),
 		Duration:            conf.GetDuration(KeyDuration),
 		RenewBefore:         conf.GetDuration(KeyRenewBefore),
-		PrivateKey:          PrivateKey{Algorithm: conf.GetString(KeyPrivateKeyAlgorithm), Size: conf.GetInt(KeyPrivateKeySize)},
+		RenewSpread:         conf.GetDuration(KeyRenewSpread),
+		CheckInterval:       conf.GetDuration(KeyCheckInterval),
+		MaxFailures:         conf.GetInt(KeyMaxFailures),
+		SANCritical:         conf.GetBool(KeySANCritical),
+		BCCritical:          conf.GetBool(KeyBCCritical),
+		OCSPNoCheck:         conf.GetBool(KeyOCSPNoCheck),
+		MustStaple:          conf.GetBool(KeyMustStaple),
+		SuppressExtensions:  conf.GetBool(KeySuppressExtensions),
+		VerifyChain:         conf.GetBool(KeyVerifyChain),
+		VerifySystemRoots:   conf.GetBool(KeyVerifySystemRoots),
+		RegenerateOnIssuer:  conf.GetBool(KeyRegenerateOnIssuer),
+		Regenerate:          conf.GetString(KeyRegenerate),
+		OnCorruptCert:       strings.ToLower(conf.GetString(KeyOnCorruptCert)),
+		PrivateKey:          PrivateKey{Algorithm: conf.GetString(KeyPrivateKeyAlgorithm), Size: conf.GetInt(KeyPrivateKeySize), Reuse: conf.GetBool(KeyPrivateKeyReuse), Format: conf.GetString(KeyPrivateKeyFormat)},
 		IssuerPath:          issuerPath,
+		RequireIssuer:       conf.GetBool(KeyRequireIssuer),
+		SerialNumber:        SerialNumberSource{Type: conf.GetString(KeySerialNumberSource), Path: conf.GetString(KeySerialNumberPath)},
+		QCStatements:        QCStatements{Enable: conf.GetBool(KeyQCStatementsEnable)},
+		MSTemplate:          MSTemplate{Name: conf.GetString(KeyMSTemplateName), MajorVersion: conf.GetInt(KeyMSTemplateMajor), MinorVersion: conf.GetInt(KeyMSTemplateMinor)},
+		CTLogs:              conf.GetStringSlice(KeyCTLogs),
+		CTRequired:          conf.GetBool(KeyCTRequired),
+		Labels:              conf.GetStringMapString(KeyLabels),
+	}
+
+	commonName, err := resolveHostnamePlaceholders(req.CommonName)
+	if err != nil {
+		if err := invalid(err); err != nil {
+			return CertificateRequest{}, err
+		}
+	} else {
+		req.CommonName = commonName
+	}
+
+	if err := conf.UnmarshalKey(KeyPrivateKeys, &req.PrivateKeys); err != nil {
+		if err := invalid(fmt.Errorf(format.WrapErrors, ErrInvalidPrivateKeys, err)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+	for _, key := range append([]PrivateKey{req.PrivateKey}, req.PrivateKeys...) {
+		if key.Format != "" && !strings.EqualFold(key.Format, PrivateKeyFormatPKCS8) {
+			if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidPrivateKeyFormat, key.Format)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+	}
+	var algorithms []string
+	for _, key := range req.PrivateKeys {
+		algorithm := keyAlgorithm(key)
+		if slices.Contains(algorithms, algorithm) {
+			if err := invalid(fmt.Errorf("%w: duplicate algorithm %s", ErrInvalidPrivateKeys, algorithm)); err != nil {
+				return CertificateRequest{}, err
+			}
+			continue
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+
+	if req.NotBefore, err = parseTimestamp(conf, KeyNotBefore); err != nil {
+		if err := invalid(err); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+	if req.NotAfter, err = parseTimestamp(conf, KeyNotAfter); err != nil {
+		if err := invalid(err); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+	if !req.NotAfter.IsZero() {
+		if conf.IsSet(KeyDuration) {
+			if err := invalid(fmt.Errorf("%w: %s and %s", ErrConflictingFields, KeyDuration, KeyNotAfter)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+		if !req.NotBefore.IsZero() && !req.NotAfter.After(req.NotBefore) {
+			if err := invalid(fmt.Errorf("%w: %s must be after %s", ErrInvalidTimestamp, KeyNotAfter, KeyNotBefore)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+	}
+
+	// Clamped before renewBeforePercent applies to the duration
+	if err := checkDurationPolicy(req.Duration); err != nil {
+		if config.PolicyMaxDurationMode == config.MaxDurationClamp {
+			logrus.Warnf("Duration %v of certificate %s clamped to %v", req.Duration, req.OutCertPath, config.PolicyMaxDuration)
+			req.Duration = config.PolicyMaxDuration
+		} else if err := invalid(err); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+	if !req.NotAfter.IsZero() {
+		// The validity of an absolute notAfter starts at notBefore, or at the generation
+		notBefore := req.NotBefore
+		if notBefore.IsZero() {
+			notBefore = time.Now()
+		}
+		if err := checkDurationPolicy(req.NotAfter.Sub(notBefore)); err != nil {
+			if config.PolicyMaxDurationMode == config.MaxDurationClamp {
+				notAfter := notBefore.Add(config.PolicyMaxDuration)
+				logrus.Warnf("Not after %s of certificate %s clamped to %s", req.NotAfter.Format(time.RFC3339), req.OutCertPath, notAfter.Format(time.RFC3339))
+				req.NotAfter = notAfter
+			} else if err := invalid(err); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+	}
+
+	if conf.IsSet(KeyRenewBeforePercent) {
+		if conf.IsSet(KeyRenewBefore) {
+			if err := invalid(fmt.Errorf("%w: %s and %s", ErrConflictingFields, KeyRenewBefore, KeyRenewBeforePercent)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+		percent := conf.GetFloat64(KeyRenewBeforePercent)
+		if percent < 0 || percent > 100 {
+			if err := invalid(fmt.Errorf("%w: %v", ErrInvalidRenewBeforePercent, percent)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+		req.RenewBefore = time.Duration(float64(req.Duration) * percent / 100)
+	}
+
+	// A v1-like certificate cannot carry the extensions these fields require
+	if req.SuppressExtensions {
+		for _, field := range []struct {
+			key string
+			set bool
+		}{
+			{KeyIsCA, req.IsCA},
+			{KeyBCCritical, req.BCCritical},
+			{KeyOCSPNoCheck, req.OCSPNoCheck},
+			{KeyMustStaple, req.MustStaple},
+			{KeyQCStatementsEnable, req.QCStatements.Enable},
+			{KeyMSTemplateName, req.MSTemplate.Name != ""},
+			{KeyMSTemplateOID, conf.GetString(KeyMSTemplateOID) != ""},
+		} {
+			if field.set {
+				if err := invalid(fmt.Errorf("%w: %s and %s", ErrConflictingFields, KeySuppressExtensions, field.key)); err != nil {
+					return CertificateRequest{}, err
+				}
+			}
+		}
+	}
+
+	// The DER key would put on disk the key kept in memory
+	if req.OutKeyInMemory && req.OutKeyDERPath != "" {
+		if err := invalid(fmt.Errorf("%w: %s and %s", ErrConflictingFields, KeyOutKey, KeyOutKeyDER)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	switch req.Regenerate {
+	case "", RegenerateCertOnly:
+	default:
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidRegenerate, req.Regenerate)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	switch req.OnCorruptCert {
+	case "", OnCorruptRegenerate, OnCorruptQuarantine, OnCorruptFail:
+	default:
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidOnCorruptCert, req.OnCorruptCert)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	if req.CheckInterval < 0 {
+		if err := invalid(fmt.Errorf("%w: %v", ErrInvalidCheckInterval, req.CheckInterval)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	if req.RenewSpread < 0 {
+		if err := invalid(fmt.Errorf("%w: %v", ErrInvalidRenewSpread, req.RenewSpread)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	if req.MaxFailures < 0 {
+		if err := invalid(fmt.Errorf("%w: %d", ErrInvalidMaxFailures, req.MaxFailures)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	if strings.EqualFold(req.SerialNumber.Type, SerialNumberSourceFile) && req.SerialNumber.Path == "" {
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrMissingMandatoryField, KeySerialNumberPath)); err != nil {
+			return CertificateRequest{}, err
+		}
 	}
 
 	for _, s := range conf.GetStringSlice(KeyKeyUsages) {
 		keyUsage, err := findKeyUsage(s)
 		if err != nil {
-			return CertificateRequest{}, fmt.Errorf(format.WrapErrorString, ErrInvalidKeyUsages, s)
+			if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidKeyUsages, s)); err != nil {
+				return CertificateRequest{}, err
+			}
+			continue
 		}
 		req.KeyUsage |= keyUsage
 	}
@@ -156,26 +662,208 @@ var LoadCertificateRequest = func(path string) (CertificateRequest, error) {
 	for _, s := range conf.GetStringSlice(KeyExtKeyUsages) {
 		extKeyUsage, err := findExtKeyUsage(s)
 		if err != nil {
-			return CertificateRequest{}, fmt.Errorf(format.WrapErrorString, ErrInvalidExtKeyUsages, s)
+			// An OID crypto/x509 has no constant for is encoded as is
+			if oid, err := parseOID(s); err == nil {
+				req.UnknownExtKeyUsage = append(req.UnknownExtKeyUsage, oid)
+				continue
+			}
+			if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidExtKeyUsages, s)); err != nil {
+				return CertificateRequest{}, err
+			}
+			continue
 		}
 		req.ExtKeyUsage = append(req.ExtKeyUsage, extKeyUsage)
 	}
 
-	for _, dnsName := range conf.GetStringSlice(KeyDNSNames) {
+	dnsNames, err := withLinesFromFile(conf.GetStringSlice(KeyDNSNames), conf.GetString(KeyDNSNamesFile))
+	if err != nil {
+		if err := invalid(err); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+	for _, dnsName := range dnsNames {
+		if slices.Contains(req.DNSNames, dnsName) {
+			continue
+		}
 		req.DNSNames = append(req.DNSNames, dnsName)
 	}
 
-	for _, s := range conf.GetStringSlice(KeyIPAddresses) {
+	if conf.GetBool(KeyValidateDNS) {
+		if unresolved := unresolvedDNSNames(req.DNSNames); len(unresolved) > 0 {
+			if !conf.GetBool(KeyValidateDNSStrict) {
+				logrus.Warnf("DNS names do not resolve: %s", strings.Join(unresolved, ", "))
+			} else if err := invalid(fmt.Errorf(format.WrapErrorString, ErrUnresolvedDNSName, strings.Join(unresolved, ", "))); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+	}
+
+	ipAddresses, err := withLinesFromFile(conf.GetStringSlice(KeyIPAddresses), conf.GetString(KeyIPAddressesFile))
+	if err != nil {
+		if err := invalid(err); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+	for _, s := range ipAddresses {
 		ipAddr := net.ParseIP(s)
 		if ipAddr == nil {
-			return CertificateRequest{}, fmt.Errorf(format.WrapErrorString, ErrInvalidIPAddress, s)
+			if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidIPAddress, s)); err != nil {
+				return CertificateRequest{}, err
+			}
+			continue
+		}
+		// IPv4-mapped IPv6 addresses are equal to their IPv4 form
+		if slices.ContainsFunc(req.IPAddresses, ipAddr.Equal) {
+			continue
 		}
 		req.IPAddresses = append(req.IPAddresses, ipAddr)
 	}
 
+	// Modern clients ignore the common name and reject a server certificate without SANs
+	if slices.Contains(req.ExtKeyUsage, x509.ExtKeyUsageServerAuth) && len(req.DNSNames) == 0 && len(req.IPAddresses) == 0 && !conf.GetBool(KeyAllowNoSAN) && !req.SuppressExtensions {
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrServerAuthWithoutSAN, KeyAllowNoSAN)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	for _, s := range conf.GetStringSlice(KeyQCStatementsTypes) {
+		qcType, err := findQCType(s)
+		if err != nil {
+			if err := invalid(fmt.Errorf(format.WrapErrorString, ErrInvalidQCType, s)); err != nil {
+				return CertificateRequest{}, err
+			}
+			continue
+		}
+		req.QCStatements.Types = append(req.QCStatements.Types, qcType)
+	}
+
+	if s := conf.GetString(KeyMSTemplateOID); s != "" {
+		oid, err := parseOID(s)
+		if err != nil {
+			if err := invalid(fmt.Errorf("%w: %s: %w", ErrInvalidMSTemplate, KeyMSTemplateOID, err)); err != nil {
+				return CertificateRequest{}, err
+			}
+		}
+		req.MSTemplate.OID = oid
+	} else if conf.IsSet(KeyMSTemplateMajor) || conf.IsSet(KeyMSTemplateMinor) {
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrMissingMandatoryField, KeyMSTemplateOID)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	// An issuer-signed certificate must not silently end up self-signed
+	if req.RequireIssuer && req.IssuerPath == (IssuerPath{}) {
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrMissingMandatoryField, KeyIssuerDir)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	// With out.dir set to issuer.dir, the default CA copy would replace the issuer certificate itself
+	if path := issuerFileOverwrittenBy(req); path != "" {
+		if err := invalid(fmt.Errorf(format.WrapErrorString, ErrOutputWouldOverwriteIssuer, path)); err != nil {
+			return CertificateRequest{}, err
+		}
+	}
+
+	if len(errs) > 0 {
+		return CertificateRequest{}, errors.Join(errs...)
+	}
 	return req, nil
 }
 
+// issuerFileOverwrittenBy returns the issuer file that one of the out files of the request would overwrite, if any.
+func issuerFileOverwrittenBy(req CertificateRequest) string {
+	var issuerFiles []string
+	for _, path := range []string{req.IssuerPath.PublicKey, req.IssuerPath.PrivateKey} {
+		if path != "" {
+			issuerFiles = append(issuerFiles, absPath(path))
+		}
+	}
+	for _, variant := range KeyVariants(req) {
+		paths := append(outputPaths(&variant), &variant.OutManifestPath, &variant.OutRequestHashPath, &variant.OutTarPath)
+		for _, path := range paths {
+			if *path != "" && slices.Contains(issuerFiles, absPath(*path)) {
+				return *path
+			}
+		}
+	}
+	return ""
+}
+
+// KeyVariants returns one request per declared private key, with the algorithm inserted in the key and certificate
+// file names (tls.crt becomes tls.rsa.crt), or the request itself when it declares a single private key.
+func KeyVariants(req CertificateRequest) []CertificateRequest {
+	if len(req.PrivateKeys) == 0 {
+		return []CertificateRequest{req}
+	}
+	variants := make([]CertificateRequest, 0, len(req.PrivateKeys))
+	for _, key := range req.PrivateKeys {
+		variant := req
+		variant.PrivateKey = key
+		variant.PrivateKeys = nil
+		algorithm := keyAlgorithm(key)
+		for _, path := range []*string{&variant.OutKeyPath, &variant.OutKeyDERPath, &variant.OutPublicKeyPath, &variant.OutCertPath, &variant.OutFullChainPath, &variant.OutTextPath, &variant.OutManifestPath, &variant.OutBundlePath, &variant.OutRequestHashPath, &variant.OutTarPath, &variant.OutP7BPath} {
+			if *path != "" {
+				ext := filepath.Ext(*path)
+				*path = strings.TrimSuffix(*path, ext) + "." + algorithm + ext
+			}
+		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+func keyAlgorithm(key PrivateKey) string {
+	if key.Algorithm == "" {
+		return RSA
+	}
+	return strings.ToLower(key.Algorithm)
+}
+
+// subjectField returns the subject field of the request, or the default one when the field is absent: an explicit
+// empty list clears the default.
+func subjectField(conf *viper.Viper, key string, defaults []string) []string {
+	if conf.InConfig(key) {
+		return conf.GetStringSlice(key)
+	}
+	return defaults
+}
+
+func parseTimestamp(conf *viper.Viper, key string) (time.Time, error) {
+	switch v := conf.Get(key).(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		// YAML and TOML decoders already parse timestamps
+		return v, nil
+	default:
+		t, err := time.Parse(time.RFC3339, fmt.Sprint(v))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %s: %v", ErrInvalidTimestamp, key, err)
+		}
+		return t, nil
+	}
+}
+
+// withLinesFromFile appends the non-empty lines of file to values, lines starting with # are comments.
+func withLinesFromFile(values []string, file string) ([]string, error) {
+	if file == "" {
+		return values, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrReadSANFile, err)
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values = append(values, line)
+	}
+	return values, nil
+}
+
 func findKeyUsage(s string) (x509.KeyUsage, error) {
 	switch strings.ToLower(s) {
 	case "digital signature":
@@ -231,5 +919,33 @@ func findExtKeyUsage(s string) (x509.ExtKeyUsage, error) {
 	case "microsoft kernel code signing":
 		return x509.ExtKeyUsageMicrosoftKernelCodeSigning, nil
 	}
+	if extKeyUsage, ok := extKeyUsageOIDs[s]; ok {
+		return extKeyUsage, nil
+	}
 	return 0, ErrInvalidExtKeyUsages
 }
+
+// extKeyUsageOIDs maps dotted OIDs to the ext key usages crypto/x509 has a constant for.
+var extKeyUsageOIDs = map[string]x509.ExtKeyUsage{
+	"2.5.29.37.0":            x509.ExtKeyUsageAny,
+	"1.3.6.1.5.5.7.3.1":      x509.ExtKeyUsageServerAuth,
+	"1.3.6.1.5.5.7.3.2":      x509.ExtKeyUsageClientAuth,
+	"1.3.6.1.5.5.7.3.3":      x509.ExtKeyUsageCodeSigning,
+	"1.3.6.1.5.5.7.3.4":      x509.ExtKeyUsageEmailProtection,
+	"1.3.6.1.5.5.7.3.5":      x509.ExtKeyUsageIPSECEndSystem,
+	"1.3.6.1.5.5.7.3.6":      x509.ExtKeyUsageIPSECTunnel,
+	"1.3.6.1.5.5.7.3.7":      x509.ExtKeyUsageIPSECUser,
+	"1.3.6.1.5.5.7.3.8":      x509.ExtKeyUsageTimeStamping,
+	"1.3.6.1.5.5.7.3.9":      x509.ExtKeyUsageOCSPSigning,
+	"1.3.6.1.4.1.311.10.3.3": x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+	"2.16.840.1.113730.4.1":  x509.ExtKeyUsageNetscapeServerGatedCrypto,
+	"1.3.6.1.4.1.311.2.1.22": x509.ExtKeyUsageMicrosoftCommercialCodeSigning,
+	"1.3.6.1.4.1.311.61.1.1": x509.ExtKeyUsageMicrosoftKernelCodeSigning,
+}
+
+func issuerKeyPath(dir, file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
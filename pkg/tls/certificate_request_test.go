@@ -2,7 +2,10 @@ package tls
 
 import (
 	"crypto/x509"
+	"encoding/asn1"
+	"errors"
 	"net"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -17,8 +20,10 @@ func TestLoadCertificateRequest(t *testing.T) {
 	viper.Reset()
 	expected := CertificateRequest{
 		OutCertPath:         "testdata/tls/server.crt",
+		OutCertIncludeChain: true,
 		OutKeyPath:          "testdata/tls/key.pem",
 		OutCAPath:           "testdata/tls/ca.pem",
+		OutKeepPrevious:     true,
 		CommonName:          "test",
 		Countries:           []string{"FR", "BE"},
 		Organizations:       []string{"uCerts"},
@@ -29,11 +34,28 @@ func TestLoadCertificateRequest(t *testing.T) {
 		PostalCodes:         []string{"12345"},
 		Duration:            12345 * time.Hour,
 		RenewBefore:         123 * time.Hour,
+		RenewSpread:         24 * time.Hour,
+		CheckInterval:       time.Minute,
+		MaxFailures:         3,
 		ExtKeyUsage:         []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		DNSNames:            []string{"localhost"},
 		IPAddresses:         []net.IP{net.IPv4(127, 0, 0, 1), net.IPv4(127, 0, 1, 1)},
-		PrivateKey:          PrivateKey{Algorithm: "ecdsa", Size: 384},
+		SANCritical:         true,
+		BCCritical:          true,
+		OCSPNoCheck:         true,
+		MustStaple:          true,
+		VerifyChain:         true,
+		VerifySystemRoots:   true,
+		RegenerateOnIssuer:  true,
+		Regenerate:          RegenerateCertOnly,
+		OnCorruptCert:       OnCorruptQuarantine,
+		PrivateKey:          PrivateKey{Algorithm: "ecdsa", Size: 384, Reuse: true, Format: "pkcs8"},
 		IssuerPath:          IssuerPath{PublicKey: "testdata/ca.pem", PrivateKey: "testdata/ca-key.pem"},
+		RequireIssuer:       true,
+		QCStatements:        QCStatements{Enable: true, Types: []asn1.ObjectIdentifier{OIDQCTypeESign, OIDQCTypeWeb}},
+		CTLogs:              []string{"https://ct.example.com/log"},
+		CTRequired:          true,
+		Labels:              map[string]string{"team": "security", "env": "test"},
 	}
 
 	actual, err := LoadCertificateRequest("testdata/valid.yaml")
@@ -66,6 +88,8 @@ func TestLoadCertificateRequest_WithDefaultValues(t *testing.T) {
 		Duration:            12345 * time.Hour,
 		RenewBefore:         123 * time.Hour,
 		ExtKeyUsage:         []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:            []string{"localhost"},
+		Labels:              map[string]string{},
 	}
 
 	actual, err := LoadCertificateRequest("testdata/valid-defaults.yaml")
@@ -74,6 +98,774 @@ func TestLoadCertificateRequest_WithDefaultValues(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestLoadCertificateRequest_WithEmptySubjectOverride(t *testing.T) {
+	viper.Reset()
+	mock(t, &config.DefaultCountries, []string{"DEF"})
+	mock(t, &config.DefaultOrganizations, []string{"default O"})
+	dir := t.TempDir()
+
+	req, err := LoadCertificateRequest("testdata/empty-subject.yaml")
+
+	require.NoError(t, err)
+	assert.Empty(t, req.Organizations)
+	assert.Equal(t, []string{"DEF"}, req.Countries)
+	req.OutCertPath = filepath.Join(dir, "tls.crt")
+	req.OutKeyPath = filepath.Join(dir, "tls.key")
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	cert, err := GenerateCertificate(req, key, nil)
+	require.NoError(t, err)
+	assert.Empty(t, cert.Subject.Organization)
+	assert.Equal(t, []string{"DEF"}, cert.Subject.Country)
+}
+
+func TestBuildCertificateRequest_WithDefaultExtKeyUsages(t *testing.T) {
+	for name, tt := range map[string]struct {
+		values   map[string]any
+		expected []x509.ExtKeyUsage
+	}{
+		"Leaf inherits the default": {
+			values:   map[string]any{},
+			expected: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		},
+		"Leaf overrides the default": {
+			values:   map[string]any{"extKeyUsages": []any{"email protection"}},
+			expected: []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		},
+		"CA without ext key usages": {
+			values:   map[string]any{"isCA": true},
+			expected: nil,
+		},
+		"CA with ext key usages": {
+			values:   map[string]any{"isCA": true, "extKeyUsages": []any{"any"}},
+			expected: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			mock(t, &config.DefaultExtKeyUsages, []string{"client auth"})
+			values := map[string]any{"out": map[string]any{"dir": "testdata/tls"}, "commonName": "test"}
+			for k, v := range tc.values {
+				values[k] = v
+			}
+
+			actual, err := BuildCertificateRequest(values)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.ExtKeyUsage)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithRole(t *testing.T) {
+	for name, tt := range map[string]struct {
+		values              map[string]any
+		expectedIsCA        bool
+		expectedKeyUsage    x509.KeyUsage
+		expectedExtKeyUsage []x509.ExtKeyUsage
+	}{
+		"Server": {
+			values:              map[string]any{"role": "server"},
+			expectedKeyUsage:    x509.KeyUsageDigitalSignature,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+		"Client": {
+			values:              map[string]any{"role": "client"},
+			expectedKeyUsage:    x509.KeyUsageDigitalSignature,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		},
+		"CA": {
+			values:           map[string]any{"role": "ca"},
+			expectedIsCA:     true,
+			expectedKeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		},
+		"Explicit keys override the role": {
+			values: map[string]any{
+				"role":         "server",
+				"keyUsages":    []any{"key encipherment"},
+				"extKeyUsages": []any{"server auth", "client auth"},
+			},
+			expectedKeyUsage:    x509.KeyUsageKeyEncipherment,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			mock(t, &config.DefaultExtKeyUsages, []string{"client auth"})
+			values := map[string]any{"out": map[string]any{"dir": "testdata/tls"}, "commonName": "test", "dnsNames": []any{"localhost"}}
+			for k, v := range tc.values {
+				values[k] = v
+			}
+
+			actual, err := BuildCertificateRequest(values)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedIsCA, actual.IsCA)
+			assert.Equal(t, tc.expectedKeyUsage, actual.KeyUsage)
+			assert.Equal(t, tc.expectedExtKeyUsage, actual.ExtKeyUsage)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithInvalidRole(t *testing.T) {
+	viper.Reset()
+
+	_, err := BuildCertificateRequest(map[string]any{"out": map[string]any{"dir": "testdata/tls"}, "role": "invalid"})
+
+	assert.ErrorIs(t, err, ErrInvalidRole)
+}
+
+func TestBuildCertificateRequest_WithExtKeyUsageOIDs(t *testing.T) {
+	for name, tt := range map[string]struct {
+		extKeyUsages        []any
+		expected            []x509.ExtKeyUsage
+		expectedUnknownOIDs []asn1.ObjectIdentifier
+	}{
+		"Known OID": {
+			extKeyUsages: []any{"1.3.6.1.5.5.7.3.1"},
+			expected:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+		"Names and OIDs": {
+			extKeyUsages: []any{"client auth", "1.3.6.1.5.5.7.3.4"},
+			expected:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageEmailProtection},
+		},
+		"Unknown OID": {
+			extKeyUsages:        []any{"client auth", "1.3.6.1.4.1.311.20.2.2"},
+			expected:            []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			expectedUnknownOIDs: []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 311, 20, 2, 2}},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":          map[string]any{"dir": "testdata/tls"},
+				"commonName":   "test",
+				"dnsNames":     []any{"localhost"},
+				"extKeyUsages": tc.extKeyUsages,
+			}
+
+			actual, err := BuildCertificateRequest(values)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.ExtKeyUsage)
+			assert.Equal(t, tc.expectedUnknownOIDs, actual.UnknownExtKeyUsage)
+		})
+	}
+}
+
+func TestLoadCertificateRequest_WithRenewBeforePercent(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/renewbefore-percent.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, 99*time.Hour, actual.RenewBefore)
+}
+
+func TestLoadCertificateRequest_WithMaxDurationPolicy(t *testing.T) {
+	for name, tt := range map[string]struct {
+		maxDuration      time.Duration
+		mode             string
+		expectedDuration time.Duration
+		expectedError    error
+	}{
+		"Under the cap": {
+			maxDuration:      20000 * time.Hour,
+			mode:             config.MaxDurationReject,
+			expectedDuration: 12345 * time.Hour,
+		},
+		"Clamped": {
+			maxDuration:      9552 * time.Hour,
+			mode:             config.MaxDurationClamp,
+			expectedDuration: 9552 * time.Hour,
+		},
+		"Rejected": {
+			maxDuration:   9552 * time.Hour,
+			mode:          config.MaxDurationReject,
+			expectedError: ErrPolicyViolation,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			loggerOutput()
+			mock(t, &config.PolicyMaxDuration, tc.maxDuration)
+			mock(t, &config.PolicyMaxDurationMode, tc.mode)
+
+			actual, err := LoadCertificateRequest("testdata/valid.yaml")
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedDuration, actual.Duration)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithMaxDurationPolicyAndNotAfter(t *testing.T) {
+	notBefore := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, tt := range map[string]struct {
+		values           map[string]any
+		mode             string
+		expectedNotAfter func() time.Time
+		expectedError    error
+	}{
+		"Under the cap": {
+			values:           map[string]any{"notBefore": "2030-01-01T00:00:00Z", "notAfter": "2030-01-31T00:00:00Z"},
+			mode:             config.MaxDurationReject,
+			expectedNotAfter: func() time.Time { return notBefore.Add(30 * 24 * time.Hour) },
+		},
+		"Clamped from notBefore": {
+			values:           map[string]any{"notBefore": "2030-01-01T00:00:00Z", "notAfter": "2031-01-01T00:00:00Z"},
+			mode:             config.MaxDurationClamp,
+			expectedNotAfter: func() time.Time { return notBefore.Add(90 * 24 * time.Hour) },
+		},
+		"Clamped from now": {
+			values:           map[string]any{"notAfter": "2100-01-01T00:00:00Z"},
+			mode:             config.MaxDurationClamp,
+			expectedNotAfter: func() time.Time { return time.Now().Add(90 * 24 * time.Hour) },
+		},
+		"Rejected from notBefore": {
+			values:        map[string]any{"notBefore": "2030-01-01T00:00:00Z", "notAfter": "2031-01-01T00:00:00Z"},
+			mode:          config.MaxDurationReject,
+			expectedError: ErrPolicyViolation,
+		},
+		"Rejected from now": {
+			values:        map[string]any{"notAfter": "2100-01-01T00:00:00Z"},
+			mode:          config.MaxDurationReject,
+			expectedError: ErrPolicyViolation,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			loggerOutput()
+			mock(t, &config.PolicyMaxDuration, 90*24*time.Hour)
+			mock(t, &config.PolicyMaxDurationMode, tc.mode)
+			values := map[string]any{"out": map[string]any{"dir": "testdata/tls"}, "commonName": "test"}
+			for k, v := range tc.values {
+				values[k] = v
+			}
+
+			actual, err := BuildCertificateRequest(values)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.WithinDuration(t, tc.expectedNotAfter(), actual.NotAfter, time.Minute)
+		})
+	}
+}
+
+func TestLoadCertificateRequest_WithAbsoluteValidity(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/absolute-validity.yaml")
+
+	require.NoError(t, err)
+	assert.True(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Equal(actual.NotBefore))
+	assert.True(t, time.Date(2030, 6, 30, 10, 0, 0, 0, time.UTC).Equal(actual.NotAfter))
+	assert.Zero(t, actual.Duration)
+}
+
+func TestLoadCertificateRequest_WithDuplicateIPAddresses(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/duplicate-ipaddresses.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback, net.IPv4(10, 0, 0, 1)}, actual.IPAddresses)
+}
+
+func TestLoadCertificateRequest_WithServerAuthWithoutSANAllowed(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/serverauth-without-san-allowed.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, actual.ExtKeyUsage)
+	assert.Empty(t, actual.DNSNames)
+	assert.Empty(t, actual.IPAddresses)
+}
+
+func TestBuildCertificateRequest_WithSuppressExtensions(t *testing.T) {
+	viper.Reset()
+	values := map[string]any{
+		"out":                map[string]any{"dir": "testdata/tls"},
+		"commonName":         "test",
+		"extKeyUsages":       []any{"server auth"},
+		"suppressExtensions": true,
+	}
+
+	actual, err := BuildCertificateRequest(values)
+
+	require.NoError(t, err)
+	assert.True(t, actual.SuppressExtensions)
+}
+
+func TestLoadCertificateRequest_WithSANFiles(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/san-files.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost", "example.com", "www.example.com"}, actual.DNSNames)
+	assert.Equal(t, []net.IP{net.IPv4(127, 0, 0, 1), net.IPv4(10, 0, 0, 1)}, actual.IPAddresses)
+}
+
+func TestLoadCertificateRequest_WithPrivateKeys(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/privatekeys.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, []PrivateKey{{Algorithm: "rsa", Size: 2048}, {Algorithm: "ecdsa", Size: 384, Reuse: true}}, actual.PrivateKeys)
+}
+
+func TestKeyVariants(t *testing.T) {
+	req := CertificateRequest{
+		OutCertPath:      "tls/tls.crt",
+		OutKeyPath:       "tls/tls.key",
+		OutCAPath:        "tls/ca.crt",
+		OutFullChainPath: "tls/fullchain.pem",
+		OutManifestPath:  "tls/index.json",
+		PrivateKeys:      []PrivateKey{{Size: 2048}, {Algorithm: "ECDSA"}},
+	}
+
+	variants := KeyVariants(req)
+
+	require.Len(t, variants, 2)
+	assert.Equal(t, CertificateRequest{
+		OutCertPath:      "tls/tls.rsa.crt",
+		OutKeyPath:       "tls/tls.rsa.key",
+		OutCAPath:        "tls/ca.crt",
+		OutFullChainPath: "tls/fullchain.rsa.pem",
+		OutManifestPath:  "tls/index.rsa.json",
+		PrivateKey:       PrivateKey{Size: 2048},
+	}, variants[0])
+	assert.Equal(t, CertificateRequest{
+		OutCertPath:      "tls/tls.ecdsa.crt",
+		OutKeyPath:       "tls/tls.ecdsa.key",
+		OutCAPath:        "tls/ca.crt",
+		OutFullChainPath: "tls/fullchain.ecdsa.pem",
+		OutManifestPath:  "tls/index.ecdsa.json",
+		PrivateKey:       PrivateKey{Algorithm: "ECDSA"},
+	}, variants[1])
+}
+
+func TestKeyVariants_WithSinglePrivateKey(t *testing.T) {
+	req := CertificateRequest{OutCertPath: "tls/tls.crt", PrivateKey: PrivateKey{Algorithm: ECDSA}}
+
+	assert.Equal(t, []CertificateRequest{req}, KeyVariants(req))
+}
+
+func TestLoadCertificateRequest_WithPublicKey(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/publickey.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/tls.pub", actual.OutPublicKeyPath)
+}
+
+func TestLoadCertificateRequest_WithKeyDER(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/keyder.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/tls.der", actual.OutKeyDERPath)
+}
+
+func TestLoadCertificateRequest_WithInMemoryKey(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/inmemory-key.yaml")
+
+	require.NoError(t, err)
+	assert.Empty(t, actual.OutKeyPath)
+	assert.True(t, actual.OutKeyInMemory)
+}
+
+func TestLoadCertificateRequest_WithText(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/text.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/tls.txt", actual.OutTextPath)
+}
+
+func TestLoadCertificateRequest_WithManifest(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/manifest.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/index.json", actual.OutManifestPath)
+}
+
+func TestLoadCertificateRequest_WithRequestHash(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/requesthash.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/request.json", actual.OutRequestHashPath)
+}
+
+func TestLoadCertificateRequest_WithBundle(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/bundle.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/bundle.pem", actual.OutBundlePath)
+	assert.True(t, actual.OutBundleWithoutKey)
+}
+
+func TestLoadCertificateRequest_WithCertbotLayout(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/layout-certbot.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/cert.pem", actual.OutCertPath)
+	assert.Equal(t, "testdata/tls/privkey.pem", actual.OutKeyPath)
+	assert.Equal(t, "testdata/tls/chain.pem", actual.OutChainPath)
+	assert.Equal(t, "testdata/tls/fullchain.pem", actual.OutFullChainPath)
+}
+
+func TestLoadCertificateRequest_WithCertbotLayoutOverrides(t *testing.T) {
+	viper.Reset()
+
+	actual, err := LoadCertificateRequest("testdata/layout-certbot-override.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/server.pem", actual.OutCertPath)
+	assert.Equal(t, "testdata/tls/privkey.pem", actual.OutKeyPath)
+	assert.Equal(t, "testdata/tls/chain.pem", actual.OutChainPath)
+	assert.Equal(t, "testdata/tls/bundle.pem", actual.OutFullChainPath)
+}
+
+func TestBuildCertificateRequest_WithKeyFromCert(t *testing.T) {
+	for name, tt := range map[string]struct {
+		out                    map[string]any
+		expectedOutKeyPath     string
+		expectedOutKeyInMemory bool
+	}{
+		"Custom cert": {
+			out:                map[string]any{"cert": "server.crt", "keyFromCert": true},
+			expectedOutKeyPath: "testdata/tls/server.key",
+		},
+		"Cert without extension": {
+			out:                map[string]any{"cert": "server", "keyFromCert": true},
+			expectedOutKeyPath: "testdata/tls/server.key",
+		},
+		"Certbot layout": {
+			out:                map[string]any{"layout": "certbot", "cert": "server.pem", "keyFromCert": true},
+			expectedOutKeyPath: "testdata/tls/server.key",
+		},
+		"Explicit key wins": {
+			out:                map[string]any{"cert": "server.crt", "key": "private.pem", "keyFromCert": true},
+			expectedOutKeyPath: "testdata/tls/private.pem",
+		},
+		"Explicit in-memory key wins": {
+			out:                    map[string]any{"cert": "server.crt", "key": "", "keyFromCert": true},
+			expectedOutKeyInMemory: true,
+		},
+		"Disabled": {
+			out:                map[string]any{"cert": "server.crt"},
+			expectedOutKeyPath: "testdata/tls/tls.key",
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			tc.out["dir"] = "testdata/tls"
+
+			actual, err := BuildCertificateRequest(map[string]any{"out": tc.out, "commonName": "test"})
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOutKeyPath, actual.OutKeyPath)
+			assert.Equal(t, tc.expectedOutKeyInMemory, actual.OutKeyInMemory)
+		})
+	}
+}
+
+func TestLoadCertificateRequest_WithPKCS11Issuer(t *testing.T) {
+	if !pkcs11Supported {
+		t.Skip("PKCS#11 support not available in this build")
+	}
+	viper.Reset()
+	expected := IssuerPath{
+		PublicKey:  "testdata/ca.crt",
+		PrivateKey: "testdata/ca.key",
+		PKCS11:     PKCS11Config{Module: "/usr/lib/softhsm/libsofthsm2.so", Slot: 3, Pin: "1234", KeyLabel: "ca"},
+	}
+
+	actual, err := LoadCertificateRequest("testdata/pkcs11.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual.IssuerPath)
+}
+
+func TestBuildCertificateRequest_WithPKCS11PinReference(t *testing.T) {
+	if !pkcs11Supported {
+		t.Skip("PKCS#11 support not available in this build")
+	}
+	for name, tt := range map[string]struct {
+		pkcs11   map[string]any
+		expected PKCS11Config
+	}{
+		"Env":           {pkcs11: map[string]any{"module": "libsofthsm2.so", "pinEnv": "PIN"}, expected: PKCS11Config{Module: "libsofthsm2.so", PinEnv: "PIN"}},
+		"Relative file": {pkcs11: map[string]any{"module": "libsofthsm2.so", "pinFile": "pin"}, expected: PKCS11Config{Module: "libsofthsm2.so", PinFile: "testdata/pin"}},
+		"Absolute file": {pkcs11: map[string]any{"module": "libsofthsm2.so", "pinFile": "/run/secrets/pin"}, expected: PKCS11Config{Module: "libsofthsm2.so", PinFile: "/run/secrets/pin"}},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":    map[string]any{"dir": "testdata/tls"},
+				"issuer": map[string]any{"dir": "testdata", "pkcs11": tc.pkcs11},
+			}
+
+			actual, err := BuildCertificateRequest(values)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.IssuerPath.PKCS11)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithConflictingPKCS11Pins(t *testing.T) {
+	for name, tt := range map[string]struct {
+		pkcs11 map[string]any
+	}{
+		"PIN and env":  {pkcs11: map[string]any{"module": "libsofthsm2.so", "pin": "1234", "pinEnv": "PIN"}},
+		"PIN and file": {pkcs11: map[string]any{"module": "libsofthsm2.so", "pin": "1234", "pinFile": "pin"}},
+		"Env and file": {pkcs11: map[string]any{"module": "libsofthsm2.so", "pinEnv": "PIN", "pinFile": "pin"}},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":    map[string]any{"dir": "testdata/tls"},
+				"issuer": map[string]any{"dir": "testdata", "pkcs11": tc.pkcs11},
+			}
+
+			_, err := BuildCertificateRequest(values)
+
+			assert.ErrorIs(t, err, ErrConflictingFields)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithAbsoluteIssuerKeys(t *testing.T) {
+	for name, tt := range map[string]struct {
+		issuer   map[string]any
+		expected IssuerPath
+	}{
+		"Absolute paths without dir": {
+			issuer:   map[string]any{"publicKey": "/run/secrets/ca/tls.crt", "privateKey": "/run/secrets/ca/tls.key"},
+			expected: IssuerPath{PublicKey: "/run/secrets/ca/tls.crt", PrivateKey: "/run/secrets/ca/tls.key"},
+		},
+		"Absolute paths ignore dir": {
+			issuer:   map[string]any{"dir": "testdata", "publicKey": "/run/secrets/ca/tls.crt", "privateKey": "/run/secrets/ca/tls.key"},
+			expected: IssuerPath{PublicKey: "/run/secrets/ca/tls.crt", PrivateKey: "/run/secrets/ca/tls.key"},
+		},
+		"Mixed paths": {
+			issuer:   map[string]any{"dir": "testdata", "publicKey": "tls.crt", "privateKey": "/run/secrets/ca/tls.key"},
+			expected: IssuerPath{PublicKey: "testdata/tls.crt", PrivateKey: "/run/secrets/ca/tls.key"},
+		},
+		"Relative paths without dir": {
+			issuer:   map[string]any{"publicKey": "tls.crt", "privateKey": "tls.key"},
+			expected: IssuerPath{},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":    map[string]any{"dir": "testdata/tls"},
+				"issuer": tc.issuer,
+			}
+
+			actual, err := BuildCertificateRequest(values)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.IssuerPath)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithIssuerEnv(t *testing.T) {
+	viper.Reset()
+	values := map[string]any{
+		"out":    map[string]any{"dir": "testdata/tls"},
+		"issuer": map[string]any{"dir": "testdata", "publicKeyEnv": "CA_CRT", "privateKeyEnv": "CA_KEY"},
+	}
+
+	actual, err := BuildCertificateRequest(values)
+
+	require.NoError(t, err)
+	assert.Equal(t, IssuerPath{PublicKeyEnv: "CA_CRT", PrivateKeyEnv: "CA_KEY"}, actual.IssuerPath)
+}
+
+func TestBuildCertificateRequest_WithIssuerEnvErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		issuer        map[string]any
+		expectedError error
+	}{
+		"Missing private key env": {
+			issuer:        map[string]any{"publicKeyEnv": "CA_CRT"},
+			expectedError: ErrMissingMandatoryField,
+		},
+		"PKCS#11 module": {
+			issuer:        map[string]any{"dir": "testdata", "publicKeyEnv": "CA_CRT", "privateKeyEnv": "CA_KEY", "pkcs11": map[string]any{"module": "libsofthsm2.so"}},
+			expectedError: ErrConflictingFields,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":    map[string]any{"dir": "testdata/tls"},
+				"issuer": tc.issuer,
+			}
+
+			_, err := BuildCertificateRequest(values)
+
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithRequireIssuer(t *testing.T) {
+	for name, tt := range map[string]struct {
+		issuer        map[string]any
+		expectedError error
+	}{
+		"Issuer dir":     {issuer: map[string]any{"dir": "testdata"}},
+		"Issuer env":     {issuer: map[string]any{"publicKeyEnv": "CA_CRT", "privateKeyEnv": "CA_KEY"}},
+		"Without issuer": {issuer: map[string]any{"publicKey": "ca.crt"}, expectedError: ErrMissingMandatoryField},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":           map[string]any{"dir": "testdata/tls"},
+				"issuer":        tc.issuer,
+				"requireIssuer": true,
+			}
+
+			_, err := BuildCertificateRequest(values)
+
+			if tc.expectedError == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithOutputOverwritingIssuer(t *testing.T) {
+	for name, tt := range map[string]struct {
+		out           map[string]any
+		expectedError error
+	}{
+		"CA copy over issuer certificate": {
+			out:           map[string]any{"dir": "testdata"},
+			expectedError: ErrOutputWouldOverwriteIssuer,
+		},
+		"Key over issuer key": {
+			out:           map[string]any{"dir": "testdata/tls", "key": "../ca.key"},
+			expectedError: ErrOutputWouldOverwriteIssuer,
+		},
+		"Same dir with other file names": {
+			out: map[string]any{"dir": "testdata", "ca": "issuer.crt"},
+		},
+		"Other dir": {
+			out: map[string]any{"dir": "testdata/tls"},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":    tc.out,
+				"issuer": map[string]any{"dir": "testdata"},
+			}
+
+			_, err := BuildCertificateRequest(values)
+
+			if tc.expectedError == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestBuildCertificateRequest_WithMixedIssuerKeysWithoutDir(t *testing.T) {
+	for name, tt := range map[string]struct {
+		issuer map[string]any
+	}{
+		"Absolute public key":  {issuer: map[string]any{"publicKey": "/run/secrets/ca/tls.crt", "privateKey": "tls.key"}},
+		"Absolute private key": {issuer: map[string]any{"publicKey": "tls.crt", "privateKey": "/run/secrets/ca/tls.key"}},
+		"Default private key":  {issuer: map[string]any{"publicKey": "/run/secrets/ca/tls.crt"}},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":    map[string]any{"dir": "testdata/tls"},
+				"issuer": tc.issuer,
+			}
+
+			_, err := BuildCertificateRequest(values)
+
+			assert.ErrorIs(t, err, ErrMissingMandatoryField)
+			assert.ErrorContains(t, err, KeyIssuerDir)
+		})
+	}
+}
+
+func TestBuildCertificateRequest(t *testing.T) {
+	viper.Reset()
+	values := map[string]any{
+		"out":        map[string]any{"dir": "testdata/tls"},
+		"commonName": "inline",
+		"dnsNames":   []any{"localhost"},
+		"duration":   "24h",
+	}
+
+	actual, err := BuildCertificateRequest(values)
+
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/tls/tls.crt", actual.OutCertPath)
+	assert.Equal(t, "testdata/tls/tls.key", actual.OutKeyPath)
+	assert.Equal(t, "inline", actual.CommonName)
+	assert.Equal(t, []string{"localhost"}, actual.DNSNames)
+	assert.Equal(t, 24*time.Hour, actual.Duration)
+}
+
+func TestBuildCertificateRequest_WithMissingOutDir(t *testing.T) {
+	viper.Reset()
+
+	_, err := BuildCertificateRequest(map[string]any{"commonName": "inline"})
+
+	assert.ErrorIs(t, err, ErrMissingMandatoryField)
+}
+
 func TestLoadCertificateRequest_WithErrors(t *testing.T) {
 	for name, tt := range map[string]struct {
 		certificateRequestFile string
@@ -87,6 +879,82 @@ func TestLoadCertificateRequest_WithErrors(t *testing.T) {
 			certificateRequestFile: "testdata/missing-outdir.yaml",
 			expectedError:          ErrMissingMandatoryField,
 		},
+		"Missing serialNumber.path": {
+			certificateRequestFile: "testdata/missing-serialnumber-path.yaml",
+			expectedError:          ErrMissingMandatoryField,
+		},
+		"Missing issuer.dir with PKCS#11": {
+			certificateRequestFile: "testdata/pkcs11-missing-issuerdir.yaml",
+			expectedError:          ErrMissingMandatoryField,
+		},
+		"Both renewBefore and renewBeforePercent": {
+			certificateRequestFile: "testdata/renewbefore-conflict.yaml",
+			expectedError:          ErrConflictingFields,
+		},
+		"CA with suppressExtensions": {
+			certificateRequestFile: "testdata/suppress-extensions-ca.yaml",
+			expectedError:          ErrConflictingFields,
+		},
+		"DER key with in-memory key": {
+			certificateRequestFile: "testdata/inmemory-key-der.yaml",
+			expectedError:          ErrConflictingFields,
+		},
+		"Negative renew before spread": {
+			certificateRequestFile: "testdata/invalid-renewspread.yaml",
+			expectedError:          ErrInvalidRenewSpread,
+		},
+		"Invalid regenerate mode": {
+			certificateRequestFile: "testdata/invalid-regenerate.yaml",
+			expectedError:          ErrInvalidRegenerate,
+		},
+		"Invalid private key format": {
+			certificateRequestFile: "testdata/invalid-privatekey-format.yaml",
+			expectedError:          ErrInvalidPrivateKeyFormat,
+		},
+		"Negative checkInterval": {
+			certificateRequestFile: "testdata/invalid-checkinterval.yaml",
+			expectedError:          ErrInvalidCheckInterval,
+		},
+		"Invalid onCorruptCert": {
+			certificateRequestFile: "testdata/invalid-oncorruptcert.yaml",
+			expectedError:          ErrInvalidOnCorruptCert,
+		},
+		"Negative maxConsecutiveFailures": {
+			certificateRequestFile: "testdata/invalid-maxfailures.yaml",
+			expectedError:          ErrInvalidMaxFailures,
+		},
+		"Server auth without SAN": {
+			certificateRequestFile: "testdata/serverauth-without-san.yaml",
+			expectedError:          ErrServerAuthWithoutSAN,
+		},
+		"Invalid renewBeforePercent": {
+			certificateRequestFile: "testdata/invalid-renewbefore-percent.yaml",
+			expectedError:          ErrInvalidRenewBeforePercent,
+		},
+		"Missing SAN file": {
+			certificateRequestFile: "testdata/missing-san-file.yaml",
+			expectedError:          ErrReadSANFile,
+		},
+		"Duplicate private key algorithm": {
+			certificateRequestFile: "testdata/privatekeys-duplicate.yaml",
+			expectedError:          ErrInvalidPrivateKeys,
+		},
+		"Both duration and notAfter": {
+			certificateRequestFile: "testdata/absolute-validity-conflict.yaml",
+			expectedError:          ErrConflictingFields,
+		},
+		"Invalid notBefore": {
+			certificateRequestFile: "testdata/invalid-notbefore.yaml",
+			expectedError:          ErrInvalidTimestamp,
+		},
+		"notAfter before notBefore": {
+			certificateRequestFile: "testdata/invalid-absolute-validity.yaml",
+			expectedError:          ErrInvalidTimestamp,
+		},
+		"Invalid layout": {
+			certificateRequestFile: "testdata/invalid-layout.yaml",
+			expectedError:          ErrInvalidLayout,
+		},
 		"Invalid extension": {
 			certificateRequestFile: "testdata/invalid.ext",
 			expectedError:          config.ErrInvalidExtension,
@@ -103,6 +971,10 @@ func TestLoadCertificateRequest_WithErrors(t *testing.T) {
 			certificateRequestFile: "testdata/invalid-extkeyusage.yaml",
 			expectedError:          ErrInvalidExtKeyUsages,
 		},
+		"Invalid QC type": {
+			certificateRequestFile: "testdata/invalid-qctypes.yaml",
+			expectedError:          ErrInvalidQCType,
+		},
 		"Invalid IP address": {
 			certificateRequestFile: "testdata/invalid-ipaddresses.yaml",
 			expectedError:          ErrInvalidIPAddress,
@@ -118,3 +990,44 @@ func TestLoadCertificateRequest_WithErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadCertificateRequest_WithAggregatedErrors(t *testing.T) {
+	viper.Reset()
+	mock(t, &config.AggregateValidationErrors, true)
+
+	_, err := LoadCertificateRequest("testdata/multiple-errors.yaml")
+
+	assert.ErrorIs(t, err, ErrMissingMandatoryField)
+	assert.ErrorIs(t, err, ErrInvalidKeyUsages)
+	assert.ErrorIs(t, err, ErrInvalidIPAddress)
+}
+
+func TestBuildCertificateRequest_WithAggregatedErrors(t *testing.T) {
+	mock(t, &config.AggregateValidationErrors, true)
+	mock(t, &Hostname, func() (string, error) { return "", errors.New("hostname error") })
+
+	_, err := BuildCertificateRequest(map[string]any{
+		"out":             map[string]any{"dir": "testdata/tls", "layout": "invalid"},
+		"commonName":      "{{ hostname }}",
+		"dnsNamesFile":    "testdata/unknown-dns-names",
+		"ipAddressesFile": "testdata/unknown-ip-addresses",
+		"keyUsages":       []string{"invalid"},
+	})
+
+	assert.ErrorIs(t, err, ErrInvalidLayout)
+	assert.ErrorIs(t, err, ErrResolveHostname)
+	assert.ErrorIs(t, err, ErrReadSANFile)
+	assert.ErrorContains(t, err, "testdata/unknown-dns-names")
+	assert.ErrorContains(t, err, "testdata/unknown-ip-addresses")
+	assert.ErrorIs(t, err, ErrInvalidKeyUsages)
+}
+
+func TestLoadCertificateRequest_WithFailFastErrors(t *testing.T) {
+	viper.Reset()
+
+	_, err := LoadCertificateRequest("testdata/multiple-errors.yaml")
+
+	assert.ErrorIs(t, err, ErrMissingMandatoryField)
+	assert.NotErrorIs(t, err, ErrInvalidKeyUsages)
+	assert.NotErrorIs(t, err, ErrInvalidIPAddress)
+}
@@ -0,0 +1,41 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var ErrWriteCertificateRequestFile = errors.New("write certificate request file")
+
+// ConvertCertificateRequest rewrites a certificate request file to the format given by the extension of out. The
+// request must be valid, and keys are written lower-cased as viper reads them case-insensitively.
+var ConvertCertificateRequest = func(in, out string) error {
+	conf, err := readCertificateRequestFile(in)
+	if err != nil {
+		return err
+	}
+	// Settings are captured before the validation sets its defaults
+	settings := conf.AllSettings()
+	if _, err := buildCertificateRequest(conf); err != nil {
+		return err
+	}
+
+	ext, err := config.GetExtension(out)
+	if err != nil {
+		return err
+	}
+	converted := viper.New()
+	converted.SetConfigType(ext)
+	if err := converted.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteCertificateRequestFile, err)
+	}
+	if err := converted.WriteConfigAs(out); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteCertificateRequestFile, err)
+	}
+	return nil
+}
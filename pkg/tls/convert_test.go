@@ -0,0 +1,61 @@
+package tls
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+func TestConvertCertificateRequest(t *testing.T) {
+	dir := t.TempDir()
+	expected, err := LoadCertificateRequest("testdata/valid.yaml")
+	require.NoError(t, err)
+
+	in := "testdata/valid.yaml"
+	for _, out := range []string{"request.toml", "request.yaml", "request.json"} {
+		out = filepath.Join(dir, out)
+
+		err := ConvertCertificateRequest(in, out)
+
+		require.NoError(t, err)
+		req, err := LoadCertificateRequest(out)
+		require.NoError(t, err)
+		assert.Equal(t, expected, req, out)
+		in = out
+	}
+}
+
+func TestConvertCertificateRequest_WithErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		in            string
+		out           string
+		expectedError error
+	}{
+		"Missing file": {
+			in:            "testdata/unknown.yaml",
+			out:           "request.json",
+			expectedError: ErrOpenCertificateRequestFile,
+		},
+		"Invalid request": {
+			in:            "testdata/invalid-checkinterval.yaml",
+			out:           "request.json",
+			expectedError: ErrInvalidCheckInterval,
+		},
+		"Unsupported format": {
+			in:            "testdata/valid.yaml",
+			out:           "request.txt",
+			expectedError: config.ErrInvalidExtension,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			err := ConvertCertificateRequest(tc.in, filepath.Join(t.TempDir(), tc.out))
+
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+const ctAddChainPath = "/ct/v1/add-chain"
+
+var (
+	ErrSubmitToCTLog = errors.New("submit to CT log")
+	ErrCTLogStatus   = errors.New("unexpected CT log status")
+)
+
+var ctHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+type SignedCertificateTimestamp struct {
+	Version    int    `json:"sct_version"`
+	LogID      string `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions string `json:"extensions"`
+	Signature  string `json:"signature"`
+}
+
+type addChainRequest struct {
+	Chain [][]byte `json:"chain"`
+}
+
+var SubmitToCTLogs = func(req CertificateRequest, cert *x509.Certificate, issuer *Issuer) ([]SignedCertificateTimestamp, error) {
+	chain := [][]byte{cert.Raw}
+	if issuer != nil {
+		chain = append(chain, issuer.PublicKey.Raw)
+	}
+
+	log := RequestLogger(req)
+	scts := make([]SignedCertificateTimestamp, 0, len(req.CTLogs))
+	for _, ctLog := range req.CTLogs {
+		sct, err := submitToCTLog(ctLog, chain)
+		if err != nil {
+			if req.CTRequired {
+				return nil, err
+			}
+			log.Warnf("Ignore CT log %s failure: %v", ctLog, err)
+			continue
+		}
+		log.Infof("SCT received from %s: id=%s timestamp=%d", ctLog, sct.LogID, sct.Timestamp)
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+func submitToCTLog(ctLog string, chain [][]byte) (SignedCertificateTimestamp, error) {
+	body, err := json.Marshal(addChainRequest{Chain: chain})
+	if err != nil {
+		return SignedCertificateTimestamp{}, fmt.Errorf(format.WrapErrors, ErrSubmitToCTLog, err)
+	}
+
+	url := strings.TrimSuffix(ctLog, "/") + ctAddChainPath
+	resp, err := ctHTTPClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return SignedCertificateTimestamp{}, fmt.Errorf(format.WrapErrors, ErrSubmitToCTLog, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return SignedCertificateTimestamp{}, fmt.Errorf(format.WrapErrorInt, ErrCTLogStatus, resp.StatusCode)
+	}
+
+	var sct SignedCertificateTimestamp
+	if err := json.NewDecoder(resp.Body).Decode(&sct); err != nil {
+		return SignedCertificateTimestamp{}, fmt.Errorf(format.WrapErrors, ErrSubmitToCTLog, err)
+	}
+	return sct, nil
+}
@@ -0,0 +1,67 @@
+package tls
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitToCTLogs(t *testing.T) {
+	cert := testCert(t)
+	var received addChainRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ctAddChainPath, r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		_, _ = w.Write([]byte(`{"sct_version":0,"id":"bG9nLWlk","timestamp":1234,"extensions":"","signature":"c2ln"}`))
+	}))
+	defer server.Close()
+	out := loggerOutput()
+
+	scts, err := SubmitToCTLogs(CertificateRequest{CTLogs: []string{server.URL}}, cert, &Issuer{PublicKey: cert})
+
+	require.NoError(t, err)
+	assert.Equal(t, []SignedCertificateTimestamp{{LogID: "bG9nLWlk", Timestamp: 1234, Signature: "c2ln"}}, scts)
+	assert.Equal(t, [][]byte{cert.Raw, cert.Raw}, received.Chain)
+	assert.Equal(t, []string{`level=info msg="SCT received from ` + server.URL + `: id=bG9nLWlk timestamp=1234"`}, splitLogLines(out))
+}
+
+func TestSubmitToCTLogs_WithError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	for name, tt := range map[string]struct {
+		ctRequired    bool
+		expectedError error
+		expectedLogs  []string
+	}{
+		"CT not required": {
+			expectedLogs: []string{`level=warning msg="Ignore CT log ` + server.URL + ` failure: unexpected CT log status: 503"`},
+		},
+		"CT required": {
+			ctRequired:    true,
+			expectedError: ErrCTLogStatus,
+			expectedLogs:  []string{""},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+
+			scts, err := SubmitToCTLogs(CertificateRequest{CTLogs: []string{server.URL}, CTRequired: tc.ctRequired}, testCert(t), nil)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Empty(t, scts)
+			}
+			assert.Equal(t, tc.expectedLogs, splitLogLines(out))
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+const (
+	DevCAName       = "uCerts development CA"
+	DevCADuration   = 365 * 24 * time.Hour
+	DevCertDuration = 90 * 24 * time.Hour
+)
+
+var ErrGenerateDevCerts = errors.New("generate development certificates")
+
+// GenerateDevCerts generates in dir a throwaway CA and a server certificate signed by it for local TLS, without any
+// certificate request file. The server files are tls.crt, tls.key and ca.crt, while the CA key stays apart in the ca
+// subdirectory.
+var GenerateDevCerts = func(dir string, dnsNames, ipAddresses []string) error {
+	caDir := filepath.Join(dir, "ca")
+	caValues := map[string]any{
+		"role":       RoleCA,
+		"commonName": DevCAName,
+		"duration":   DevCADuration.String(),
+		"out":        map[string]any{"dir": caDir, "cert": "ca.crt", "key": "ca.key"},
+		"privateKey": map[string]any{"algorithm": ECDSA},
+	}
+	commonName := "localhost"
+	if len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	} else if len(ipAddresses) > 0 {
+		commonName = ipAddresses[0]
+	}
+	serverValues := map[string]any{
+		"role":        RoleServer,
+		"commonName":  commonName,
+		"dnsNames":    dnsNames,
+		"ipAddresses": ipAddresses,
+		"duration":    DevCertDuration.String(),
+		"out":         map[string]any{"dir": dir},
+		"issuer":      map[string]any{"dir": caDir},
+		"privateKey":  map[string]any{"algorithm": ECDSA},
+	}
+
+	for _, values := range []map[string]any{caValues, serverValues} {
+		req, err := BuildCertificateRequest(values)
+		if err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrGenerateDevCerts, err)
+		}
+		issuer, err := LoadIssuer(req.IssuerPath)
+		if err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrGenerateDevCerts, err)
+		}
+		if err := MakeParentsDirectories(req.OutCertPath); err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrGenerateDevCerts, err)
+		}
+		if !generate(req, issuer) {
+			return fmt.Errorf(format.WrapErrorString, ErrGenerateDevCerts, req.OutCertPath)
+		}
+	}
+	return nil
+}
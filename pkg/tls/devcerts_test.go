@@ -0,0 +1,53 @@
+package tls
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateDevCerts(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := filepath.Join(t.TempDir(), "certs")
+
+	err := GenerateDevCerts(dir, []string{"localhost", "app.local"}, []string{"127.0.0.1"})
+
+	require.NoError(t, err)
+	ca, err := LoadCertFromFile(filepath.Join(dir, "ca.crt"))
+	require.NoError(t, err)
+	assert.True(t, ca.IsCA)
+	assert.Equal(t, DevCAName, ca.Subject.CommonName)
+	assert.FileExists(t, filepath.Join(dir, "ca", "ca.key"))
+	assert.FileExists(t, filepath.Join(dir, "tls.key"))
+	cert, err := LoadCertFromFile(filepath.Join(dir, "tls.crt"))
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", cert.Subject.CommonName)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, name := range []string{"localhost", "app.local", "127.0.0.1"} {
+		_, err = cert.Verify(x509.VerifyOptions{DNSName: name, Roots: roots})
+		assert.NoError(t, err, name)
+	}
+	key, err := LoadPrivateKeyFromFile(filepath.Join(dir, "tls.key"))
+	require.NoError(t, err)
+	assert.Equal(t, publicKey(key), cert.PublicKey)
+}
+
+func TestGenerateDevCerts_WithoutSAN(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := t.TempDir()
+
+	err := GenerateDevCerts(dir, nil, nil)
+
+	assert.ErrorIs(t, err, ErrGenerateDevCerts)
+	assert.ErrorIs(t, err, ErrServerAuthWithoutSAN)
+	_, err = os.Stat(filepath.Join(dir, "tls.crt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,56 @@
+package tls
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+)
+
+// DiffRequests returns the human-readable differences between two certificate requests,
+// one line per changed field, in the form "field: old -> new".
+func DiffRequests(a, b CertificateRequest) []string {
+	fields := []struct {
+		name string
+		a, b any
+	}{
+		{KeyCommonName, a.CommonName, b.CommonName},
+		{KeyIsCA, a.IsCA, b.IsCA},
+		{KeyCountries, a.Countries, b.Countries},
+		{KeyOrganizations, a.Organizations, b.Organizations},
+		{KeyOrganizationalUnits, a.OrganizationalUnits, b.OrganizationalUnits},
+		{KeyLocalities, a.Localities, b.Localities},
+		{KeyProvinces, a.Provinces, b.Provinces},
+		{KeyStreetAddresses, a.StreetAddresses, b.StreetAddresses},
+		{KeyPostalCodes, a.PostalCodes, b.PostalCodes},
+		{KeyDNSNames, a.DNSNames, b.DNSNames},
+		{KeyIPAddresses, ipStrings(a.IPAddresses), ipStrings(b.IPAddresses)},
+		{KeyDuration, a.Duration, b.Duration},
+		{KeyRenewBefore, a.RenewBefore, b.RenewBefore},
+		{KeyPrivateKeyAlgorithm, a.PrivateKey.Algorithm, b.PrivateKey.Algorithm},
+		{KeyPrivateKeySize, a.PrivateKey.Size, b.PrivateKey.Size},
+	}
+
+	var diffs []string
+	for _, f := range fields {
+		if isEmpty(f.a) && isEmpty(f.b) || reflect.DeepEqual(f.a, f.b) {
+			continue
+		}
+		diffs = append(diffs, fmt.Sprintf("%s: %v -> %v", f.name, f.a, f.b))
+	}
+	return diffs
+}
+
+// isEmpty treats nil and empty slices alike so that they are not reported as a difference.
+func isEmpty(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Slice && rv.Len() == 0
+}
+
+// ipStrings compares addresses by their textual form, parsed certificates hold 4-byte IPv4 addresses.
+func ipStrings(ips []net.IP) []string {
+	s := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+	return s
+}
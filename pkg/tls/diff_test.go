@@ -0,0 +1,68 @@
+package tls
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRequests(t *testing.T) {
+	base := CertificateRequest{
+		CommonName:  "test",
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		Duration:    24 * time.Hour,
+		PrivateKey:  PrivateKey{Algorithm: RSA, Size: 2048},
+	}
+
+	for name, tt := range map[string]struct {
+		change   func(req *CertificateRequest)
+		expected []string
+	}{
+		"Identical requests": {
+			change:   func(_ *CertificateRequest) {},
+			expected: nil,
+		},
+		"Nil and empty slices": {
+			change:   func(req *CertificateRequest) { req.Countries = []string{} },
+			expected: nil,
+		},
+		"Changed SANs": {
+			change: func(req *CertificateRequest) {
+				req.DNSNames = []string{"localhost", "example.com"}
+				req.IPAddresses = []net.IP{net.IPv6loopback}
+			},
+			expected: []string{
+				"dnsNames: [localhost] -> [localhost example.com]",
+				"ipAddresses: [127.0.0.1] -> [::1]",
+			},
+		},
+		"Changed duration": {
+			change:   func(req *CertificateRequest) { req.Duration = 48 * time.Hour },
+			expected: []string{"duration: 24h0m0s -> 48h0m0s"},
+		},
+		"Changed subject and key params": {
+			change: func(req *CertificateRequest) {
+				req.CommonName = "other"
+				req.Organizations = []string{"uCerts"}
+				req.PrivateKey = PrivateKey{Algorithm: ECDSA, Size: 256}
+			},
+			expected: []string{
+				"commonName: test -> other",
+				"subject.organizations: [] -> [uCerts]",
+				"privateKey.algorithm: rsa -> ecdsa",
+				"privateKey.size: 2048 -> 256",
+			},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			other := base
+			tc.change(&other)
+
+			assert.Equal(t, tc.expected, DiffRequests(base, other))
+		})
+	}
+}
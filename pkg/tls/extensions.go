@@ -0,0 +1,67 @@
+package tls
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net"
+)
+
+var (
+	OIDExtensionSubjectAltName   = asn1.ObjectIdentifier{2, 5, 29, 17}
+	OIDExtensionOCSPNoCheck      = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+	OIDExtensionBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+	OIDExtensionTLSFeature       = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+)
+
+const (
+	nameTypeDNS = 2
+	nameTypeIP  = 7
+
+	tlsFeatureStatusRequest = 5
+)
+
+// subjectAltNameExtension encodes the SAN extension like crypto/x509 does, but lets the caller choose its criticality.
+func subjectAltNameExtension(dnsNames []string, ipAddresses []net.IP, critical bool) (pkix.Extension, error) {
+	var rawValues []asn1.RawValue
+	for _, name := range dnsNames {
+		rawValues = append(rawValues, asn1.RawValue{Tag: nameTypeDNS, Class: asn1.ClassContextSpecific, Bytes: []byte(name)})
+	}
+	for _, ip := range ipAddresses {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+		rawValues = append(rawValues, asn1.RawValue{Tag: nameTypeIP, Class: asn1.ClassContextSpecific, Bytes: ip})
+	}
+	value, err := asn1.Marshal(rawValues)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: OIDExtensionSubjectAltName, Critical: critical, Value: value}, nil
+}
+
+// leafBasicConstraintsExtension encodes the critical basic constraints of an end-entity certificate, so that the
+// criticality does not depend on the crypto/x509 defaults. The cA boolean, false by default, is omitted in DER.
+func leafBasicConstraintsExtension() (pkix.Extension, error) {
+	value, err := asn1.Marshal(struct {
+		IsCA bool `asn1:"optional"`
+	}{})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: OIDExtensionBasicConstraints, Critical: true, Value: value}, nil
+}
+
+// ocspNoCheckExtension tells relying parties not to check the revocation status of a delegated OCSP responder (RFC 6960).
+func ocspNoCheckExtension() pkix.Extension {
+	return pkix.Extension{Id: OIDExtensionOCSPNoCheck, Value: asn1.NullBytes}
+}
+
+// tlsFeatureExtension requires the TLS clients to get a stapled OCSP response from the server, a.k.a. must-staple
+// (RFC 7633). The value is a sequence of TLS extension types, status_request being the only one defined for it.
+func tlsFeatureExtension() (pkix.Extension, error) {
+	value, err := asn1.Marshal([]int{tlsFeatureStatusRequest})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: OIDExtensionTLSFeature, Value: value}, nil
+}
@@ -1,11 +1,14 @@
 package tls
 
 import (
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -15,31 +18,89 @@ import (
 var (
 	ErrLoadIssuerKeyPair      = errors.New("load issuer key pair")
 	ErrParseIssuerCertificate = errors.New("parse issuer certificate")
+	ErrIssuerNotCA            = errors.New("issuer certificate is not a CA")
 	ErrCreateFile             = errors.New("create file")
 	ErrReadFile               = errors.New("read file")
 	ErrParseCertificate       = errors.New("parse certificate")
+	ErrParsePrivateKey        = errors.New("parse private key")
 	ErrEncode                 = errors.New("encode")
 	ErrReadDir                = errors.New("read directory")
+	ErrCreateDirectory        = errors.New("create directory")
 )
 
+// FileSystem is the minimal set of file operations behind WritePemToFile, WritePemBlocksToFile, MakeParentsDirectories,
+// LoadCertFromFile, LoadPrivateKeyFromFile, FileDoesNotExists and the staging of the output files.
+type FileSystem interface {
+	Create(name string) (io.WriteCloser, error)
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (fs.FileInfo, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	MkdirTemp(dir, pattern string) (string, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	RemoveAll(path string) error
+}
+
+// FS is the file system in use, replaced by tests to run without the disk.
+var FS FileSystem = osFileSystem{}
+
+type osFileSystem struct{}
+
+func (osFileSystem) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (osFileSystem) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (osFileSystem) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+
+func (osFileSystem) MkdirTemp(dir, pattern string) (string, error) { return os.MkdirTemp(dir, pattern) }
+
+func (osFileSystem) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+func (osFileSystem) Remove(name string) error { return os.Remove(name) }
+
+func (osFileSystem) RemoveAll(path string) error { return os.RemoveAll(path) }
+
 var LoadIssuer = func(path IssuerPath) (*Issuer, error) {
+	if path.PublicKeyEnv != "" && path.PrivateKeyEnv != "" {
+		return loadEnvIssuer(path)
+	}
 	if path.PublicKey == "" || path.PrivateKey == "" {
 		return nil, nil
 	}
+	if path.PKCS11.Module != "" {
+		return loadPKCS11Issuer(path)
+	}
 	rootCA, err := tls.LoadX509KeyPair(path.PublicKey, path.PrivateKey)
 	if err != nil {
 		return nil, fmt.Errorf(format.WrapErrors, ErrLoadIssuerKeyPair, err)
 	}
+	return issuerFromKeyPair(rootCA)
+}
+
+func issuerFromKeyPair(rootCA tls.Certificate) (*Issuer, error) {
 	caKey := rootCA.PrivateKey
 	ca, err := x509.ParseCertificate(rootCA.Certificate[0])
 	if err != nil {
 		return nil, fmt.Errorf(format.WrapErrors, ErrParseIssuerCertificate, err)
 	}
+	if err := checkIssuerIsCA(ca); err != nil {
+		return nil, err
+	}
 	return &Issuer{PublicKey: ca, PrivateKey: caKey}, nil
 }
 
+// checkIssuerIsCA prevents signing with a leaf certificate, which would produce certificates failing path validation.
+func checkIssuerIsCA(ca *x509.Certificate) error {
+	if !ca.IsCA || ca.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf(format.WrapErrorString, ErrIssuerNotCA, ca.Subject.String())
+	}
+	return nil
+}
+
 var WritePemToFile = func(b *pem.Block, file string) error {
-	pemFile, err := os.Create(file)
+	pemFile, err := FS.Create(file)
 	if err != nil {
 		return fmt.Errorf(format.WrapErrors, ErrCreateFile, err)
 	}
@@ -51,8 +112,22 @@ var WritePemToFile = func(b *pem.Block, file string) error {
 	return nil
 }
 
+var WritePemBlocksToFile = func(blocks []*pem.Block, file string) error {
+	pemFile, err := FS.Create(file)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrCreateFile, err)
+	}
+	defer func() { _ = pemFile.Close() }()
+	for _, b := range blocks {
+		if err := pem.Encode(pemFile, b); err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrEncode, err)
+		}
+	}
+	return nil
+}
+
 var LoadCertFromFile = func(file string) (*x509.Certificate, error) {
-	b, err := os.ReadFile(file)
+	b, err := FS.ReadFile(file)
 	if err != nil {
 		return nil, fmt.Errorf(format.WrapErrors, ErrReadFile, err)
 	}
@@ -70,6 +145,35 @@ var LoadCertFromFile = func(file string) (*x509.Certificate, error) {
 	return x509Cert, nil
 }
 
+var LoadPrivateKeyFromFile = func(file string) (crypto.PrivateKey, error) {
+	b, err := FS.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrReadFile, err)
+	}
+
+	keyPEMBlock, _ := pem.Decode(b)
+	if keyPEMBlock == nil {
+		return nil, ErrInvalidPEMBlock
+	}
+
+	var key crypto.PrivateKey
+	switch keyPEMBlock.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(keyPEMBlock.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(keyPEMBlock.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(keyPEMBlock.Bytes)
+	default:
+		return nil, ErrInvalidPEMBlock
+	}
+	if err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrParsePrivateKey, err)
+	}
+
+	return key, nil
+}
+
 var ReadDir = func(dir string) ([]string, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -85,17 +189,14 @@ var ReadDir = func(dir string) ([]string, error) {
 	return files, nil
 }
 
-var MakeParentsDirectories = func(path string) bool {
-	dir := filepath.Dir(path)
-	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return false
-		}
+var MakeParentsDirectories = func(path string) error {
+	if err := FS.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrCreateDirectory, err)
 	}
-	return true
+	return nil
 }
 
 var FileDoesNotExists = func(file string) bool {
-	_, err := os.Stat(file)
-	return errors.Is(err, os.ErrNotExist)
+	_, err := FS.Stat(file)
+	return errors.Is(err, fs.ErrNotExist)
 }
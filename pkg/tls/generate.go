@@ -0,0 +1,59 @@
+package tls
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var (
+	ErrWritePEM       = errors.New("write PEM")
+	ErrGenerateFailed = errors.New("generation failed")
+)
+
+// GenerateToWriter generates the certificate of the request, and its key when withKey is set, and writes them as PEM
+// to w instead of the out files. The key is generated in memory and never written to disk.
+var GenerateToWriter = func(req CertificateRequest, issuer *Issuer, w io.Writer, withKey bool) error {
+	for _, variant := range KeyVariants(req) {
+		key, keyBlock, err := newPrivateKey(variant)
+		if err != nil {
+			return err
+		}
+		cert, err := newCertificate(variant, key, issuer)
+		if err != nil {
+			return err
+		}
+		blocks := []*pem.Block{CertificatePEMBlock(cert, variant.OutPEMHeaders)}
+		if withKey {
+			blocks = append(blocks, keyBlock)
+		}
+		for _, block := range blocks {
+			if err := pem.Encode(w, block); err != nil {
+				return fmt.Errorf(format.WrapErrors, ErrWritePEM, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GenerateFiles generates the out files of every key variant of the request, whatever the existing certificates, and
+// fails when any of them could not be generated.
+var GenerateFiles = func(req CertificateRequest, issuer *Issuer) error {
+	var failed []string
+	for _, variant := range KeyVariants(req) {
+		if err := MakeParentsDirectories(variant.OutCertPath); err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrGenerateFailed, err)
+		}
+		if !generate(variant, issuer) {
+			failed = append(failed, variant.OutCertPath)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf(format.WrapErrorString, ErrGenerateFailed, strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateToWriter(t *testing.T) {
+	for name, tt := range map[string]struct {
+		withKey        bool
+		expectedBlocks []string
+	}{
+		"Certificate only":     {withKey: false, expectedBlocks: []string{"CERTIFICATE"}},
+		"Certificate with key": {withKey: true, expectedBlocks: []string{"CERTIFICATE", "PRIVATE KEY"}},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			req := CertificateRequest{
+				OutCertPath: filepath.Join(dir, "tls.crt"),
+				OutKeyPath:  filepath.Join(dir, "tls.key"),
+				CommonName:  "test",
+				Duration:    time.Hour,
+				DNSNames:    []string{"localhost"},
+				PrivateKey:  PrivateKey{Algorithm: ED25519},
+			}
+			issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+			require.NoError(t, err)
+			var stdout bytes.Buffer
+
+			err = GenerateToWriter(req, issuer, &stdout, tc.withKey)
+
+			require.NoError(t, err)
+			var blocks []*pem.Block
+			for block, rest := pem.Decode(stdout.Bytes()); block != nil; block, rest = pem.Decode(rest) {
+				blocks = append(blocks, block)
+			}
+			require.Len(t, blocks, len(tc.expectedBlocks))
+			for i, expectedType := range tc.expectedBlocks {
+				assert.Equal(t, expectedType, blocks[i].Type)
+			}
+			cert, err := x509.ParseCertificate(blocks[0].Bytes)
+			require.NoError(t, err)
+			assert.Equal(t, "test", cert.Subject.CommonName)
+			assert.NoError(t, cert.CheckSignatureFrom(issuer.PublicKey))
+			assert.True(t, FileDoesNotExists(req.OutCertPath))
+			assert.True(t, FileDoesNotExists(req.OutKeyPath))
+		})
+	}
+}
+
+func TestGenerateToWriter_WithError(t *testing.T) {
+	req := CertificateRequest{CommonName: "test", Duration: time.Hour, PrivateKey: PrivateKey{Algorithm: ED25519}}
+
+	err := GenerateToWriter(req, nil, failingWriter{}, false)
+
+	assert.ErrorIs(t, err, ErrWritePEM)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(_ []byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func TestGenerateFiles(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCertPath: filepath.Join(dir, "sub", "tls.crt"),
+		OutKeyPath:  filepath.Join(dir, "sub", "tls.key"),
+		CommonName:  "test",
+		Duration:    time.Hour,
+		PrivateKeys: []PrivateKey{{Algorithm: ECDSA}, {Algorithm: ED25519}},
+	}
+
+	err := GenerateFiles(req, nil)
+
+	require.NoError(t, err)
+	for _, variant := range KeyVariants(req) {
+		assert.FileExists(t, variant.OutCertPath)
+		assert.FileExists(t, variant.OutKeyPath)
+	}
+}
+
+func TestGenerateFiles_WithFailure(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCertPath:   filepath.Join(dir, "tls.crt"),
+		OutKeyPath:    filepath.Join(dir, "tls.key"),
+		CommonName:    "test",
+		Duration:      time.Hour,
+		RequireIssuer: true,
+	}
+
+	err := GenerateFiles(req, nil)
+
+	assert.ErrorIs(t, err, ErrGenerateFailed)
+	assert.ErrorContains(t, err, req.OutCertPath)
+	assert.NoFileExists(t, req.OutCertPath)
+}
@@ -0,0 +1,85 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var (
+	ErrResolveHostname   = errors.New("resolve hostname")
+	ErrUnresolvedDNSName = errors.New("unresolved DNS name")
+)
+
+var hostnamePlaceholder = regexp.MustCompile(`\{\{\s*(hostname|fqdn)\s*}}`)
+
+var Hostname = os.Hostname
+
+var LookupHost = net.LookupHost
+
+var LookupFQDN = func(hostname string) (string, error) {
+	cname, err := net.LookupCNAME(hostname)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cname, "."), nil
+}
+
+// resolveHostnamePlaceholders replaces {{ hostname }} with the short host name and {{ fqdn }} with the fully qualified one.
+func resolveHostnamePlaceholders(s string) (string, error) {
+	if !hostnamePlaceholder.MatchString(s) {
+		return s, nil
+	}
+
+	hostname, err := Hostname()
+	if err != nil {
+		return "", fmt.Errorf(format.WrapErrors, ErrResolveHostname, err)
+	}
+
+	var resolveErr error
+	resolved := hostnamePlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		if hostnamePlaceholder.FindStringSubmatch(placeholder)[1] == "hostname" {
+			short, _, _ := strings.Cut(hostname, ".")
+			return short
+		}
+		if strings.Contains(hostname, ".") {
+			return hostname
+		}
+		fqdn, err := LookupFQDN(hostname)
+		if err != nil {
+			resolveErr = fmt.Errorf(format.WrapErrors, ErrResolveHostname, err)
+		}
+		return fqdn
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+// unresolvedDNSNames looks up the DNS names, wildcards excepted, and returns those which do not exist. A network failure
+// does not tell whether a name exists, so it is only logged.
+func unresolvedDNSNames(dnsNames []string) []string {
+	var unresolved []string
+	for _, name := range dnsNames {
+		if strings.HasPrefix(name, "*.") {
+			continue
+		}
+		if _, err := LookupHost(name); err != nil {
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				unresolved = append(unresolved, name)
+				continue
+			}
+			logrus.Warnf("Failed to check that DNS name %s resolves: %v", name, err)
+		}
+	}
+	return unresolved
+}
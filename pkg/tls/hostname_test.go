@@ -0,0 +1,134 @@
+package tls
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveHostnamePlaceholders(t *testing.T) {
+	for name, tt := range map[string]struct {
+		value    string
+		hostname string
+		expected string
+	}{
+		"No placeholder":             {value: "test", hostname: "node1.example.com", expected: "test"},
+		"Short name from FQDN":       {value: "{{ hostname }}", hostname: "node1.example.com", expected: "node1"},
+		"FQDN":                       {value: "{{ fqdn }}", hostname: "node1.example.com", expected: "node1.example.com"},
+		"FQDN looked up":             {value: "{{fqdn}}", hostname: "node1", expected: "node1.lookup.example.com"},
+		"Placeholder within a value": {value: "svc.{{ hostname }}.local", hostname: "node1.example.com", expected: "svc.node1.local"},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			mock(t, &Hostname, func() (string, error) { return tc.hostname, nil })
+			mock(t, &LookupFQDN, func(hostname string) (string, error) { return hostname + ".lookup.example.com", nil })
+
+			actual, err := resolveHostnamePlaceholders(tc.value)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestResolveHostnamePlaceholders_WithErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		value      string
+		hostname   func() (string, error)
+		lookupFQDN func(string) (string, error)
+	}{
+		"Hostname error": {
+			value:      "{{ hostname }}",
+			hostname:   func() (string, error) { return "", errors.New("hostname error") },
+			lookupFQDN: func(_ string) (string, error) { return "", nil },
+		},
+		"Lookup error": {
+			value:      "{{ fqdn }}",
+			hostname:   func() (string, error) { return "node1", nil },
+			lookupFQDN: func(_ string) (string, error) { return "", errors.New("lookup error") },
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			mock(t, &Hostname, tc.hostname)
+			mock(t, &LookupFQDN, tc.lookupFQDN)
+
+			_, err := resolveHostnamePlaceholders(tc.value)
+
+			assert.ErrorIs(t, err, ErrResolveHostname)
+		})
+	}
+}
+
+func TestLoadCertificateRequest_WithHostnameCommonName(t *testing.T) {
+	viper.Reset()
+	mock(t, &Hostname, func() (string, error) { return "node1.example.com", nil })
+
+	actual, err := LoadCertificateRequest("testdata/hostname.yaml")
+
+	require.NoError(t, err)
+	assert.Equal(t, "node1.example.com", actual.CommonName)
+}
+
+func TestBuildCertificateRequest_WithValidateDNSResolves(t *testing.T) {
+	for name, tt := range map[string]struct {
+		dnsNames     []any
+		strict       bool
+		expectedErr  error
+		expectedLogs string
+	}{
+		"Resolving names": {
+			dnsNames: []any{"resolves.example.com", "*.example.com"},
+		},
+		"Non-resolving name warns": {
+			dnsNames:     []any{"resolves.example.com", "typo.example.com"},
+			expectedLogs: `level=warning msg="DNS names do not resolve: typo.example.com"`,
+		},
+		"Non-resolving name fails when strict": {
+			dnsNames:    []any{"resolves.example.com", "typo.example.com"},
+			strict:      true,
+			expectedErr: ErrUnresolvedDNSName,
+		},
+		"Network failure only warns when strict": {
+			dnsNames:     []any{"timeout.example.com"},
+			strict:       true,
+			expectedLogs: `level=warning msg="Failed to check that DNS name timeout.example.com resolves: lookup timeout.example.com: i/o timeout"`,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			out := loggerOutput()
+			mock(t, &LookupHost, func(host string) ([]string, error) {
+				switch host {
+				case "resolves.example.com":
+					return []string{"192.0.2.1"}, nil
+				case "timeout.example.com":
+					return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+				}
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			})
+			values := map[string]any{
+				"out":                       map[string]any{"dir": "testdata/tls"},
+				"commonName":                "test",
+				"dnsNames":                  tc.dnsNames,
+				"validateDNSResolves":       true,
+				"validateDNSResolvesStrict": tc.strict,
+			}
+
+			_, err := BuildCertificateRequest(values)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedLogs, strings.TrimSuffix(out.String(), "\n"))
+		})
+	}
+}
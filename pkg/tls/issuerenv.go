@@ -0,0 +1,47 @@
+package tls
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var (
+	ErrMissingIssuerEnv = errors.New("missing issuer environment variable")
+	ErrDecodeIssuerEnv  = errors.New("decode issuer environment variable")
+)
+
+// loadEnvIssuer loads the issuer from the base64 encoded PEM certificate and key held by the environment variables
+// named by the request, as injected in containers, without reading any file.
+func loadEnvIssuer(path IssuerPath) (*Issuer, error) {
+	certPEM, err := decodeIssuerEnv(path.PublicKeyEnv)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := decodeIssuerEnv(path.PrivateKeyEnv)
+	if err != nil {
+		return nil, err
+	}
+	rootCA, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrLoadIssuerKeyPair, err)
+	}
+	return issuerFromKeyPair(rootCA)
+}
+
+func decodeIssuerEnv(name string) ([]byte, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil, fmt.Errorf(format.WrapErrorString, ErrMissingIssuerEnv, name)
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrDecodeIssuerEnv, name, err)
+	}
+	return b, nil
+}
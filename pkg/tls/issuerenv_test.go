@@ -0,0 +1,58 @@
+package tls
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadIssuer_FromEnv(t *testing.T) {
+	setBase64Env(t, "UCERTS_TEST_CA_CRT", "testdata/ca.crt")
+	setBase64Env(t, "UCERTS_TEST_CA_KEY", "testdata/ca.key")
+	expected, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	issuer, err := LoadIssuer(IssuerPath{PublicKeyEnv: "UCERTS_TEST_CA_CRT", PrivateKeyEnv: "UCERTS_TEST_CA_KEY"})
+
+	require.NoError(t, err)
+	assert.Equal(t, expected.PublicKey.Raw, issuer.PublicKey.Raw)
+	req := CertificateRequest{CommonName: "env", DNSNames: []string{"localhost"}}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	cert, err := GenerateCertificate(req, key, issuer)
+	require.NoError(t, err)
+	assert.NoError(t, cert.CheckSignatureFrom(expected.PublicKey))
+}
+
+func TestLoadIssuer_FromEnvWithErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		publicKey     string
+		expectedError error
+	}{
+		"Missing variable": {publicKey: "", expectedError: ErrMissingIssuerEnv},
+		"Invalid base64":   {publicKey: "not base64!", expectedError: ErrDecodeIssuerEnv},
+		"Invalid PEM":      {publicKey: base64.StdEncoding.EncodeToString([]byte("invalid")), expectedError: ErrLoadIssuerKeyPair},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("UCERTS_TEST_CA_CRT", tc.publicKey)
+			setBase64Env(t, "UCERTS_TEST_CA_KEY", "testdata/ca.key")
+
+			_, err := LoadIssuer(IssuerPath{PublicKeyEnv: "UCERTS_TEST_CA_CRT", PrivateKeyEnv: "UCERTS_TEST_CA_KEY"})
+
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
+
+func setBase64Env(t *testing.T, name, file string) {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	t.Setenv(name, base64.StdEncoding.EncodeToString(b))
+}
@@ -0,0 +1,123 @@
+package tls
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+const (
+	ManifestTypeKey       = "key"
+	ManifestTypeKeyDER    = "keyDer"
+	ManifestTypePublicKey = "publicKey"
+	ManifestTypeCert      = "cert"
+	ManifestTypeCA        = "ca"
+	ManifestTypeChain     = "chain"
+	ManifestTypeFullChain = "fullchain"
+	ManifestTypeText      = "text"
+	ManifestTypeBundle    = "bundle"
+	ManifestTypeP7B       = "p7b"
+)
+
+var ErrWriteManifest = errors.New("write manifest")
+
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Path        string     `json:"path"`
+	Type        string     `json:"type"`
+	Fingerprint string     `json:"fingerprint"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+}
+
+// BuildManifest lists the files written for the request. Key files are identified by their public key fingerprint,
+// the others by the fingerprint of the first certificate they contain.
+func BuildManifest(req CertificateRequest, key crypto.PrivateKey, cert *x509.Certificate, issuer *Issuer) (Manifest, error) {
+	var files []ManifestFile
+	if !req.OutKeyInMemory || req.OutKeyDERPath != "" || req.OutPublicKeyPath != "" {
+		keyFingerprint, err := publicKeyFingerprint(key)
+		if err != nil {
+			return Manifest{}, err
+		}
+		if !req.OutKeyInMemory {
+			files = append(files, ManifestFile{Path: req.OutKeyPath, Type: ManifestTypeKey, Fingerprint: keyFingerprint})
+		}
+		if req.OutKeyDERPath != "" {
+			files = append(files, ManifestFile{Path: req.OutKeyDERPath, Type: ManifestTypeKeyDER, Fingerprint: keyFingerprint})
+		}
+		if req.OutPublicKeyPath != "" {
+			files = append(files, ManifestFile{Path: req.OutPublicKeyPath, Type: ManifestTypePublicKey, Fingerprint: keyFingerprint})
+		}
+	}
+	files = append(files, certManifestFile(req.OutCertPath, ManifestTypeCert, cert))
+	if issuer != nil {
+		files = append(files, certManifestFile(req.OutCAPath, ManifestTypeCA, issuer.PublicKey))
+		if req.OutChainPath != "" {
+			files = append(files, certManifestFile(req.OutChainPath, ManifestTypeChain, issuer.PublicKey))
+		}
+	}
+	if req.OutFullChainPath != "" {
+		files = append(files, certManifestFile(req.OutFullChainPath, ManifestTypeFullChain, cert))
+	}
+	if req.OutTextPath != "" {
+		files = append(files, certManifestFile(req.OutTextPath, ManifestTypeText, cert))
+	}
+	if req.OutP7BPath != "" {
+		files = append(files, certManifestFile(req.OutP7BPath, ManifestTypeP7B, cert))
+	}
+	if req.OutBundlePath != "" {
+		files = append(files, certManifestFile(req.OutBundlePath, ManifestTypeBundle, cert))
+	}
+	return Manifest{Files: files}, nil
+}
+
+// WriteManifest replaces the manifest file atomically, so that a reader never sees a partial manifest.
+var WriteManifest = func(manifest Manifest, path string) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteManifest, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteManifest, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf(format.WrapErrors, ErrWriteManifest, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteManifest, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteManifest, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteManifest, err)
+	}
+	return nil
+}
+
+func certManifestFile(path, fileType string, cert *x509.Certificate) ManifestFile {
+	notAfter := cert.NotAfter
+	return ManifestFile{Path: path, Type: fileType, Fingerprint: Fingerprint(cert), NotAfter: &notAfter}
+}
+
+func publicKeyFingerprint(key crypto.PrivateKey) (string, error) {
+	b, err := x509.MarshalPKIXPublicKey(publicKey(key))
+	if err != nil {
+		return "", fmt.Errorf(format.WrapErrors, ErrWriteManifest, err)
+	}
+	sum := sha256.Sum256(b)
+	return colonHex(sum[:]), nil
+}
@@ -0,0 +1,166 @@
+package tls
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOutFilesFromRequest_WithManifest(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:       filepath.Join(dir, "ca.crt"),
+		OutCertPath:     filepath.Join(dir, "tls.crt"),
+		OutKeyPath:      filepath.Join(dir, "tls.key"),
+		OutManifestPath: filepath.Join(dir, "index.json"),
+		Duration:        time.Hour,
+	}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	key, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+	require.NoError(t, err)
+	keyFingerprint, err := publicKeyFingerprint(key)
+	require.NoError(t, err)
+	manifest := readManifest(t, req.OutManifestPath)
+	require.Len(t, manifest.Files, 3)
+	assert.Equal(t, ManifestFile{Path: req.OutKeyPath, Type: ManifestTypeKey, Fingerprint: keyFingerprint}, manifest.Files[0])
+	assertManifestCertFile(t, req.OutCertPath, ManifestTypeCert, cert, manifest.Files[1])
+	assertManifestCertFile(t, req.OutCAPath, ManifestTypeCA, issuer.PublicKey, manifest.Files[2])
+	logs := splitLogLines(out)
+	assert.Equal(t, `level=info msg="Write manifest to `+req.OutManifestPath+`"`, logs[len(logs)-1])
+}
+
+func TestGenerateOutFilesFromRequest_WithManifestAndP7B(t *testing.T) {
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:       filepath.Join(dir, "ca.crt"),
+		OutCertPath:     filepath.Join(dir, "tls.crt"),
+		OutKeyPath:      filepath.Join(dir, "tls.key"),
+		OutP7BPath:      filepath.Join(dir, "tls.p7b"),
+		OutManifestPath: filepath.Join(dir, "index.json"),
+		Duration:        time.Hour,
+	}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	manifest := readManifest(t, req.OutManifestPath)
+	require.Len(t, manifest.Files, 4)
+	assertManifestCertFile(t, req.OutP7BPath, ManifestTypeP7B, cert, manifest.Files[3])
+}
+
+func TestGenerateOutFilesFromRequest_WithManifestAndKeyDER(t *testing.T) {
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:       filepath.Join(dir, "ca.crt"),
+		OutCertPath:     filepath.Join(dir, "tls.crt"),
+		OutKeyPath:      filepath.Join(dir, "tls.key"),
+		OutKeyDERPath:   filepath.Join(dir, "tls.der"),
+		OutManifestPath: filepath.Join(dir, "index.json"),
+		Duration:        time.Hour,
+	}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	key, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+	require.NoError(t, err)
+	keyFingerprint, err := publicKeyFingerprint(key)
+	require.NoError(t, err)
+	manifest := readManifest(t, req.OutManifestPath)
+	require.Len(t, manifest.Files, 4)
+	assert.Equal(t, ManifestFile{Path: req.OutKeyDERPath, Type: ManifestTypeKeyDER, Fingerprint: keyFingerprint}, manifest.Files[1])
+}
+
+func TestGenerateOutFilesFromRequest_WithManifestRenewal(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:       filepath.Join(dir, "ca.crt"),
+		OutCertPath:     filepath.Join(dir, "tls.crt"),
+		OutKeyPath:      filepath.Join(dir, "tls.key"),
+		OutManifestPath: filepath.Join(dir, "index.json"),
+		OutStaging:      true,
+		Duration:        time.Hour,
+	}
+	GenerateOutFilesFromRequest(req, nil)
+	first := readManifest(t, req.OutManifestPath)
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	manifest := readManifest(t, req.OutManifestPath)
+	require.Len(t, manifest.Files, 2)
+	assertManifestCertFile(t, req.OutCertPath, ManifestTypeCert, cert, manifest.Files[1])
+	assert.NotEqual(t, first.Files[1].Fingerprint, manifest.Files[1].Fingerprint)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestBuildManifest_WithAllOutFiles(t *testing.T) {
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+	req := CertificateRequest{
+		OutKeyInMemory:   true,
+		OutKeyDERPath:    "tls.der",
+		OutPublicKeyPath: "tls.pub",
+		OutCertPath:      "tls.crt",
+		OutCAPath:        "ca.crt",
+		OutChainPath:     "chain.pem",
+		OutFullChainPath: "fullchain.pem",
+		OutTextPath:      "tls.txt",
+		OutP7BPath:       "tls.p7b",
+	}
+
+	manifest, err := BuildManifest(req, issuer.PrivateKey, issuer.PublicKey, issuer)
+
+	require.NoError(t, err)
+	var types []string
+	for _, file := range manifest.Files {
+		types = append(types, file.Type)
+	}
+	assert.Equal(t, []string{ManifestTypeKeyDER, ManifestTypePublicKey, ManifestTypeCert, ManifestTypeCA, ManifestTypeChain, ManifestTypeFullChain, ManifestTypeText, ManifestTypeP7B}, types)
+	assert.Nil(t, manifest.Files[0].NotAfter)
+	assert.Nil(t, manifest.Files[1].NotAfter)
+	assert.Equal(t, manifest.Files[0].Fingerprint, manifest.Files[1].Fingerprint)
+}
+
+func TestWriteManifest_WithError(t *testing.T) {
+	err := WriteManifest(Manifest{}, filepath.Join(t.TempDir(), "missing", "index.json"))
+
+	assert.ErrorIs(t, err, ErrWriteManifest)
+}
+
+func readManifest(t *testing.T, path string) Manifest {
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(b, &manifest))
+	return manifest
+}
+
+func assertManifestCertFile(t *testing.T, path, fileType string, cert *x509.Certificate, actual ManifestFile) {
+	assert.Equal(t, path, actual.Path)
+	assert.Equal(t, fileType, actual.Type)
+	assert.Equal(t, Fingerprint(cert), actual.Fingerprint)
+	require.NotNil(t, actual.NotAfter)
+	assert.True(t, cert.NotAfter.Equal(*actual.NotAfter))
+}
@@ -0,0 +1,60 @@
+package tls
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+)
+
+var (
+	OIDExtensionMSTemplateName        = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2}
+	OIDExtensionMSTemplateInformation = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 21, 7}
+
+	ErrInvalidMSTemplate = errors.New("invalid Microsoft certificate template")
+)
+
+// MSTemplate identifies the Active Directory certificate template, by its name (v1 templates) and/or by its OID and
+// version (v2 and later templates).
+type MSTemplate struct {
+	Name         string
+	OID          asn1.ObjectIdentifier
+	MajorVersion int
+	MinorVersion int
+}
+
+type msTemplateInformation struct {
+	TemplateID   asn1.ObjectIdentifier
+	MajorVersion int
+	MinorVersion int `asn1:"optional"`
+}
+
+func msTemplateExtensions(template MSTemplate) ([]pkix.Extension, error) {
+	var extensions []pkix.Extension
+	if template.Name != "" {
+		value, err := asn1.Marshal(bmpString(template.Name))
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: OIDExtensionMSTemplateName, Value: value})
+	}
+	if len(template.OID) > 0 {
+		value, err := asn1.Marshal(msTemplateInformation{TemplateID: template.OID, MajorVersion: template.MajorVersion, MinorVersion: template.MinorVersion})
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: OIDExtensionMSTemplateInformation, Value: value})
+	}
+	return extensions, nil
+}
+
+// bmpString encodes s as UTF-16BE, encoding/asn1 only decodes BMPString.
+func bmpString(s string) asn1.RawValue {
+	codes := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(codes))
+	for i, c := range codes {
+		binary.BigEndian.PutUint16(b[2*i:], c)
+	}
+	return asn1.RawValue{Tag: asn1.TagBMPString, Class: asn1.ClassUniversal, Bytes: b}
+}
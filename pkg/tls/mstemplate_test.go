@@ -0,0 +1,92 @@
+package tls
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"slices"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMSTemplateExtensions(t *testing.T) {
+	oid := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 21, 8, 1, 2, 3}
+
+	extensions, err := msTemplateExtensions(MSTemplate{Name: "WebServer", OID: oid, MajorVersion: 100, MinorVersion: 4})
+
+	require.NoError(t, err)
+	require.Len(t, extensions, 2)
+	assert.Equal(t, OIDExtensionMSTemplateName, extensions[0].Id)
+	assert.Equal(t, []byte{0x1e, 0x12, 0, 'W', 0, 'e', 0, 'b', 0, 'S', 0, 'e', 0, 'r', 0, 'v', 0, 'e', 0, 'r'}, extensions[0].Value)
+	var name string
+	_, err = asn1.Unmarshal(extensions[0].Value, &name)
+	require.NoError(t, err)
+	assert.Equal(t, "WebServer", name)
+	assert.Equal(t, OIDExtensionMSTemplateInformation, extensions[1].Id)
+	var info msTemplateInformation
+	_, err = asn1.Unmarshal(extensions[1].Value, &info)
+	require.NoError(t, err)
+	assert.Equal(t, msTemplateInformation{TemplateID: oid, MajorVersion: 100, MinorVersion: 4}, info)
+}
+
+func TestMSTemplateExtensions_WithNameOnly(t *testing.T) {
+	extensions, err := msTemplateExtensions(MSTemplate{Name: "User"})
+
+	require.NoError(t, err)
+	require.Len(t, extensions, 1)
+	assert.Equal(t, OIDExtensionMSTemplateName, extensions[0].Id)
+}
+
+func TestGenerateCertificate_WithMSTemplate(t *testing.T) {
+	viper.Reset()
+	values := map[string]any{
+		"out":        map[string]any{"dir": "testdata/tls"},
+		"commonName": "test",
+		"duration":   "1h",
+		"msTemplate": map[string]any{"name": "WebServer", "oid": "1.3.6.1.4.1.311.21.8.1.2.3", "majorVersion": 100},
+		"privateKey": map[string]any{"algorithm": "ed25519"},
+	}
+	req, err := BuildCertificateRequest(values)
+	require.NoError(t, err)
+	assert.Equal(t, MSTemplate{Name: "WebServer", OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 21, 8, 1, 2, 3}, MajorVersion: 100}, req.MSTemplate)
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	cert, err := GenerateCertificate(req, key, nil)
+
+	require.NoError(t, err)
+	for _, id := range []asn1.ObjectIdentifier{OIDExtensionMSTemplateName, OIDExtensionMSTemplateInformation} {
+		assert.True(t, slices.ContainsFunc(cert.Extensions, func(e pkix.Extension) bool { return e.Id.Equal(id) }), id.String())
+	}
+}
+
+func TestBuildCertificateRequest_WithInvalidMSTemplate(t *testing.T) {
+	for name, tt := range map[string]struct {
+		msTemplate         map[string]any
+		suppressExtensions bool
+		expectedError      error
+	}{
+		"Invalid OID":             {msTemplate: map[string]any{"oid": "invalid"}, expectedError: ErrInvalidMSTemplate},
+		"Version without OID":     {msTemplate: map[string]any{"name": "WebServer", "majorVersion": 100}, expectedError: ErrMissingMandatoryField},
+		"With suppressExtensions": {msTemplate: map[string]any{"name": "WebServer"}, suppressExtensions: true, expectedError: ErrConflictingFields},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			values := map[string]any{
+				"out":                map[string]any{"dir": "testdata/tls"},
+				"commonName":         "test",
+				"msTemplate":         tc.msTemplate,
+				"suppressExtensions": tc.suppressExtensions,
+			}
+
+			_, err := BuildCertificateRequest(values)
+
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var (
+	ErrPKCS11Unsupported       = errors.New("PKCS#11 support not available in this build")
+	ErrPKCS11LoadModule        = errors.New("load PKCS#11 module")
+	ErrPKCS11Session           = errors.New("open PKCS#11 session")
+	ErrPKCS11KeyNotFound       = errors.New("PKCS#11 key not found")
+	ErrPKCS11Sign              = errors.New("PKCS#11 sign")
+	ErrPKCS11UnsupportedSigner = errors.New("unsupported PKCS#11 signature")
+	ErrReadPKCS11Pin           = errors.New("read PKCS#11 PIN")
+)
+
+// PKCS11Config locates the issuer key in a token. The PIN is either Pin, or read from the environment variable PinEnv
+// or from the file PinFile so that it is not stored in plaintext in the request file.
+type PKCS11Config struct {
+	Module   string
+	Slot     uint
+	Pin      string
+	PinEnv   string
+	PinFile  string
+	KeyLabel string
+}
+
+func loadPKCS11Issuer(path IssuerPath) (*Issuer, error) {
+	ca, err := LoadCertFromFile(path.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrParseIssuerCertificate, err)
+	}
+	if err := checkIssuerIsCA(ca); err != nil {
+		return nil, err
+	}
+	config := path.PKCS11
+	if config.Pin, err = pkcs11Pin(config); err != nil {
+		return nil, err
+	}
+	signer, err := newPKCS11Signer(config, ca.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{PublicKey: ca, PrivateKey: signer}, nil
+}
+
+func pkcs11Pin(config PKCS11Config) (string, error) {
+	switch {
+	case config.PinEnv != "":
+		pin, ok := os.LookupEnv(config.PinEnv)
+		if !ok || pin == "" {
+			return "", fmt.Errorf(format.WrapErrorString, ErrMissingIssuerEnv, config.PinEnv)
+		}
+		return pin, nil
+	case config.PinFile != "":
+		b, err := FS.ReadFile(config.PinFile)
+		if err != nil {
+			return "", fmt.Errorf(format.WrapErrors, ErrReadPKCS11Pin, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return config.Pin, nil
+}
@@ -0,0 +1,133 @@
+//go:build cgo
+
+package tls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+const pkcs11Supported = true
+
+// DigestInfo prefixes for PKCS#1 v1.5 signatures, as CKM_RSA_PKCS signs raw data.
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+type pkcs11Signer struct {
+	config PKCS11Config
+	public crypto.PublicKey
+}
+
+func newPKCS11Signer(config PKCS11Config, public crypto.PublicKey) (crypto.Signer, error) {
+	signer := &pkcs11Signer{config: config, public: public}
+	// Check the key is reachable now rather than at signing time
+	err := signer.withKey(func(_ *pkcs11.Ctx, _ pkcs11.SessionHandle, _ pkcs11.ObjectHandle) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mechanism uint
+	data := digest
+	switch s.public.(type) {
+	case *rsa.PublicKey:
+		if _, isPSS := opts.(*rsa.PSSOptions); isPSS {
+			return nil, fmt.Errorf(format.WrapErrorString, ErrPKCS11UnsupportedSigner, "RSA-PSS")
+		}
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf(format.WrapErrorString, ErrPKCS11UnsupportedSigner, opts.HashFunc())
+		}
+		mechanism = pkcs11.CKM_RSA_PKCS
+		data = append(append([]byte{}, prefix...), digest...)
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.CKM_ECDSA
+	default:
+		return nil, fmt.Errorf(format.WrapErrorString, ErrPKCS11UnsupportedSigner, fmt.Sprintf("%T", s.public))
+	}
+
+	var signature []byte
+	err := s.withKey(func(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, key pkcs11.ObjectHandle) error {
+		if err := ctx.SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, key); err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrPKCS11Sign, err)
+		}
+		var err error
+		if signature, err = ctx.Sign(session, data); err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrPKCS11Sign, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if mechanism == pkcs11.CKM_ECDSA {
+		// PKCS#11 returns r || s whereas x509 expects an ASN.1 sequence
+		half := len(signature) / 2
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			R: new(big.Int).SetBytes(signature[:half]),
+			S: new(big.Int).SetBytes(signature[half:]),
+		})
+	}
+	return signature, nil
+}
+
+// withKey opens a dedicated session for each operation so that no HSM session outlives a certificate generation.
+func (s *pkcs11Signer) withKey(f func(*pkcs11.Ctx, pkcs11.SessionHandle, pkcs11.ObjectHandle) error) error {
+	ctx := pkcs11.New(s.config.Module)
+	if ctx == nil {
+		return fmt.Errorf(format.WrapErrorString, ErrPKCS11LoadModule, s.config.Module)
+	}
+	defer ctx.Destroy()
+	if err := ctx.Initialize(); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrPKCS11LoadModule, err)
+	}
+	defer func() { _ = ctx.Finalize() }()
+
+	session, err := ctx.OpenSession(s.config.Slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrPKCS11Session, err)
+	}
+	defer func() { _ = ctx.CloseSession(session) }()
+	if err := ctx.Login(session, pkcs11.CKU_USER, s.config.Pin); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrPKCS11Session, err)
+	}
+	defer func() { _ = ctx.Logout(session) }()
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, s.config.KeyLabel),
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrPKCS11KeyNotFound, err)
+	}
+	keys, _, err := ctx.FindObjects(session, 1)
+	_ = ctx.FindObjectsFinal(session)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrPKCS11KeyNotFound, err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf(format.WrapErrorString, ErrPKCS11KeyNotFound, s.config.KeyLabel)
+	}
+
+	return f(ctx, session, keys[0])
+}
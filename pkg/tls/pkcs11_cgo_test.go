@@ -0,0 +1,65 @@
+//go:build cgo
+
+package tls
+
+import (
+	"encoding/pem"
+	"os"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SoftHSM token expected by TestLoadIssuer_WithPKCS11, see .github/workflows/go.yml
+const (
+	testPKCS11ModuleEnv  = "UCERTS_TEST_PKCS11_MODULE"
+	testPKCS11TokenLabel = "ucerts"
+	testPKCS11Pin        = "1234"
+	testPKCS11KeyLabel   = "ca"
+)
+
+func TestLoadIssuer_WithPKCS11(t *testing.T) {
+	module := os.Getenv(testPKCS11ModuleEnv)
+	if module == "" {
+		t.Skipf("%s not set", testPKCS11ModuleEnv)
+	}
+	config := PKCS11Config{Module: module, Slot: findTestPKCS11Slot(t, module), Pin: testPKCS11Pin, KeyLabel: testPKCS11KeyLabel}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key", PKCS11: config})
+	require.NoError(t, err)
+	req := CertificateRequest{CommonName: "pkcs11"}
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	cert, err := GenerateCertificate(req, key, issuer)
+
+	require.NoError(t, err)
+	assert.NoError(t, cert.CheckSignatureFrom(issuer.PublicKey))
+}
+
+func TestLoadIssuer_WithPKCS11Error(t *testing.T) {
+	_, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key", PKCS11: PKCS11Config{Module: "unknown.so"}})
+
+	assert.ErrorIs(t, err, ErrPKCS11LoadModule)
+}
+
+func findTestPKCS11Slot(t *testing.T, module string) uint {
+	ctx := pkcs11.New(module)
+	require.NotNil(t, ctx)
+	defer ctx.Destroy()
+	require.NoError(t, ctx.Initialize())
+	defer func() { _ = ctx.Finalize() }()
+	slots, err := ctx.GetSlotList(true)
+	require.NoError(t, err)
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		require.NoError(t, err)
+		if info.Label == testPKCS11TokenLabel {
+			return slot
+		}
+	}
+	require.FailNow(t, "SoftHSM token not found", testPKCS11TokenLabel)
+	return 0
+}
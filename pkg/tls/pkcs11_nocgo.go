@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package tls
+
+import "crypto"
+
+// pkcs11Supported is false in the builds without cgo, like the released binaries: requests with an issuer.pkcs11 are
+// rejected when they are loaded.
+const pkcs11Supported = false
+
+func newPKCS11Signer(_ PKCS11Config, _ crypto.PublicKey) (crypto.Signer, error) {
+	return nil, ErrPKCS11Unsupported
+}
@@ -0,0 +1,19 @@
+//go:build !cgo
+
+package tls
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCertificateRequest_WithUnsupportedPKCS11Issuer(t *testing.T) {
+	viper.Reset()
+
+	_, err := LoadCertificateRequest("testdata/pkcs11.yaml")
+
+	assert.ErrorIs(t, err, ErrPKCS11Unsupported)
+	assert.ErrorContains(t, err, KeyIssuerPKCS11Module)
+}
@@ -0,0 +1,51 @@
+package tls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPKCS11Pin(t *testing.T) {
+	t.Setenv("UCERTS_TEST_PKCS11_PIN", "5678")
+	pinFile := filepath.Join(t.TempDir(), "pin")
+	require.NoError(t, os.WriteFile(pinFile, []byte("9012\n"), 0600))
+
+	for name, tt := range map[string]struct {
+		config   PKCS11Config
+		expected string
+	}{
+		"Plaintext": {config: PKCS11Config{Pin: "1234"}, expected: "1234"},
+		"Env":       {config: PKCS11Config{PinEnv: "UCERTS_TEST_PKCS11_PIN"}, expected: "5678"},
+		"File":      {config: PKCS11Config{PinFile: pinFile}, expected: "9012"},
+		"No PIN":    {config: PKCS11Config{}, expected: ""},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			pin, err := pkcs11Pin(tc.config)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, pin)
+		})
+	}
+}
+
+func TestPKCS11Pin_WithErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		config        PKCS11Config
+		expectedError error
+	}{
+		"Missing env":  {config: PKCS11Config{PinEnv: "UCERTS_TEST_PKCS11_MISSING_PIN"}, expectedError: ErrMissingIssuerEnv},
+		"Missing file": {config: PKCS11Config{PinFile: filepath.Join(t.TempDir(), "missing")}, expectedError: ErrReadPKCS11Pin},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			_, err := pkcs11Pin(tc.config)
+
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
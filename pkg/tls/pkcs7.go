@@ -0,0 +1,74 @@
+package tls
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var ErrWritePKCS7 = errors.New("write PKCS#7")
+
+var (
+	OIDPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OIDPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+// pkcs7SignedData is the degenerate SignedData of RFC 2315 carrying certificates only: no content, digest
+// algorithms nor signers.
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue `asn1:"optional"`
+	SignerInfos      asn1.RawValue
+}
+
+// WritePKCS7 writes the certificate followed by the issuer certificate as a PEM encoded PKCS#7 certs-only structure,
+// a.k.a. p7b, as expected by Windows and Java.
+var WritePKCS7 = func(cert *x509.Certificate, issuer *Issuer, path string) error {
+	certs := []*x509.Certificate{cert}
+	if issuer != nil {
+		certs = append(certs, issuer.PublicKey)
+	}
+	der, err := encodePKCS7(certs)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWritePKCS7, err)
+	}
+	if err := WritePemToFile(&pem.Block{Type: "PKCS7", Bytes: der}, path); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWritePKCS7, err)
+	}
+	return nil
+}
+
+func encodePKCS7(certs []*x509.Certificate) ([]byte, error) {
+	var raw []byte
+	for _, cert := range certs {
+		raw = append(raw, cert.Raw...)
+	}
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+	signedData, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo:      pkcs7ContentInfo{ContentType: OIDPKCS7Data},
+		// certificates [0] IMPLICIT SET OF Certificate
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos:  emptySet,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// content [0] EXPLICIT SignedData
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: OIDPKCS7SignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+}
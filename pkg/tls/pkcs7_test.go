@@ -0,0 +1,83 @@
+package tls
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOutFilesFromRequest_WithPKCS7(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:   filepath.Join(dir, "ca.crt"),
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		OutP7BPath:  filepath.Join(dir, "tls.p7b"),
+		Duration:    time.Hour,
+	}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	certs := decodePKCS7File(t, req.OutP7BPath)
+	require.Len(t, certs, 2)
+	assert.Equal(t, cert.Raw, certs[0].Raw)
+	assert.Equal(t, issuer.PublicKey.Raw, certs[1].Raw)
+}
+
+func TestWritePKCS7_WithoutIssuer(t *testing.T) {
+	cert, err := LoadCertFromFile("testdata/test.crt")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "tls.p7b")
+
+	err = WritePKCS7(cert, nil, path)
+
+	require.NoError(t, err)
+	certs := decodePKCS7File(t, path)
+	require.Len(t, certs, 1)
+	assert.Equal(t, cert.Raw, certs[0].Raw)
+}
+
+func TestWritePKCS7_WithError(t *testing.T) {
+	cert, err := LoadCertFromFile("testdata/test.crt")
+	require.NoError(t, err)
+
+	err = WritePKCS7(cert, nil, filepath.Join(t.TempDir(), "missing", "tls.p7b"))
+
+	assert.ErrorIs(t, err, ErrWritePKCS7)
+}
+
+func decodePKCS7File(t *testing.T, path string) []*x509.Certificate {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	block, _ := pem.Decode(b)
+	require.NotNil(t, block)
+	require.Equal(t, "PKCS7", block.Type)
+
+	var contentInfo pkcs7ContentInfo
+	_, err = asn1.Unmarshal(block.Bytes, &contentInfo)
+	require.NoError(t, err)
+	require.True(t, contentInfo.ContentType.Equal(OIDPKCS7SignedData))
+	var signedData pkcs7SignedData
+	_, err = asn1.Unmarshal(contentInfo.Content.Bytes, &signedData)
+	require.NoError(t, err)
+	assert.Equal(t, 1, signedData.Version)
+	assert.True(t, signedData.ContentInfo.ContentType.Equal(OIDPKCS7Data))
+	assert.Empty(t, signedData.SignerInfos.Bytes)
+
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	require.NoError(t, err)
+	return certs
+}
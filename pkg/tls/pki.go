@@ -1,18 +1,21 @@
 package tls
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"math/big"
+	"io"
 	"strings"
 	"time"
 
@@ -30,6 +33,8 @@ const (
 	RSA           = "rsa"
 	ECDSA         = "ecdsa"
 	ED25519       = "ed25519"
+	// PrivateKeyFormatPKCS8 encodes RSA and ECDSA keys as PKCS#8 instead of PKCS#1 and SEC 1, Ed25519 keys always are
+	PrivateKeyFormatPKCS8 = "pkcs8"
 )
 
 var (
@@ -37,14 +42,58 @@ var (
 	ErrGenerateSerialNumber           = errors.New("generate serial number")
 	ErrGenerateCert                   = errors.New("generate cert")
 	ErrCopyCA                         = errors.New("copy CA")
+	ErrWriteChain                     = errors.New("write chain")
+	ErrWriteFullChain                 = errors.New("write fullchain")
+	ErrWriteBundle                    = errors.New("write bundle")
+	ErrVerifyChain                    = errors.New("verify chain")
 	ErrRSAKeySizeTooWeak              = fmt.Errorf("RSA key size too weak, minimum is %d", MinRSAKeySize)
 	ErrRSAKeySizeTooBig               = fmt.Errorf("RSA key size too big, maximum is %d", MaxRSAKeySize)
 	ErrUnsupportedPrivateKeyAlgorithm = fmt.Errorf("unsupported private key algorithm")
 	ErrEncodePrivateKey               = fmt.Errorf("encode private key")
+	ErrWritePublicKey                 = errors.New("write public key")
+	ErrWritePrivateKeyDER             = errors.New("write DER private key")
 	ErrUnsupportedECDSAKeySize        = errors.New("unsupported ecdsa key size")
+	ErrMissingPrivateKeyHook          = errors.New("no private key hook to deliver the in-memory key")
+	ErrIssuerRequired                 = errors.New("issuer required, refuse to self-sign")
 )
 
+// randReader is the source of randomness for keys, serial numbers and signatures, only overridden by tests.
+var randReader io.Reader = rand.Reader
+
+// PrivateKeyHook receives the generated key when it must not be written to disk (out.key set to "").
+var PrivateKeyHook func(req CertificateRequest, key crypto.PrivateKey, keyPEM []byte) error
+
 var GeneratePrivateKey = func(req CertificateRequest) (crypto.PrivateKey, error) {
+	key, pemBlock, err := newPrivateKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writePrivateKey(req, key, pemBlock); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writePrivateKey writes the encoded key to out.key, or hands it to PrivateKeyHook for keys kept in memory.
+func writePrivateKey(req CertificateRequest, key crypto.PrivateKey, pemBlock *pem.Block) error {
+	if req.OutKeyInMemory {
+		if PrivateKeyHook == nil {
+			return fmt.Errorf(format.WrapErrors, ErrGenerateKey, ErrMissingPrivateKeyHook)
+		}
+		if err := PrivateKeyHook(req, key, pem.EncodeToMemory(pemBlock)); err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrGenerateKey, err)
+		}
+		return nil
+	}
+	if err := WritePemToFile(pemBlock, req.OutKeyPath); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrGenerateKey, err)
+	}
+	return nil
+}
+
+// newPrivateKey generates the key of the request and encodes it without writing it anywhere.
+func newPrivateKey(req CertificateRequest) (crypto.PrivateKey, *pem.Block, error) {
 	algorithm := req.PrivateKey.Algorithm
 	if algorithm == "" {
 		algorithm = RSA
@@ -54,6 +103,10 @@ var GeneratePrivateKey = func(req CertificateRequest) (crypto.PrivateKey, error)
 	var pemBlock *pem.Block
 	var err error
 
+	if err := checkAlgorithmPolicy(algorithm); err != nil {
+		return nil, nil, err
+	}
+
 	switch strings.ToLower(algorithm) {
 	case RSA:
 		key, pemBlock, err = generateRSAPrivateKey(req)
@@ -62,19 +115,22 @@ var GeneratePrivateKey = func(req CertificateRequest) (crypto.PrivateKey, error)
 	case ED25519:
 		key, pemBlock, err = generateEd25519PrivateKey(req)
 	default:
-		return nil, fmt.Errorf(format.WrapErrorString, ErrUnsupportedPrivateKeyAlgorithm, algorithm)
+		return nil, nil, fmt.Errorf(format.WrapErrorString, ErrUnsupportedPrivateKeyAlgorithm, algorithm)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf(format.WrapErrors, ErrGenerateKey, err)
+		return nil, nil, fmt.Errorf(format.WrapErrors, ErrGenerateKey, err)
 	}
 
-	err = WritePemToFile(pemBlock, req.OutKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf(format.WrapErrors, ErrGenerateKey, err)
+	if strings.EqualFold(req.PrivateKey.Format, PrivateKeyFormatPKCS8) && pemBlock.Type != "PRIVATE KEY" {
+		bytes, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf(format.WrapErrors, ErrGenerateKey, fmt.Errorf(format.WrapErrors, ErrEncodePrivateKey, err))
+		}
+		pemBlock = &pem.Block{Type: "PRIVATE KEY", Bytes: bytes}
 	}
 
-	return key, nil
+	return key, pemBlock, nil
 }
 
 func generateRSAPrivateKey(req CertificateRequest) (crypto.PrivateKey, *pem.Block, error) {
@@ -88,7 +144,10 @@ func generateRSAPrivateKey(req CertificateRequest) (crypto.PrivateKey, *pem.Bloc
 	if keySize > MaxRSAKeySize {
 		return nil, nil, ErrRSAKeySizeTooBig
 	}
-	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err := checkRSAKeySizePolicy(keySize); err != nil {
+		return nil, nil, err
+	}
+	key, err := rsa.GenerateKey(randReader, keySize)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -112,8 +171,11 @@ func generateECPrivateKey(req CertificateRequest) (crypto.PrivateKey, *pem.Block
 	default:
 		return nil, nil, fmt.Errorf(format.WrapErrorInt, ErrUnsupportedECDSAKeySize, keySize)
 	}
+	if err := checkECDSACurvePolicy(ecCurve.Params().Name); err != nil {
+		return nil, nil, err
+	}
 
-	key, err := ecdsa.GenerateKey(ecCurve, rand.Reader)
+	key, err := ecdsa.GenerateKey(ecCurve, randReader)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -127,7 +189,7 @@ func generateECPrivateKey(req CertificateRequest) (crypto.PrivateKey, *pem.Block
 }
 
 func generateEd25519PrivateKey(req CertificateRequest) (crypto.PrivateKey, *pem.Block, error) {
-	_, key, err := ed25519.GenerateKey(rand.Reader)
+	_, key, err := ed25519.GenerateKey(randReader)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -140,11 +202,66 @@ func generateEd25519PrivateKey(req CertificateRequest) (crypto.PrivateKey, *pem.
 	return key, &pem.Block{Type: "PRIVATE KEY", Bytes: bytes}, nil
 }
 
-var GenerateCertificate = func(req CertificateRequest, key crypto.PrivateKey, issuer *Issuer) error {
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+var WritePublicKey = func(key crypto.PrivateKey, path string) error {
+	bytes, err := x509.MarshalPKIXPublicKey(publicKey(key))
 	if err != nil {
-		return fmt.Errorf(format.WrapErrors, ErrGenerateSerialNumber, err)
+		return fmt.Errorf(format.WrapErrors, ErrWritePublicKey, err)
+	}
+	if err := WritePemToFile(&pem.Block{Type: "PUBLIC KEY", Bytes: bytes}, path); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWritePublicKey, err)
+	}
+	return nil
+}
+
+// WritePrivateKeyDER writes the raw PKCS#8 DER encoding of the key, for the consumers not reading PEM.
+var WritePrivateKeyDER = func(key crypto.PrivateKey, path string) error {
+	bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWritePrivateKeyDER, err)
+	}
+	file, err := FS.Create(path)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWritePrivateKeyDER, fmt.Errorf(format.WrapErrors, ErrCreateFile, err))
+	}
+	defer func() { _ = file.Close() }()
+	if _, err := file.Write(bytes); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWritePrivateKeyDER, err)
+	}
+	return nil
+}
+
+var GenerateCertificate = func(req CertificateRequest, key crypto.PrivateKey, issuer *Issuer) (*x509.Certificate, error) {
+	cert, err := newCertificate(req, key, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCertificate(req, cert, issuer); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// writeCertificate writes the certificate to out.cert, followed by the issuer with out.certIncludeChain.
+func writeCertificate(req CertificateRequest, cert *x509.Certificate, issuer *Issuer) error {
+	var err error
+	if req.OutCertIncludeChain && issuer != nil {
+		// For servers expecting the chain in the certificate file itself, the leaf comes first
+		blocks := []*pem.Block{CertificatePEMBlock(cert, req.OutPEMHeaders), CertificatePEMBlock(issuer.PublicKey, req.OutPEMHeaders)}
+		err = WritePemBlocksToFile(blocks, req.OutCertPath)
+	} else {
+		err = WritePemToFile(CertificatePEMBlock(cert, req.OutPEMHeaders), req.OutCertPath)
+	}
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
+	}
+	return nil
+}
+
+// newCertificate signs the certificate of the request without writing it anywhere.
+func newCertificate(req CertificateRequest, key crypto.PrivateKey, issuer *Issuer) (*x509.Certificate, error) {
+	serialNumber, err := NextSerialNumber(req.SerialNumber)
+	if err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrGenerateSerialNumber, err)
 	}
 
 	// All certificates should have the DigitalSignature KeyUsage bits set.
@@ -159,8 +276,16 @@ var GenerateCertificate = func(req CertificateRequest, key crypto.PrivateKey, is
 	if req.IsCA {
 		keyUsage |= x509.KeyUsageCertSign
 	}
+	keyUsage |= req.KeyUsage
 
 	notBefore := time.Now()
+	if !req.NotBefore.IsZero() {
+		notBefore = req.NotBefore
+	}
+	notAfter := notBefore.Add(req.Duration)
+	if !req.NotAfter.IsZero() {
+		notAfter = req.NotAfter
+	}
 	template := &x509.Certificate{
 		Subject: pkix.Name{
 			CommonName:         req.CommonName,
@@ -175,14 +300,72 @@ var GenerateCertificate = func(req CertificateRequest, key crypto.PrivateKey, is
 		SerialNumber:          serialNumber,
 		IsCA:                  req.IsCA,
 		NotBefore:             notBefore,
-		NotAfter:              notBefore.Add(req.Duration),
+		NotAfter:              notAfter,
 		KeyUsage:              keyUsage,
 		ExtKeyUsage:           req.ExtKeyUsage,
+		UnknownExtKeyUsage:    req.UnknownExtKeyUsage,
 		DNSNames:              req.DNSNames,
 		IPAddresses:           req.IPAddresses,
 		BasicConstraintsValid: true,
 	}
 
+	if req.SuppressExtensions {
+		// Debugging option for test harnesses: crypto/x509 still encodes a v3 certificate, but without SANs, usages nor
+		// basic constraints. Only the authority key identifier of an issuer having a subject key identifier remains.
+		template.KeyUsage = 0
+		template.ExtKeyUsage = nil
+		template.UnknownExtKeyUsage = nil
+		template.DNSNames = nil
+		template.IPAddresses = nil
+		template.BasicConstraintsValid = false
+	}
+
+	if req.SANCritical && len(template.DNSNames)+len(template.IPAddresses) > 0 {
+		// crypto/x509 does not generate the SAN extension when it is already part of ExtraExtensions
+		extension, err := subjectAltNameExtension(req.DNSNames, req.IPAddresses, true)
+		if err != nil {
+			return nil, fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, extension)
+	}
+
+	if req.BCCritical && !req.IsCA {
+		// crypto/x509 does not generate the basic constraints extension when it is already part of ExtraExtensions
+		extension, err := leafBasicConstraintsExtension()
+		if err != nil {
+			return nil, fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, extension)
+	}
+
+	if req.OCSPNoCheck {
+		template.ExtraExtensions = append(template.ExtraExtensions, ocspNoCheckExtension())
+	}
+
+	if req.MustStaple {
+		extension, err := tlsFeatureExtension()
+		if err != nil {
+			return nil, fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, extension)
+	}
+
+	if req.QCStatements.Enable {
+		extension, err := qcStatementsExtension(req.QCStatements)
+		if err != nil {
+			return nil, fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, extension)
+	}
+
+	if req.MSTemplate.Name != "" || len(req.MSTemplate.OID) > 0 {
+		extensions, err := msTemplateExtensions(req.MSTemplate)
+		if err != nil {
+			return nil, fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, extensions...)
+	}
+
 	// Default is selfsigned
 	issuerCert := template
 	signerKey := key
@@ -191,18 +374,53 @@ var GenerateCertificate = func(req CertificateRequest, key crypto.PrivateKey, is
 		signerKey = issuer.PrivateKey
 	}
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, issuerCert, publicKey(key), signerKey)
+	certBytes, err := x509.CreateCertificate(randReader, template, issuerCert, publicKey(key), signerKey)
 	if err != nil {
-		return fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
+		return nil, fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
 	}
 
-	pemCert := &pem.Block{Type: "CERTIFICATE", Bytes: certBytes}
-	err = WritePemToFile(pemCert, req.OutCertPath)
+	cert, err := x509.ParseCertificate(certBytes)
 	if err != nil {
-		return fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
+		return nil, fmt.Errorf(format.WrapErrors, ErrGenerateCert, err)
 	}
 
-	return nil
+	return cert, nil
+}
+
+// CertificatePEMBlock optionally adds descriptive headers, which changes the encoded bytes.
+func CertificatePEMBlock(cert *x509.Certificate, withHeaders bool) *pem.Block {
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}
+	if withHeaders {
+		block.Headers = map[string]string{
+			"Subject": cert.Subject.String(),
+			"Issuer":  cert.Issuer.String(),
+			"Serial":  SerialNumber(cert),
+		}
+	}
+	return block
+}
+
+// LegacySHA1Fingerprint is only meant for older systems keyed on SHA-1, prefer Fingerprint.
+func LegacySHA1Fingerprint(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+	return colonHex(sum[:])
+}
+
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return colonHex(sum[:])
+}
+
+func SerialNumber(cert *x509.Certificate) string {
+	return colonHex(cert.SerialNumber.Bytes())
+}
+
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02X", v)
+	}
+	return strings.Join(parts, ":")
 }
 
 func publicKey(priv any) any {
@@ -218,6 +436,109 @@ func publicKey(priv any) any {
 	}
 }
 
+func samePublicKey(a, b crypto.PrivateKey) bool {
+	pub, ok := publicKey(a).(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(publicKey(b))
+}
+
+// IsSelfSigned reports whether the certificate is its own issuer and is signed by its own key.
+func IsSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	// CheckSignatureFrom would reject a non-CA parent
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// IssuedBy compares the certificate issuer name and authority key identifier with the issuer, without checking the signature.
+func IssuedBy(cert *x509.Certificate, issuer *Issuer) bool {
+	if !bytes.Equal(cert.RawIssuer, issuer.PublicKey.RawSubject) {
+		return false
+	}
+	if len(cert.AuthorityKeyId) == 0 || len(issuer.PublicKey.SubjectKeyId) == 0 {
+		return true
+	}
+	return bytes.Equal(cert.AuthorityKeyId, issuer.PublicKey.SubjectKeyId)
+}
+
+var VerifyCertificateChain = func(cert *x509.Certificate, issuer *Issuer, withSystemRoots bool) error {
+	roots := x509.NewCertPool()
+	if withSystemRoots {
+		systemRoots, err := x509.SystemCertPool()
+		if err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrVerifyChain, err)
+		}
+		roots = systemRoots
+	}
+	roots.AddCert(issuer.PublicKey)
+	_, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrVerifyChain, err)
+	}
+	return nil
+}
+
+var WriteChain = func(issuer *Issuer, path string) error {
+	err := WritePemBlocksToFile([]*pem.Block{{Type: "CERTIFICATE", Bytes: issuer.PublicKey.Raw}}, path)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteChain, err)
+	}
+	return nil
+}
+
+var WriteFullChain = func(cert *x509.Certificate, issuer *Issuer, path string, withHeaders bool) error {
+	blocks := []*pem.Block{CertificatePEMBlock(cert, withHeaders)}
+	if issuer != nil {
+		blocks = append(blocks, CertificatePEMBlock(issuer.PublicKey, withHeaders))
+	}
+	if err := WritePemBlocksToFile(blocks, path); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteFullChain, err)
+	}
+	return nil
+}
+
+// WriteBundle writes in a single file the private key (omitted when nil) encoded in keyFormat, the certificate and the
+// issuer certificate.
+var WriteBundle = func(key crypto.PrivateKey, keyFormat string, cert *x509.Certificate, issuer *Issuer, path string, withHeaders bool) error {
+	var blocks []*pem.Block
+	if key != nil {
+		keyBlock, err := privateKeyPEMBlock(key, keyFormat)
+		if err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrWriteBundle, err)
+		}
+		blocks = append(blocks, keyBlock)
+	}
+	blocks = append(blocks, CertificatePEMBlock(cert, withHeaders))
+	if issuer != nil {
+		blocks = append(blocks, CertificatePEMBlock(issuer.PublicKey, withHeaders))
+	}
+	if err := WritePemBlocksToFile(blocks, path); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteBundle, err)
+	}
+	return nil
+}
+
+// privateKeyPEMBlock encodes the key as GeneratePrivateKey does for the given privateKey.format.
+func privateKeyPEMBlock(key crypto.PrivateKey, keyFormat string) (*pem.Block, error) {
+	if !strings.EqualFold(keyFormat, PrivateKeyFormatPKCS8) {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}, nil
+		case *ecdsa.PrivateKey:
+			b, err := x509.MarshalECPrivateKey(k)
+			if err != nil {
+				return nil, fmt.Errorf(format.WrapErrors, ErrEncodePrivateKey, err)
+			}
+			return &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}, nil
+		}
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrEncodePrivateKey, err)
+	}
+	return &pem.Block{Type: "PRIVATE KEY", Bytes: b}, nil
+}
+
 var CopyCA = func(issuer *Issuer, path string) error {
 	pemCert := &pem.Block{Type: "CERTIFICATE", Bytes: issuer.PublicKey.Raw}
 	err := WritePemToFile(pemCert, path)
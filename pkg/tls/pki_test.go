@@ -1,14 +1,30 @@
 package tls
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
 )
 
 func TestGeneratePrivateKey(t *testing.T) {
@@ -36,6 +52,14 @@ func TestGeneratePrivateKey(t *testing.T) {
 			req:          CertificateRequest{PrivateKey: PrivateKey{Algorithm: "ed25519"}},
 			expectedType: "PRIVATE KEY",
 		},
+		"ECDSA as PKCS#8": {
+			req:          CertificateRequest{PrivateKey: PrivateKey{Algorithm: "ecdsa", Format: "pkcs8"}},
+			expectedType: "PRIVATE KEY",
+		},
+		"RSA as PKCS#8": {
+			req:          CertificateRequest{PrivateKey: PrivateKey{Algorithm: "rsa", Format: "PKCS8"}},
+			expectedType: "PRIVATE KEY",
+		},
 	} {
 		tc := tt // Use local variable to avoid closure-caused race condition
 		t.Run(name, func(t *testing.T) {
@@ -53,6 +77,36 @@ func TestGeneratePrivateKey(t *testing.T) {
 	}
 }
 
+func TestGeneratePrivateKey_WithFormatRoundTrip(t *testing.T) {
+	for name, tt := range map[string]struct {
+		format       string
+		expectedType string
+	}{
+		"SEC 1 by default": {format: "", expectedType: "EC PRIVATE KEY"},
+		"PKCS#8":           {format: PrivateKeyFormatPKCS8, expectedType: "PRIVATE KEY"},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			req := CertificateRequest{
+				OutKeyPath: filepath.Join(t.TempDir(), "tls.key"),
+				PrivateKey: PrivateKey{Algorithm: ECDSA, Size: 384, Format: tc.format},
+			}
+
+			key, err := GeneratePrivateKey(req)
+
+			require.NoError(t, err)
+			b, err := os.ReadFile(req.OutKeyPath)
+			require.NoError(t, err)
+			block, _ := pem.Decode(b)
+			require.NotNil(t, block)
+			assert.Equal(t, tc.expectedType, block.Type)
+			loaded, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+			require.NoError(t, err)
+			assert.True(t, key.(*ecdsa.PrivateKey).Equal(loaded))
+		})
+	}
+}
+
 func TestGeneratePrivateKey_WithError(t *testing.T) {
 	for name, tt := range map[string]struct {
 		req            CertificateRequest
@@ -69,6 +123,11 @@ func TestGeneratePrivateKey_WithError(t *testing.T) {
 			writePemToFile: func(_ *pem.Block, _ string) error { return errors.New("error") },
 			expectedError:  ErrGenerateKey,
 		},
+		"In memory without hook": {
+			req:            CertificateRequest{OutKeyInMemory: true},
+			writePemToFile: func(_ *pem.Block, _ string) error { return errors.New("unexpected write") },
+			expectedError:  ErrMissingPrivateKeyHook,
+		},
 	} {
 		tc := tt // Use local variable to avoid closure-caused race condition
 		t.Run(name, func(t *testing.T) {
@@ -81,6 +140,143 @@ func TestGeneratePrivateKey_WithError(t *testing.T) {
 	}
 }
 
+func TestRandReader_IsCryptoRand(t *testing.T) {
+	assert.Equal(t, rand.Reader, randReader)
+}
+
+func TestGeneratePrivateKey_WithDeterministicRandReader(t *testing.T) {
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	req := CertificateRequest{PrivateKey: PrivateKey{Algorithm: ED25519}}
+
+	mock(t, &randReader, deterministicReader())
+	first, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	mock(t, &randReader, deterministicReader())
+	second, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+}
+
+func TestGeneratePrivateKey_WithRandReaderError(t *testing.T) {
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	mock(t, &randReader, iotest.ErrReader(errors.New("no entropy")))
+
+	// rsa and ecdsa keys cannot be reproduced from a deterministic reader (the standard library may read an extra
+	// random byte), so only check that they use the injected reader
+	for _, algorithm := range []string{RSA, ECDSA, ED25519} {
+		_, err := GeneratePrivateKey(CertificateRequest{PrivateKey: PrivateKey{Algorithm: algorithm}})
+
+		assert.ErrorIs(t, err, ErrGenerateKey, algorithm)
+	}
+}
+
+func TestGeneratePrivateKey_InMemory(t *testing.T) {
+	req := CertificateRequest{OutKeyInMemory: true, PrivateKey: PrivateKey{Algorithm: ECDSA}}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return errors.New("unexpected write") })
+	var delivered []byte
+	mock(t, &PrivateKeyHook, func(_ CertificateRequest, _ crypto.PrivateKey, keyPEM []byte) error {
+		delivered = keyPEM
+		return nil
+	})
+
+	key, err := GeneratePrivateKey(req)
+
+	require.NoError(t, err)
+	pemBlock, _ := pem.Decode(delivered)
+	require.NotNil(t, pemBlock)
+	parsed, err := x509.ParseECPrivateKey(pemBlock.Bytes)
+	require.NoError(t, err)
+	assert.True(t, samePublicKey(key, parsed))
+}
+
+func TestGeneratePrivateKey_InMemoryWithHookError(t *testing.T) {
+	mock(t, &PrivateKeyHook, func(_ CertificateRequest, _ crypto.PrivateKey, _ []byte) error { return errors.New("hook error") })
+
+	_, err := GeneratePrivateKey(CertificateRequest{OutKeyInMemory: true, PrivateKey: PrivateKey{Algorithm: ECDSA}})
+
+	assert.ErrorIs(t, err, ErrGenerateKey)
+	assert.ErrorContains(t, err, "hook error")
+}
+
+func TestGeneratePrivateKey_WithPolicy(t *testing.T) {
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	mock(t, &config.PolicyMinRSASize, 3072)
+	mock(t, &config.PolicyAllowedECDSACurves, []string{"P-384"})
+	mock(t, &config.PolicyAllowedAlgorithms, []string{"rsa", "ecdsa"})
+
+	for name, tt := range map[string]struct {
+		privateKey    PrivateKey
+		expectedError error
+	}{
+		"Allowed RSA":          {privateKey: PrivateKey{Algorithm: "RSA", Size: 3072}},
+		"Allowed ECDSA":        {privateKey: PrivateKey{Algorithm: "ecdsa", Size: 384}},
+		"RSA too weak":         {privateKey: PrivateKey{Algorithm: "rsa", Size: 2048}, expectedError: ErrPolicyViolation},
+		"Default RSA too weak": {privateKey: PrivateKey{}, expectedError: ErrPolicyViolation},
+		"Disallowed curve":     {privateKey: PrivateKey{Algorithm: "ecdsa", Size: 256}, expectedError: ErrPolicyViolation},
+		"Disallowed algorithm": {privateKey: PrivateKey{Algorithm: "ed25519"}, expectedError: ErrPolicyViolation},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			_, err := GeneratePrivateKey(CertificateRequest{PrivateKey: tc.privateKey})
+
+			if tc.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestWritePublicKey(t *testing.T) {
+	for _, algorithm := range []string{RSA, ECDSA, ED25519} {
+		t.Run(algorithm, func(t *testing.T) {
+			mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+			key, err := GeneratePrivateKey(CertificateRequest{PrivateKey: PrivateKey{Algorithm: algorithm}})
+			require.NoError(t, err)
+			var pemBlock *pem.Block
+			mock(t, &WritePemToFile, func(b *pem.Block, _ string) error {
+				pemBlock = b
+				return nil
+			})
+
+			err = WritePublicKey(key, "public.pem")
+
+			require.NoError(t, err)
+			assert.Equal(t, "PUBLIC KEY", pemBlock.Type)
+			actual, err := x509.ParsePKIXPublicKey(pemBlock.Bytes)
+			require.NoError(t, err)
+			assert.Equal(t, publicKey(key), actual)
+		})
+	}
+}
+
+func TestWritePublicKey_WithError(t *testing.T) {
+	for name, tt := range map[string]struct {
+		key            crypto.PrivateKey
+		writePemToFile func(_ *pem.Block, _ string) error
+	}{
+		"Unsupported key": {
+			key:            "invalid",
+			writePemToFile: func(_ *pem.Block, _ string) error { return nil },
+		},
+		"Write error": {
+			key:            ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)),
+			writePemToFile: func(_ *pem.Block, _ string) error { return errors.New("error") },
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			mock(t, &WritePemToFile, tc.writePemToFile)
+
+			err := WritePublicKey(tc.key, "public.pem")
+
+			assert.ErrorIs(t, err, ErrWritePublicKey)
+		})
+	}
+}
+
 func TestGenerateCertificate(t *testing.T) {
 	var req CertificateRequest
 	var pemBlock *pem.Block
@@ -91,10 +287,301 @@ func TestGenerateCertificate(t *testing.T) {
 	key, err := GeneratePrivateKey(req)
 	require.NoError(t, err)
 
-	err = GenerateCertificate(req, key, nil)
+	cert, err := GenerateCertificate(req, key, nil)
 
 	require.NoError(t, err)
 	assert.Equal(t, "CERTIFICATE", pemBlock.Type)
+	assert.Equal(t, pemBlock.Bytes, cert.Raw)
+}
+
+func TestGenerateCertificate_WithDeterministicRandReader(t *testing.T) {
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	now := time.Now().Truncate(time.Second)
+	req := CertificateRequest{CommonName: "test", NotBefore: now, NotAfter: now.Add(time.Hour), PrivateKey: PrivateKey{Algorithm: ED25519}}
+	generate := func() *x509.Certificate {
+		mock(t, &randReader, deterministicReader())
+		key, err := GeneratePrivateKey(req)
+		require.NoError(t, err)
+		cert, err := GenerateCertificate(req, key, nil)
+		require.NoError(t, err)
+		return cert
+	}
+
+	first := generate()
+	second := generate()
+
+	assert.Equal(t, first.SerialNumber, second.SerialNumber)
+	assert.Equal(t, first.Raw, second.Raw)
+}
+
+func TestGenerateCertificate_WithSANCritical(t *testing.T) {
+	for name, tt := range map[string]struct {
+		sanCritical bool
+	}{
+		"Critical":     {sanCritical: true},
+		"Not critical": {sanCritical: false},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			req := CertificateRequest{
+				CommonName:  "test",
+				DNSNames:    []string{"localhost", "example.com"},
+				IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+				SANCritical: tc.sanCritical,
+			}
+			mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+			key, err := GeneratePrivateKey(req)
+			require.NoError(t, err)
+
+			cert, err := GenerateCertificate(req, key, nil)
+
+			require.NoError(t, err)
+			i := slices.IndexFunc(cert.Extensions, func(e pkix.Extension) bool { return e.Id.Equal(OIDExtensionSubjectAltName) })
+			require.GreaterOrEqual(t, i, 0)
+			assert.Equal(t, tc.sanCritical, cert.Extensions[i].Critical)
+			assert.Equal(t, req.DNSNames, cert.DNSNames)
+			assert.Len(t, cert.IPAddresses, 2)
+			assert.True(t, cert.IPAddresses[0].Equal(req.IPAddresses[0]))
+			assert.True(t, cert.IPAddresses[1].Equal(req.IPAddresses[1]))
+		})
+	}
+}
+
+func TestGenerateCertificate_WithKeyUsage(t *testing.T) {
+	req := CertificateRequest{
+		CommonName: "test",
+		Duration:   time.Hour,
+		IsCA:       true,
+		KeyUsage:   x509.KeyUsageCRLSign,
+		PrivateKey: PrivateKey{Algorithm: ED25519},
+	}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	cert, err := GenerateCertificate(req, key, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign|x509.KeyUsageCRLSign, cert.KeyUsage)
+}
+
+func TestGenerateCertificate_WithUnknownExtKeyUsage(t *testing.T) {
+	oid := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 2}
+	req := CertificateRequest{
+		CommonName:         "test",
+		Duration:           time.Hour,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{oid},
+		PrivateKey:         PrivateKey{Algorithm: ED25519},
+	}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	cert, err := GenerateCertificate(req, key, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+	require.Len(t, cert.UnknownExtKeyUsage, 1)
+	assert.True(t, oid.Equal(cert.UnknownExtKeyUsage[0]))
+}
+
+func TestGenerateCertificate_WithBCCritical(t *testing.T) {
+	req := CertificateRequest{
+		CommonName: "test",
+		Duration:   time.Hour,
+		DNSNames:   []string{"localhost"},
+		BCCritical: true,
+		PrivateKey: PrivateKey{Algorithm: ED25519},
+	}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	cert, err := GenerateCertificate(req, key, nil)
+
+	require.NoError(t, err)
+	var extensions []pkix.Extension
+	for _, e := range cert.Extensions {
+		if e.Id.Equal(OIDExtensionBasicConstraints) {
+			extensions = append(extensions, e)
+		}
+	}
+	require.Len(t, extensions, 1)
+	assert.True(t, extensions[0].Critical)
+	assert.True(t, cert.BasicConstraintsValid)
+	assert.False(t, cert.IsCA)
+}
+
+func TestGenerateCertificate_WithSuppressExtensions(t *testing.T) {
+	req := CertificateRequest{
+		CommonName:         "test",
+		Duration:           time.Hour,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:           []string{"localhost"},
+		IPAddresses:        []net.IP{net.IPv4(127, 0, 0, 1)},
+		SANCritical:        true,
+		SuppressExtensions: true,
+		PrivateKey:         PrivateKey{Algorithm: ED25519},
+	}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	cert, err := GenerateCertificate(req, key, nil)
+
+	require.NoError(t, err)
+	assert.Empty(t, cert.Extensions)
+	assert.Empty(t, cert.DNSNames)
+	assert.Empty(t, cert.IPAddresses)
+	assert.Zero(t, cert.KeyUsage)
+	assert.Empty(t, cert.ExtKeyUsage)
+	assert.False(t, cert.BasicConstraintsValid)
+}
+
+func TestGenerateCertificate_WithAbsoluteValidity(t *testing.T) {
+	notBefore := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2030, 6, 30, 10, 0, 0, 0, time.UTC)
+	for name, tt := range map[string]struct {
+		req               CertificateRequest
+		expectedNotBefore time.Time
+		expectedNotAfter  time.Time
+	}{
+		"notBefore and notAfter": {
+			req:               CertificateRequest{NotBefore: notBefore, NotAfter: notAfter},
+			expectedNotBefore: notBefore,
+			expectedNotAfter:  notAfter,
+		},
+		"notBefore and duration": {
+			req:               CertificateRequest{NotBefore: notBefore, Duration: 24 * time.Hour},
+			expectedNotBefore: notBefore,
+			expectedNotAfter:  notBefore.Add(24 * time.Hour),
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+			key, err := GeneratePrivateKey(tc.req)
+			require.NoError(t, err)
+
+			cert, err := GenerateCertificate(tc.req, key, nil)
+
+			require.NoError(t, err)
+			assert.True(t, tc.expectedNotBefore.Equal(cert.NotBefore), cert.NotBefore)
+			assert.True(t, tc.expectedNotAfter.Equal(cert.NotAfter), cert.NotAfter)
+		})
+	}
+}
+
+func TestGenerateCertificate_WithNotAfterOnly(t *testing.T) {
+	notAfter := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	req := CertificateRequest{NotAfter: notAfter}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	cert, err := GenerateCertificate(req, key, nil)
+
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), cert.NotBefore, time.Minute)
+	assert.True(t, notAfter.Equal(cert.NotAfter), cert.NotAfter)
+}
+
+func TestGenerateCertificate_WithOCSPNoCheck(t *testing.T) {
+	for name, tt := range map[string]struct {
+		ocspNoCheck bool
+	}{
+		"Enabled":  {ocspNoCheck: true},
+		"Disabled": {ocspNoCheck: false},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			req := CertificateRequest{
+				CommonName:  "ocsp",
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+				OCSPNoCheck: tc.ocspNoCheck,
+			}
+			mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+			key, err := GeneratePrivateKey(req)
+			require.NoError(t, err)
+
+			cert, err := GenerateCertificate(req, key, nil)
+
+			require.NoError(t, err)
+			i := slices.IndexFunc(cert.Extensions, func(e pkix.Extension) bool { return e.Id.Equal(OIDExtensionOCSPNoCheck) })
+			if !tc.ocspNoCheck {
+				assert.Equal(t, -1, i)
+				return
+			}
+			require.GreaterOrEqual(t, i, 0)
+			assert.False(t, cert.Extensions[i].Critical)
+			assert.Equal(t, asn1.NullBytes, cert.Extensions[i].Value)
+			assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, cert.ExtKeyUsage)
+		})
+	}
+}
+
+func TestGenerateCertificate_WithMustStaple(t *testing.T) {
+	for name, tt := range map[string]struct {
+		mustStaple bool
+	}{
+		"Enabled":  {mustStaple: true},
+		"Disabled": {mustStaple: false},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			req := CertificateRequest{CommonName: "staple", MustStaple: tc.mustStaple}
+			mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+			key, err := GeneratePrivateKey(req)
+			require.NoError(t, err)
+
+			cert, err := GenerateCertificate(req, key, nil)
+
+			require.NoError(t, err)
+			i := slices.IndexFunc(cert.Extensions, func(e pkix.Extension) bool { return e.Id.Equal(OIDExtensionTLSFeature) })
+			if !tc.mustStaple {
+				assert.Equal(t, -1, i)
+				return
+			}
+			require.GreaterOrEqual(t, i, 0)
+			assert.False(t, cert.Extensions[i].Critical)
+			// SEQUENCE { INTEGER 5 }
+			assert.Equal(t, []byte{0x30, 0x03, 0x02, 0x01, 0x05}, cert.Extensions[i].Value)
+			var features []int
+			_, err = asn1.Unmarshal(cert.Extensions[i].Value, &features)
+			require.NoError(t, err)
+			assert.Equal(t, []int{5}, features)
+		})
+	}
+}
+
+func TestGenerateCertificate_WithQCStatements(t *testing.T) {
+	req := CertificateRequest{QCStatements: QCStatements{Enable: true, Types: []asn1.ObjectIdentifier{OIDQCTypeESeal}}}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	cert, err := GenerateCertificate(req, key, nil)
+
+	require.NoError(t, err)
+	expected, err := qcStatementsExtension(req.QCStatements)
+	require.NoError(t, err)
+	assert.Contains(t, cert.Extensions, expected)
+}
+
+func TestGenerateCertificate_WithSerialNumberFile(t *testing.T) {
+	req := CertificateRequest{SerialNumber: SerialNumberSource{Type: "file", Path: filepath.Join(t.TempDir(), "serial")}}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	first, err := GenerateCertificate(req, key, nil)
+	require.NoError(t, err)
+	second, err := GenerateCertificate(req, key, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, big.NewInt(1), first.SerialNumber)
+	assert.Equal(t, big.NewInt(2), second.SerialNumber)
 }
 
 func TestGenerateCertificate_WithError(t *testing.T) {
@@ -104,11 +591,87 @@ func TestGenerateCertificate_WithError(t *testing.T) {
 	require.NoError(t, err)
 	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return errors.New("error") })
 
-	err = GenerateCertificate(req, key, nil)
+	_, err = GenerateCertificate(req, key, nil)
 
 	require.ErrorIs(t, err, ErrGenerateCert)
 }
 
+func TestCertificatePEMBlock(t *testing.T) {
+	cert, err := LoadCertFromFile("testdata/test.crt")
+	require.NoError(t, err)
+	withoutHeaders, err := os.ReadFile("testdata/test.crt")
+	require.NoError(t, err)
+
+	assert.Equal(t, withoutHeaders, pem.EncodeToMemory(CertificatePEMBlock(cert, false)))
+	withHeaders := pem.EncodeToMemory(CertificatePEMBlock(cert, true))
+	assert.NotEqual(t, withoutHeaders, withHeaders)
+	block, _ := pem.Decode(withHeaders)
+	require.NotNil(t, block)
+	assert.Equal(t, map[string]string{
+		"Subject": cert.Subject.String(),
+		"Issuer":  cert.Issuer.String(),
+		"Serial":  testCertSerial,
+	}, block.Headers)
+	assert.Equal(t, cert.Raw, block.Bytes)
+}
+
+func TestGenerateCertificate_WithPEMHeaders(t *testing.T) {
+	req := CertificateRequest{CommonName: "test", OutPEMHeaders: true}
+	var pemBlock *pem.Block
+	mock(t, &WritePemToFile, func(b *pem.Block, _ string) error {
+		pemBlock = b
+		return nil
+	})
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+
+	_, err = GenerateCertificate(req, key, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "CN=test", pemBlock.Headers["Subject"])
+	assert.Equal(t, "CN=test", pemBlock.Headers["Issuer"])
+}
+
+func TestFingerprint(t *testing.T) {
+	cert, err := LoadCertFromFile("testdata/test.crt")
+	require.NoError(t, err)
+
+	assert.Equal(t, "3A:07:D3:EA:A5:A0:5B:3B:FF:CB:79:3F:D6:0D:1E:D4:3D:62:9A:E7:11:DE:19:80:67:2A:59:5F:9B:77:FE:7B", Fingerprint(cert))
+}
+
+func TestIssuedBy(t *testing.T) {
+	issuer := &Issuer{PublicKey: &x509.Certificate{RawSubject: []byte("CN=ca"), SubjectKeyId: []byte{1}}}
+	for name, tt := range map[string]struct {
+		cert     *x509.Certificate
+		expected bool
+	}{
+		"Same issuer":              {cert: &x509.Certificate{RawIssuer: []byte("CN=ca"), AuthorityKeyId: []byte{1}}, expected: true},
+		"Without authority key id": {cert: &x509.Certificate{RawIssuer: []byte("CN=ca")}, expected: true},
+		"Different issuer name":    {cert: &x509.Certificate{RawIssuer: []byte("CN=other"), AuthorityKeyId: []byte{1}}, expected: false},
+		"Rotated key, same name":   {cert: &x509.Certificate{RawIssuer: []byte("CN=ca"), AuthorityKeyId: []byte{2}}, expected: false},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IssuedBy(tc.cert, issuer))
+		})
+	}
+}
+
+func TestLegacySHA1Fingerprint(t *testing.T) {
+	cert, err := LoadCertFromFile("testdata/test.crt")
+	require.NoError(t, err)
+
+	// Same value as `openssl x509 -noout -fingerprint -sha1 -in testdata/test.crt`
+	assert.Equal(t, "ED:1D:70:E4:F0:47:F1:72:5A:46:4E:E9:89:C9:95:DA:6F:B1:A6:09", LegacySHA1Fingerprint(cert))
+}
+
+func TestSerialNumber(t *testing.T) {
+	cert, err := LoadCertFromFile("testdata/test.crt")
+	require.NoError(t, err)
+
+	assert.Equal(t, "E8:A4:75:B4:D3:64:91:D8:67:D3:0A:2E:20:7F:E1:01", SerialNumber(cert))
+}
+
 func TestCopyCA(t *testing.T) {
 	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
 	require.NoError(t, err)
@@ -123,6 +686,15 @@ func TestCopyCA(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestWriteBundle_WithError(t *testing.T) {
+	cert, err := LoadCertFromFile("testdata/test.crt")
+	require.NoError(t, err)
+
+	err = WriteBundle(nil, "", cert, nil, filepath.Join(t.TempDir(), "missing", "bundle.pem"), false)
+
+	assert.ErrorIs(t, err, ErrWriteBundle)
+}
+
 func TestCopyCA_WithError(t *testing.T) {
 	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return errors.New("error") })
 
@@ -130,3 +702,24 @@ func TestCopyCA_WithError(t *testing.T) {
 
 	require.ErrorIs(t, err, ErrCopyCA)
 }
+
+func deterministicReader() io.Reader {
+	return mathrand.New(mathrand.NewSource(42))
+}
+
+func TestIsSelfSigned(t *testing.T) {
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	req := CertificateRequest{CommonName: "test", Duration: time.Hour}
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	selfSigned, err := GenerateCertificate(req, key, nil)
+	require.NoError(t, err)
+	issued, err := GenerateCertificate(req, key, issuer)
+	require.NoError(t, err)
+
+	assert.True(t, IsSelfSigned(selfSigned))
+	assert.True(t, IsSelfSigned(issuer.PublicKey))
+	assert.False(t, IsSelfSigned(issued))
+}
@@ -0,0 +1,273 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+const (
+	PlanActionCreate = "create"
+	PlanActionRenew  = "renew"
+	PlanActionRotate = "rotate"
+	PlanActionSkip   = "skip"
+	PlanActionError  = "error"
+)
+
+type Plan struct {
+	Request     string `json:"request"`
+	OutCertPath string `json:"outCertPath,omitempty"`
+	Action      string `json:"action"`
+	Reason      string `json:"reason"`
+}
+
+var NeedsRenewal = func(req CertificateRequest, cert *x509.Certificate) bool {
+	return RenewalTime(req, cert).Before(time.Now())
+}
+
+// RenewalTime returns when the certificate must be renewed: renewBefore its expiry, moved earlier by an offset in
+// [0, renewBeforeSpread) derived from its serial number. The offset is the same across restarts for a given
+// certificate, while the renewals of the certificates issued at the same time are spread over the window.
+func RenewalTime(req CertificateRequest, cert *x509.Certificate) time.Time {
+	renewAt := cert.NotAfter.Add(-req.RenewBefore)
+	if req.RenewSpread <= 0 {
+		return renewAt
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(cert.SerialNumber.Bytes())
+	return renewAt.Add(-time.Duration(h.Sum64() % uint64(req.RenewSpread)))
+}
+
+// PlanCertificateRequests tells what would be done for the request files of the directories and for the inline requests.
+var PlanCertificateRequests = func(dirs []string, inline []map[string]any) []Plan {
+	var plans []Plan
+	for _, dir := range dirs {
+		files, err := ReadDir(dir)
+		if err != nil {
+			plans = append(plans, Plan{Request: dir, Action: PlanActionError, Reason: err.Error()})
+			continue
+		}
+		for _, file := range files {
+			// Handle only certificate request files
+			if !config.IsCertificateRequestFile(file) {
+				continue
+			}
+			plans = append(plans, PlanCertificateRequest(file)...)
+		}
+	}
+	for i, values := range inline {
+		req, err := BuildCertificateRequest(values)
+		plans = append(plans, planCertificateRequest(fmt.Sprintf("inline #%d", i), req, err)...)
+	}
+	return plans
+}
+
+// PlanCertificateRequest tells what would be done for each key pair of the request file.
+var PlanCertificateRequest = func(file string) []Plan {
+	req, err := LoadCertificateRequest(file)
+	return planCertificateRequest(file, req, err)
+}
+
+func planCertificateRequest(name string, req CertificateRequest, err error) []Plan {
+	if err != nil {
+		return []Plan{{Request: name, Action: PlanActionError, Reason: err.Error()}}
+	}
+	if req.Disabled {
+		return []Plan{{Request: name, Action: PlanActionSkip, Reason: "certificate request disabled"}}
+	}
+	var plans []Plan
+	for _, variant := range KeyVariants(req) {
+		plans = append(plans, planKeyVariant(name, variant))
+	}
+	return plans
+}
+
+func planKeyVariant(name string, req CertificateRequest) Plan {
+	plan := Plan{Request: name, OutCertPath: req.OutCertPath}
+
+	issuer, err := LoadIssuer(req.IssuerPath)
+	if err != nil {
+		plan.Action, plan.Reason = PlanActionError, "invalid issuer: "+err.Error()
+		return plan
+	}
+
+	d := decideCertificate(req, issuer)
+	switch d.state {
+	case certificateMissing:
+		plan.Action, plan.Reason = PlanActionCreate, "certificate "+req.OutCertPath+" does not exist"
+	case certificateUnchanged:
+		plan.Action, plan.Reason = PlanActionSkip, "request and issuer unchanged since the last generation"
+	case certificateCorrupt:
+		plan.Action, plan.Reason = PlanActionRenew, "invalid certificate: "+d.err.Error()
+		if req.OnCorruptCert == OnCorruptFail {
+			plan.Action = PlanActionError
+		}
+	case certificateExpired:
+		plan.Action, plan.Reason = PlanActionRenew, "certificate expires at "+d.cert.NotAfter.Format(time.DateTime)
+	case certificateNotRenewable:
+		plan.Action, plan.Reason = PlanActionSkip, "certificate expires at "+d.cert.NotAfter.Format(time.DateTime)+", not renewable with a fixed notAfter"
+	case certificateIssuerChanged:
+		plan.Action, plan.Reason = PlanActionRenew, "issuer changed"
+	case certificateRequestChanged:
+		plan.Action, plan.Reason = PlanActionRenew, "request changed: "+strings.Join(d.diffs, ", ")
+		if d.keyChanged {
+			plan.Action = PlanActionRotate
+		}
+	case certificateChainBroken:
+		plan.Action, plan.Reason = PlanActionRenew, "certificate does not chain to issuer: "+d.err.Error()
+	default:
+		plan.Action, plan.Reason = PlanActionSkip, "certificate valid until "+d.cert.NotAfter.Format(time.DateTime)
+	}
+	return plan
+}
+
+type certificateState int
+
+const (
+	certificateValid certificateState = iota
+	certificateMissing
+	certificateUnchanged
+	certificateCorrupt
+	certificateExpired
+	certificateNotRenewable
+	certificateIssuerChanged
+	certificateRequestChanged
+	certificateChainBroken
+)
+
+// certificateDecision tells whether the certificate of a key variant must be generated and why.
+type certificateDecision struct {
+	state      certificateState
+	cert       *x509.Certificate
+	err        error
+	diffs      []string
+	keyChanged bool
+}
+
+// decideCertificate is the single decision shared by the daemon and plan, so that plan reports what the daemon does.
+func decideCertificate(req CertificateRequest, issuer *Issuer) certificateDecision {
+	if FileDoesNotExists(req.OutCertPath) {
+		return certificateDecision{state: certificateMissing}
+	}
+
+	if unchangedRequest(req, issuer) {
+		return certificateDecision{state: certificateUnchanged}
+	}
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	if err != nil {
+		return certificateDecision{state: certificateCorrupt, err: err}
+	}
+
+	if NeedsRenewal(req, cert) {
+		// A fixed notAfter would give the same expiry again, and a regeneration on every pass
+		if !req.NotAfter.IsZero() && !cert.NotAfter.Before(req.NotAfter.Truncate(time.Second)) {
+			return certificateDecision{state: certificateNotRenewable, cert: cert}
+		}
+		return certificateDecision{state: certificateExpired, cert: cert}
+	}
+
+	if req.RegenerateOnIssuer && issuer != nil && !IssuedBy(cert, issuer) {
+		return certificateDecision{state: certificateIssuerChanged, cert: cert}
+	}
+
+	if req.Regenerate == RegenerateCertOnly {
+		if diffs, keyChanged := requestChanges(req, cert); len(diffs) > 0 {
+			return certificateDecision{state: certificateRequestChanged, cert: cert, diffs: diffs, keyChanged: keyChanged}
+		}
+	}
+
+	if req.VerifyChain && issuer != nil {
+		if err := VerifyCertificateChain(cert, issuer, req.VerifySystemRoots); err != nil {
+			return certificateDecision{state: certificateChainBroken, cert: cert, err: err}
+		}
+	}
+	return certificateDecision{state: certificateValid, cert: cert}
+}
+
+// PlanExitCode returns 2 when changes are pending, 1 when a request cannot be planned and 0 otherwise.
+func PlanExitCode(plans []Plan) int {
+	code := 0
+	for _, plan := range plans {
+		switch plan.Action {
+		case PlanActionError:
+			return 1
+		case PlanActionCreate, PlanActionRenew, PlanActionRotate:
+			code = 2
+		}
+	}
+	return code
+}
+
+// requestChanges returns the differences between the request and the certificate, and whether they imply a new key.
+func requestChanges(req CertificateRequest, cert *x509.Certificate) ([]string, bool) {
+	current, wanted := requestFromCertificate(cert), normalizeRequest(req)
+	if !req.NotAfter.IsZero() {
+		// Absolute validity cannot be compared with a duration
+		wanted.Duration = current.Duration
+	}
+	return DiffRequests(current, wanted), current.PrivateKey != wanted.PrivateKey
+}
+
+// requestFromCertificate rebuilds the request fields that can be read back from a certificate.
+func requestFromCertificate(cert *x509.Certificate) CertificateRequest {
+	req := CertificateRequest{
+		CommonName:          cert.Subject.CommonName,
+		IsCA:                cert.IsCA,
+		Countries:           cert.Subject.Country,
+		Organizations:       cert.Subject.Organization,
+		OrganizationalUnits: cert.Subject.OrganizationalUnit,
+		Localities:          cert.Subject.Locality,
+		Provinces:           cert.Subject.Province,
+		StreetAddresses:     cert.Subject.StreetAddress,
+		PostalCodes:         cert.Subject.PostalCode,
+		DNSNames:            cert.DNSNames,
+		IPAddresses:         cert.IPAddresses,
+		Duration:            cert.NotAfter.Sub(cert.NotBefore),
+	}
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		req.PrivateKey = PrivateKey{Algorithm: RSA, Size: pub.N.BitLen()}
+	case *ecdsa.PublicKey:
+		req.PrivateKey = PrivateKey{Algorithm: ECDSA, Size: pub.Curve.Params().BitSize}
+	case ed25519.PublicKey:
+		req.PrivateKey = PrivateKey{Algorithm: ED25519}
+	}
+	return req
+}
+
+// normalizeRequest applies the defaults used at generation time so that it compares with requestFromCertificate.
+func normalizeRequest(req CertificateRequest) CertificateRequest {
+	req.RenewBefore = 0
+	req.PrivateKey.Reuse = false
+	// The key encoding cannot be read back from the certificate
+	req.PrivateKey.Format = ""
+	// Certificate validity is encoded with a one-second precision
+	req.Duration = req.Duration.Round(time.Second)
+	if req.SuppressExtensions {
+		// The SANs are extensions, left out of the certificate
+		req.DNSNames, req.IPAddresses = nil, nil
+	}
+	req.PrivateKey.Algorithm = strings.ToLower(req.PrivateKey.Algorithm)
+	switch req.PrivateKey.Algorithm {
+	case "", RSA:
+		req.PrivateKey.Algorithm = RSA
+		if req.PrivateKey.Size == 0 {
+			req.PrivateKey.Size = MinRSAKeySize
+		}
+	case ECDSA:
+		if req.PrivateKey.Size == 0 {
+			req.PrivateKey.Size = 256
+		}
+	case ED25519:
+		req.PrivateKey.Size = 0
+	}
+	return req
+}
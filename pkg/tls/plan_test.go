@@ -0,0 +1,267 @@
+package tls
+
+import (
+	"crypto/x509"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanCertificateRequests(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := t.TempDir()
+	changed := writePlanRequest(t, dir, "changed", "duration: 24h\nrenewBefore: 1h\nregenerate: certOnly\nprivateKey:\n  algorithm: ecdsa\n")
+	generatePlanCertificate(t, changed)
+	changed = writePlanRequest(t, dir, "changed", "duration: 24h\nrenewBefore: 1h\nregenerate: certOnly\nprivateKey:\n  algorithm: ecdsa\ndnsNames:\n  - localhost\n")
+	create := writePlanRequest(t, dir, "create", "duration: 24h\n")
+	// Without regenerate: certOnly, the daemon does not act on request changes
+	ignored := writePlanRequest(t, dir, "ignored", "duration: 24h\nrenewBefore: 1h\nprivateKey:\n  algorithm: ecdsa\n")
+	generatePlanCertificate(t, ignored)
+	ignored = writePlanRequest(t, dir, "ignored", "duration: 24h\nrenewBefore: 1h\nprivateKey:\n  algorithm: ecdsa\ndnsNames:\n  - localhost\n")
+	renew := writePlanRequest(t, dir, "renew", "duration: 1h\nrenewBefore: 2h\nprivateKey:\n  algorithm: ecdsa\n")
+	generatePlanCertificate(t, renew)
+	rotate := writePlanRequest(t, dir, "rotate", "duration: 24h\nrenewBefore: 1h\nregenerate: certOnly\nprivateKey:\n  algorithm: ecdsa\n")
+	generatePlanCertificate(t, rotate)
+	rotate = writePlanRequest(t, dir, "rotate", "duration: 24h\nrenewBefore: 1h\nregenerate: certOnly\nprivateKey:\n  algorithm: ecdsa\n  size: 384\n")
+	skip := writePlanRequest(t, dir, "skip", "duration: 24h\nrenewBefore: 1h\nprivateKey:\n  algorithm: ecdsa\n")
+	generatePlanCertificate(t, skip)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), nil, 0600))
+
+	plans := PlanCertificateRequests([]string{dir}, nil)
+
+	require.Len(t, plans, 6)
+	assert.Equal(t, Plan{Request: changed, OutCertPath: filepath.Join(dir, "changed", "tls.crt"), Action: PlanActionRenew, Reason: "request changed: dnsNames: [] -> [localhost]"}, plans[0])
+	assert.Equal(t, Plan{Request: create, OutCertPath: filepath.Join(dir, "create", "tls.crt"), Action: PlanActionCreate, Reason: "certificate " + filepath.Join(dir, "create", "tls.crt") + " does not exist"}, plans[1])
+	assert.Equal(t, ignored, plans[2].Request)
+	assert.Equal(t, PlanActionSkip, plans[2].Action)
+	assert.Contains(t, plans[2].Reason, "certificate valid until ")
+	assert.Equal(t, renew, plans[3].Request)
+	assert.Equal(t, PlanActionRenew, plans[3].Action)
+	assert.Contains(t, plans[3].Reason, "certificate expires at ")
+	assert.Equal(t, Plan{Request: rotate, OutCertPath: filepath.Join(dir, "rotate", "tls.crt"), Action: PlanActionRotate, Reason: "request changed: privateKey.size: 256 -> 384"}, plans[4])
+	assert.Equal(t, skip, plans[5].Request)
+	assert.Equal(t, PlanActionSkip, plans[5].Action)
+	assert.Contains(t, plans[5].Reason, "certificate valid until ")
+	assert.Equal(t, 2, PlanExitCode(plans))
+	assert.Equal(t, 0, PlanExitCode([]Plan{plans[2], plans[5]}))
+}
+
+func TestPlanCertificateRequests_WithError(t *testing.T) {
+	viper.Reset()
+
+	plans := PlanCertificateRequests([]string{"testdata/unknown"}, nil)
+
+	require.Len(t, plans, 1)
+	assert.Equal(t, PlanActionError, plans[0].Action)
+	assert.Contains(t, plans[0].Reason, ErrReadDir.Error())
+	assert.Equal(t, 1, PlanExitCode(plans))
+}
+
+func TestPlanCertificateRequests_WithInline(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	inline := []map[string]any{
+		{"out": map[string]any{"dir": dir}, "commonName": "inline"},
+		{"out": map[string]any{"dir": dir}, "commonName": "disabled", "enabled": false},
+		{"commonName": "invalid"},
+	}
+
+	plans := PlanCertificateRequests(nil, inline)
+
+	require.Len(t, plans, 3)
+	assert.Equal(t, Plan{Request: "inline #0", OutCertPath: filepath.Join(dir, "tls.crt"), Action: PlanActionCreate, Reason: "certificate " + filepath.Join(dir, "tls.crt") + " does not exist"}, plans[0])
+	assert.Equal(t, Plan{Request: "inline #1", Action: PlanActionSkip, Reason: "certificate request disabled"}, plans[1])
+	assert.Equal(t, "inline #2", plans[2].Request)
+	assert.Equal(t, PlanActionError, plans[2].Action)
+	assert.Contains(t, plans[2].Reason, ErrMissingMandatoryField.Error())
+}
+
+func TestPlanCertificateRequest_WithFixedNotAfterInRenewalWindow(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		CommonName:  "test",
+		NotAfter:    time.Now().Add(time.Hour),
+		RenewBefore: 2 * time.Hour,
+		PrivateKey:  PrivateKey{Algorithm: ED25519},
+	}
+	GenerateOutFilesFromRequest(req, nil)
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+
+	plans := PlanCertificateRequest("valid.yaml")
+
+	require.Len(t, plans, 1)
+	assert.Equal(t, PlanActionSkip, plans[0].Action)
+	assert.Contains(t, plans[0].Reason, "not renewable with a fixed notAfter")
+}
+
+func TestPlanCertificateRequest_WithInvalidCertificate(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	file := writePlanRequest(t, dir, "invalid", "duration: 24h\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "invalid"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid", "tls.crt"), []byte("invalid"), 0600))
+
+	plans := PlanCertificateRequest(file)
+
+	assert.Equal(t, []Plan{{Request: file, OutCertPath: filepath.Join(dir, "invalid", "tls.crt"), Action: PlanActionRenew, Reason: "invalid certificate: " + ErrInvalidPEMBlock.Error()}}, plans)
+}
+
+func TestPlanCertificateRequest_WithDisabledRequest(t *testing.T) {
+	viper.Reset()
+	file := writePlanRequest(t, t.TempDir(), "disabled", "duration: 24h\nenabled: false\n")
+
+	plans := PlanCertificateRequest(file)
+
+	assert.Equal(t, []Plan{{Request: file, Action: PlanActionSkip, Reason: "certificate request disabled"}}, plans)
+	assert.Equal(t, 0, PlanExitCode(plans))
+}
+
+func TestPlanCertificateRequest_WithCorruptCertificateFailing(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	file := writePlanRequest(t, dir, "invalid", "duration: 24h\nonCorruptCert: fail\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "invalid"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid", "tls.crt"), []byte("invalid"), 0600))
+
+	plans := PlanCertificateRequest(file)
+
+	assert.Equal(t, []Plan{{Request: file, OutCertPath: filepath.Join(dir, "invalid", "tls.crt"), Action: PlanActionError, Reason: "invalid certificate: " + ErrInvalidPEMBlock.Error()}}, plans)
+}
+
+func TestPlanCertificateRequest_WithIssuerChanged(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	file := writePlanRequest(t, dir, "issuer", "duration: 24h\nregenerateOnIssuerChange: true\nprivateKey:\n  algorithm: ecdsa\n")
+	generatePlanCertificate(t, file)
+	file = writePlanRequest(t, dir, "issuer", "duration: 24h\nregenerateOnIssuerChange: true\nprivateKey:\n  algorithm: ecdsa\nissuer:\n  dir: testdata\n")
+
+	plans := PlanCertificateRequest(file)
+
+	assert.Equal(t, []Plan{{Request: file, OutCertPath: filepath.Join(dir, "issuer", "tls.crt"), Action: PlanActionRenew, Reason: "issuer changed"}}, plans)
+}
+
+func TestPlanCertificateRequest_WithPrivateKeys(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := t.TempDir()
+	file := writePlanRequest(t, dir, "keys", "duration: 24h\nrenewBefore: 1h\nprivateKeys:\n  - algorithm: ed25519\n  - algorithm: ecdsa\n")
+	req, err := LoadCertificateRequest(file)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "keys"), 0755))
+	// Only the first certificate exists
+	GenerateOutFilesFromRequest(KeyVariants(req)[0], nil)
+
+	plans := PlanCertificateRequest(file)
+
+	require.Len(t, plans, 2)
+	assert.Equal(t, filepath.Join(dir, "keys", "tls.ed25519.crt"), plans[0].OutCertPath)
+	assert.Equal(t, PlanActionSkip, plans[0].Action)
+	missing := filepath.Join(dir, "keys", "tls.ecdsa.crt")
+	assert.Equal(t, Plan{Request: file, OutCertPath: missing, Action: PlanActionCreate, Reason: "certificate " + missing + " does not exist"}, plans[1])
+	assert.Equal(t, 2, PlanExitCode(plans))
+}
+
+func TestRequestChanges_WithSuppressExtensions(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutKeyPath:         filepath.Join(dir, "tls.key"),
+		OutCertPath:        filepath.Join(dir, "tls.crt"),
+		CommonName:         "test",
+		Duration:           24 * time.Hour,
+		DNSNames:           []string{"localhost"},
+		IPAddresses:        []net.IP{net.ParseIP("127.0.0.1")},
+		PrivateKey:         PrivateKey{Algorithm: ED25519},
+		Regenerate:         RegenerateCertOnly,
+		SuppressExtensions: true,
+	}
+	GenerateOutFilesFromRequest(req, nil)
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+
+	diffs, keyChanged := requestChanges(req, cert)
+
+	assert.Empty(t, diffs)
+	assert.False(t, keyChanged)
+	assert.Equal(t, certificateValid, decideCertificate(req, nil).state)
+}
+
+func TestPlanExitCode(t *testing.T) {
+	for name, tt := range map[string]struct {
+		plans    []Plan
+		expected int
+	}{
+		"No requests":     {plans: nil, expected: 0},
+		"Nothing to do":   {plans: []Plan{{Action: PlanActionSkip}}, expected: 0},
+		"Pending changes": {plans: []Plan{{Action: PlanActionSkip}, {Action: PlanActionRotate}}, expected: 2},
+		"Error":           {plans: []Plan{{Action: PlanActionCreate}, {Action: PlanActionError}}, expected: 1},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, PlanExitCode(tc.plans))
+		})
+	}
+}
+
+func writePlanRequest(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name+".yaml")
+	content = "out:\n  dir: " + filepath.Join(dir, name) + "\ncommonName: " + name + "\n" + content
+	require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+	return file
+}
+
+func generatePlanCertificate(t *testing.T, file string) {
+	req, err := LoadCertificateRequest(file)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(req.OutCertPath), 0755))
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	_, err = GenerateCertificate(req, key, nil)
+	require.NoError(t, err)
+}
+
+func TestRenewalTime(t *testing.T) {
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1234), NotAfter: notAfter}
+	sameCert := &x509.Certificate{SerialNumber: big.NewInt(1234), NotAfter: notAfter}
+	otherCert := &x509.Certificate{SerialNumber: big.NewInt(5678), NotAfter: notAfter}
+	req := CertificateRequest{RenewBefore: 24 * time.Hour, RenewSpread: 7 * 24 * time.Hour}
+
+	renewAt := RenewalTime(req, cert)
+
+	assert.Equal(t, renewAt, RenewalTime(req, sameCert))
+	assert.NotEqual(t, renewAt, RenewalTime(req, otherCert))
+	renewBefore := notAfter.Add(-req.RenewBefore)
+	assert.False(t, renewAt.After(renewBefore), renewAt)
+	assert.True(t, renewAt.After(renewBefore.Add(-req.RenewSpread)), renewAt)
+}
+
+func TestRenewalTime_WithoutSpread(t *testing.T) {
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1234), NotAfter: notAfter}
+
+	assert.Equal(t, notAfter.Add(-time.Hour), RenewalTime(CertificateRequest{RenewBefore: time.Hour}, cert))
+}
+
+func TestNeedsRenewal_WithSpread(t *testing.T) {
+	req := CertificateRequest{RenewBefore: time.Hour, RenewSpread: 24 * time.Hour}
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1234), NotAfter: time.Now()}
+	offset := cert.NotAfter.Add(-req.RenewBefore).Sub(RenewalTime(req, cert))
+
+	cert.NotAfter = time.Now().Add(req.RenewBefore + offset + time.Minute)
+	assert.False(t, NeedsRenewal(req, cert))
+
+	cert.NotAfter = time.Now().Add(req.RenewBefore + offset - time.Minute)
+	assert.True(t, NeedsRenewal(req, cert))
+}
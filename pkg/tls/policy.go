@@ -0,0 +1,45 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+var ErrPolicyViolation = errors.New("policy violation")
+
+func checkAlgorithmPolicy(algorithm string) error {
+	if len(config.PolicyAllowedAlgorithms) == 0 || containsFold(config.PolicyAllowedAlgorithms, algorithm) {
+		return nil
+	}
+	return fmt.Errorf("%w: algorithm %s is not allowed", ErrPolicyViolation, algorithm)
+}
+
+func checkRSAKeySizePolicy(keySize int) error {
+	if keySize >= config.PolicyMinRSASize {
+		return nil
+	}
+	return fmt.Errorf("%w: RSA key size %d is lower than %d", ErrPolicyViolation, keySize, config.PolicyMinRSASize)
+}
+
+func checkECDSACurvePolicy(curve string) error {
+	if len(config.PolicyAllowedECDSACurves) == 0 || containsFold(config.PolicyAllowedECDSACurves, curve) {
+		return nil
+	}
+	return fmt.Errorf("%w: ECDSA curve %s is not allowed", ErrPolicyViolation, curve)
+}
+
+func checkDurationPolicy(duration time.Duration) error {
+	if config.PolicyMaxDuration <= 0 || duration <= config.PolicyMaxDuration {
+		return nil
+	}
+	return fmt.Errorf("%w: duration %v is greater than %v", ErrPolicyViolation, duration, config.PolicyMaxDuration)
+}
+
+func containsFold(values []string, s string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, s) })
+}
@@ -0,0 +1,79 @@
+package tls
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	OIDExtensionQCStatements = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 3}
+	OIDQCCompliance          = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 1}
+	OIDQCType                = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6}
+	OIDQCTypeESign           = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6, 1}
+	OIDQCTypeESeal           = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6, 2}
+	OIDQCTypeWeb             = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6, 3}
+
+	ErrInvalidQCType = errors.New("invalid QC type")
+	ErrInvalidOID    = errors.New("invalid OID")
+)
+
+type QCStatements struct {
+	Enable bool
+	Types  []asn1.ObjectIdentifier
+}
+
+type qcStatement struct {
+	StatementID   asn1.ObjectIdentifier
+	StatementInfo asn1.RawValue `asn1:"optional"`
+}
+
+func qcStatementsExtension(qc QCStatements) (pkix.Extension, error) {
+	statements := []qcStatement{{StatementID: OIDQCCompliance}}
+	if len(qc.Types) > 0 {
+		types, err := asn1.Marshal(qc.Types)
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		statements = append(statements, qcStatement{StatementID: OIDQCType, StatementInfo: asn1.RawValue{FullBytes: types}})
+	}
+	value, err := asn1.Marshal(statements)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: OIDExtensionQCStatements, Value: value}, nil
+}
+
+func findQCType(s string) (asn1.ObjectIdentifier, error) {
+	switch strings.ToLower(s) {
+	case "esign":
+		return OIDQCTypeESign, nil
+	case "eseal":
+		return OIDQCTypeESeal, nil
+	case "web":
+		return OIDQCTypeWeb, nil
+	}
+	oid, err := parseOID(s)
+	if err != nil {
+		return nil, ErrInvalidQCType
+	}
+	return oid, nil
+}
+
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, ErrInvalidOID
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidOID
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
@@ -0,0 +1,66 @@
+package tls
+
+import (
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQCStatementsExtension(t *testing.T) {
+	extension, err := qcStatementsExtension(QCStatements{Enable: true, Types: []asn1.ObjectIdentifier{OIDQCTypeWeb}})
+
+	require.NoError(t, err)
+	assert.Equal(t, OIDExtensionQCStatements, extension.Id)
+	assert.False(t, extension.Critical)
+	var statements []qcStatement
+	_, err = asn1.Unmarshal(extension.Value, &statements)
+	require.NoError(t, err)
+	require.Len(t, statements, 2)
+	assert.Equal(t, OIDQCCompliance, statements[0].StatementID)
+	assert.Equal(t, OIDQCType, statements[1].StatementID)
+	var types []asn1.ObjectIdentifier
+	_, err = asn1.Unmarshal(statements[1].StatementInfo.FullBytes, &types)
+	require.NoError(t, err)
+	assert.Equal(t, []asn1.ObjectIdentifier{OIDQCTypeWeb}, types)
+}
+
+func TestQCStatementsExtension_WithoutTypes(t *testing.T) {
+	extension, err := qcStatementsExtension(QCStatements{Enable: true})
+
+	require.NoError(t, err)
+	var statements []qcStatement
+	_, err = asn1.Unmarshal(extension.Value, &statements)
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	assert.Equal(t, OIDQCCompliance, statements[0].StatementID)
+}
+
+func TestFindQCType(t *testing.T) {
+	for name, tt := range map[string]struct {
+		qcType   string
+		expected asn1.ObjectIdentifier
+	}{
+		"esign": {qcType: "esign", expected: OIDQCTypeESign},
+		"eseal": {qcType: "eSeal", expected: OIDQCTypeESeal},
+		"web":   {qcType: "WEB", expected: OIDQCTypeWeb},
+		"OID":   {qcType: "0.4.0.1862.1.6.3", expected: OIDQCTypeWeb},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			actual, err := findQCType(tc.qcType)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFindQCType_WithError(t *testing.T) {
+	for _, qcType := range []string{"invalid", "1", "1.a.3", "1.-2"} {
+		_, err := findQCType(qcType)
+
+		assert.ErrorIs(t, err, ErrInvalidQCType)
+	}
+}
@@ -0,0 +1,92 @@
+package tls
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// requestFailures counts the consecutive failures of the requests, and quarantines those reaching their
+// maxConsecutiveFailures until they change.
+var requestFailures = &failures{counts: make(map[string]int), quarantined: make(map[string]string)}
+
+// generationFailures records the certificates whose generation failed, as GenerateOutFilesFromRequest only logs them.
+var generationFailures = &failedCertificates{paths: make(map[string]struct{})}
+
+type failures struct {
+	mu          sync.Mutex
+	counts      map[string]int
+	quarantined map[string]string
+}
+
+// isQuarantined reports whether the request is quarantined, and releases it when its fingerprint changed.
+func (f *failures) isQuarantined(key, fingerprint string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	quarantined, ok := f.quarantined[key]
+	if !ok {
+		return false
+	}
+	if quarantined == fingerprint {
+		return true
+	}
+	delete(f.quarantined, key)
+	return false
+}
+
+// record counts a failure of the request or resets its count on success, and reports whether the request has just
+// been quarantined.
+func (f *failures) record(key, fingerprint string, ok bool, maxFailures int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if ok {
+		delete(f.counts, key)
+		return false
+	}
+	f.counts[key]++
+	if maxFailures <= 0 || f.counts[key] < maxFailures {
+		return false
+	}
+	delete(f.counts, key)
+	f.quarantined[key] = fingerprint
+	return true
+}
+
+type failedCertificates struct {
+	mu    sync.Mutex
+	paths map[string]struct{}
+}
+
+func (f *failedCertificates) add(path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.paths[path] = struct{}{}
+}
+
+// take reports whether the generation of the certificate failed, and forgets it.
+func (f *failedCertificates) take(path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.paths[path]
+	delete(f.paths, path)
+	return ok
+}
+
+// fileFingerprint identifies the content of a certificate request file by its size and modification time.
+func fileFingerprint(file string) string {
+	info, err := os.Stat(file)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// requestFingerprint identifies an inline certificate request, which has no file to look at.
+func requestFingerprint(req CertificateRequest) string {
+	fingerprint, _ := HashRequest(req, nil)
+	return fingerprint
+}
@@ -0,0 +1,92 @@
+package tls
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCertificateRequestFile_WithMaxConsecutiveFailures(t *testing.T) {
+	out := loggerOutput()
+	mock(t, &requestFailures, &failures{counts: make(map[string]int), quarantined: make(map[string]string)})
+	file := filepath.Join(t.TempDir(), "request.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("commonName: test\n"), 0o600))
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return CertificateRequest{MaxFailures: 2}, nil })
+	loads := 0
+	mock(t, &LoadIssuer, func(_ IssuerPath) (*Issuer, error) {
+		loads++
+		return nil, errors.New("LoadIssuer error")
+	})
+
+	for i := 0; i < 3; i++ {
+		HandleCertificateRequestFile(file)
+	}
+
+	assert.Equal(t, 2, loads)
+	assert.Equal(t, []string{
+		`level=info msg="Handle certificate request ` + file + `"`,
+		`level=error msg="Invalid issuer: LoadIssuer error"`,
+		`level=info msg="Handle certificate request ` + file + `"`,
+		`level=error msg="Invalid issuer: LoadIssuer error"`,
+		`level=error msg="Certificate request ` + file + ` quarantined after 2 consecutive failures, waiting for it to change"`,
+	}, splitLogLines(out))
+
+	// Changing the file releases the request
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(file, later, later))
+	HandleCertificateRequestFile(file)
+
+	assert.Equal(t, 3, loads)
+}
+
+func TestHandleCertificateRequestFile_WithFailureCountReset(t *testing.T) {
+	loggerOutput()
+	mock(t, &requestFailures, &failures{counts: make(map[string]int), quarantined: make(map[string]string)})
+	file := filepath.Join(t.TempDir(), "request.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("commonName: test\n"), 0o600))
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return CertificateRequest{MaxFailures: 2}, nil })
+	loads := 0
+	mock(t, &LoadIssuer, func(_ IssuerPath) (*Issuer, error) {
+		loads++
+		if loads == 2 {
+			return nil, nil
+		}
+		return nil, errors.New("LoadIssuer error")
+	})
+	mock(t, &FileDoesNotExists, func(_ string) bool { return true })
+	mock(t, &MakeParentsDirectories, func(_ string) error { return nil })
+	mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) {})
+
+	for i := 0; i < 4; i++ {
+		HandleCertificateRequestFile(file)
+	}
+
+	// A success in between resets the count of consecutive failures
+	assert.Equal(t, 4, loads)
+}
+
+func TestHandleInlineCertificateRequests_WithMaxConsecutiveFailures(t *testing.T) {
+	out := loggerOutput()
+	mock(t, &requestFailures, &failures{counts: make(map[string]int), quarantined: make(map[string]string)})
+	var generated int
+	mock(t, &GenerateOutFilesFromRequest, func(req CertificateRequest, _ *Issuer) {
+		generated++
+		generationFailures.add(req.OutCertPath)
+	})
+	requests := []map[string]any{{"out": map[string]any{"dir": t.TempDir()}, "commonName": "test", "maxConsecutiveFailures": 1}}
+
+	HandleInlineCertificateRequests(requests)
+	HandleInlineCertificateRequests(requests)
+
+	assert.Equal(t, 1, generated)
+	assert.Equal(t, []string{
+		`level=info msg="Handle inline certificate request #0"`,
+		`level=error msg="Inline certificate request #0 quarantined after 1 consecutive failures, waiting for it to change"`,
+		`level=info msg="Handle inline certificate request #0"`,
+	}, splitLogLines(out))
+}
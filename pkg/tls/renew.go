@@ -0,0 +1,87 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var (
+	ErrRequestNotFound = errors.New("no certificate request for this certificate")
+	ErrRenewFailed     = errors.New("renew failed")
+)
+
+// IndexRequestsByOutCertPath indexes the key variants of the enabled requests by the absolute path of their
+// certificate.
+func IndexRequestsByOutCertPath(requests []CertificateRequest) map[string]CertificateRequest {
+	index := make(map[string]CertificateRequest)
+	for _, req := range requests {
+		if req.Disabled {
+			continue
+		}
+		for _, variant := range KeyVariants(req) {
+			index[absPath(variant.OutCertPath)] = variant
+		}
+	}
+	return index
+}
+
+// FindRequestByOutCertPath loads the requests of the directories and the inline requests to find the one writing the
+// certificate to path. Invalid requests are logged and skipped.
+var FindRequestByOutCertPath = func(dirs []string, inline []map[string]any, path string) (CertificateRequest, error) {
+	var requests []CertificateRequest
+	for _, dir := range dirs {
+		dirRequests, errs := LoadCertificateRequestsFromDir(dir)
+		for _, err := range errs {
+			logrus.Warnf("Skip certificate request: %v", err)
+		}
+		requests = append(requests, dirRequests...)
+	}
+	for i, values := range inline {
+		req, err := BuildCertificateRequest(values)
+		if err != nil {
+			logrus.Warnf("Skip inline certificate request #%d: %v", i, err)
+			continue
+		}
+		requests = append(requests, req)
+	}
+
+	req, ok := IndexRequestsByOutCertPath(requests)[absPath(path)]
+	if !ok {
+		return CertificateRequest{}, fmt.Errorf(format.WrapErrorString, ErrRequestNotFound, path)
+	}
+	return req, nil
+}
+
+// RenewCertificate regenerates the certificate written to path by one of the configured requests, whatever its
+// expiry.
+var RenewCertificate = func(path string) error {
+	req, err := FindRequestByOutCertPath(config.CertificateRequestsPaths, config.CertificateRequestsInline, path)
+	if err != nil {
+		return err
+	}
+	issuer, err := LoadIssuer(req.IssuerPath)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrRenewFailed, err)
+	}
+	if err := MakeParentsDirectories(req.OutCertPath); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrRenewFailed, err)
+	}
+	RequestLogger(req).Infof("Renew certificate %s", req.OutCertPath)
+	if !generate(req, issuer) {
+		return fmt.Errorf(format.WrapErrorString, ErrRenewFailed, req.OutCertPath)
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
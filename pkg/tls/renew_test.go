@@ -0,0 +1,100 @@
+package tls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+func TestIndexRequestsByOutCertPath(t *testing.T) {
+	server := CertificateRequest{OutCertPath: "tls/server.crt"}
+	client := CertificateRequest{OutCertPath: "tls/client.crt", PrivateKeys: []PrivateKey{{Algorithm: RSA}, {Algorithm: ECDSA}}}
+	disabled := CertificateRequest{OutCertPath: "tls/disabled.crt", Disabled: true}
+
+	index := IndexRequestsByOutCertPath([]CertificateRequest{server, client, disabled})
+
+	require.Len(t, index, 3)
+	assert.Equal(t, server, index[absPath("tls/server.crt")])
+	assert.Equal(t, ECDSA, index[absPath("tls/client.ecdsa.crt")].PrivateKey.Algorithm)
+	assert.Equal(t, RSA, index[absPath("tls/client.rsa.crt")].PrivateKey.Algorithm)
+	assert.NotContains(t, index, absPath("tls/disabled.crt"))
+}
+
+func TestRenewCertificate(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := t.TempDir()
+	requestsDir := filepath.Join(dir, "requests")
+	require.NoError(t, os.Mkdir(requestsDir, 0o755))
+	for _, name := range []string{"server", "client"} {
+		request := "out:\n  dir: " + filepath.Join(dir, name) + "\ncommonName: " + name + "\nduration: 24h\nprivateKey:\n  algorithm: ed25519\n"
+		require.NoError(t, os.WriteFile(filepath.Join(requestsDir, name+".yaml"), []byte(request), 0o600))
+	}
+	mock(t, &config.CertificateRequestsPaths, []string{requestsDir})
+	inline := []map[string]any{{"out": map[string]any{"dir": filepath.Join(dir, "inline")}, "commonName": "inline", "duration": "24h"}}
+	mock(t, &config.CertificateRequestsInline, inline)
+	LoadCertificateRequests(requestsDir)
+	HandleInlineCertificateRequests(config.CertificateRequestsInline)
+	previousSerials := make(map[string]string)
+	for _, name := range []string{"server", "client", "inline"} {
+		cert, err := LoadCertFromFile(filepath.Join(dir, name, "tls.crt"))
+		require.NoError(t, err)
+		previousSerials[name] = SerialNumber(cert)
+	}
+
+	err := RenewCertificate(filepath.Join(dir, "server", "tls.crt"))
+
+	require.NoError(t, err)
+	for name, expectedRenewed := range map[string]bool{"server": true, "client": false, "inline": false} {
+		cert, err := LoadCertFromFile(filepath.Join(dir, name, "tls.crt"))
+		require.NoError(t, err)
+		assert.Equal(t, expectedRenewed, SerialNumber(cert) != previousSerials[name], name)
+		assert.Equal(t, name, cert.Subject.CommonName)
+	}
+}
+
+func TestRenewCertificate_WithInlineRequest(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := t.TempDir()
+	mock(t, &config.CertificateRequestsPaths, nil)
+	mock(t, &config.CertificateRequestsInline, []map[string]any{{"out": map[string]any{"dir": dir}, "commonName": "inline"}})
+	var renewed CertificateRequest
+	mock(t, &GenerateOutFilesFromRequest, func(req CertificateRequest, _ *Issuer) { renewed = req })
+
+	err := RenewCertificate(filepath.Join(dir, "tls.crt"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "inline", renewed.CommonName)
+}
+
+func TestRenewCertificate_WithUnknownPath(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	mock(t, &config.CertificateRequestsPaths, []string{"testdata/requests"})
+	mock(t, &config.CertificateRequestsInline, nil)
+	mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { t.Error("unexpected generation") })
+
+	err := RenewCertificate(filepath.Join(t.TempDir(), "unknown.crt"))
+
+	assert.ErrorIs(t, err, ErrRequestNotFound)
+}
+
+func TestRenewCertificate_WithGenerationFailure(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := t.TempDir()
+	mock(t, &config.CertificateRequestsPaths, nil)
+	mock(t, &config.CertificateRequestsInline, []map[string]any{{"out": map[string]any{"dir": dir}, "commonName": "inline"}})
+	mock(t, &GenerateOutFilesFromRequest, func(req CertificateRequest, _ *Issuer) { generationFailures.add(req.OutCertPath) })
+
+	err := RenewCertificate(filepath.Join(dir, "tls.crt"))
+
+	assert.ErrorIs(t, err, ErrRenewFailed)
+}
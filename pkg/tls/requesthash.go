@@ -0,0 +1,91 @@
+package tls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var ErrWriteRequestHash = errors.New("write request hash")
+
+// RequestHash is the sidecar of a generated certificate, it records the request the certificate was generated from.
+type RequestHash struct {
+	Hash         string    `json:"hash"`
+	NotAfter     time.Time `json:"notAfter"`
+	SerialNumber *big.Int  `json:"serialNumber,omitempty"`
+}
+
+// HashRequest identifies the request together with the issuer certificate, so that a CA change is not skipped.
+func HashRequest(req CertificateRequest, issuer *Issuer) (string, error) {
+	// Reusing the key or not does not change the certificate, regenerate: certOnly forces it
+	req.PrivateKey.Reuse = false
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(b)
+	if issuer != nil {
+		h.Write(issuer.PublicKey.Raw)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+var WriteRequestHash = func(requestHash RequestHash, path string) error {
+	b, err := json.Marshal(requestHash)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteRequestHash, err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteRequestHash, err)
+	}
+	return nil
+}
+
+func readRequestHash(path string) (RequestHash, error) {
+	var requestHash RequestHash
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return requestHash, err
+	}
+	err = json.Unmarshal(b, &requestHash)
+	return requestHash, err
+}
+
+// unchangedRequest tells from the sidecar alone, without parsing the certificate, that the certificate was generated
+// from the same request and issuer and does not need renewal yet.
+func unchangedRequest(req CertificateRequest, issuer *Issuer) bool {
+	if req.OutRequestHashPath == "" {
+		return false
+	}
+	previous, err := readRequestHash(req.OutRequestHashPath)
+	if err != nil {
+		return false
+	}
+	hash, err := HashRequest(req, issuer)
+	if err != nil || hash != previous.Hash {
+		return false
+	}
+	// The renewal time is spread from the serial number, missing in the sidecars written before it was recorded
+	if previous.SerialNumber == nil && req.RenewSpread > 0 {
+		return false
+	}
+	cert := &x509.Certificate{SerialNumber: previous.SerialNumber, NotAfter: previous.NotAfter}
+	return RenewalTime(req, cert).After(time.Now())
+}
+
+func writeRequestHash(req CertificateRequest, cert *x509.Certificate, issuer *Issuer) error {
+	hash, err := HashRequest(req, issuer)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteRequestHash, err)
+	}
+	return WriteRequestHash(RequestHash{Hash: hash, NotAfter: cert.NotAfter, SerialNumber: cert.SerialNumber}, req.OutRequestHashPath)
+}
@@ -0,0 +1,136 @@
+package tls
+
+import (
+	"crypto/x509"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCertificateRequestFile_WithRequestHash(t *testing.T) {
+	for name, tt := range map[string]struct {
+		change        func(req *CertificateRequest)
+		expectedLoads int
+	}{
+		"Unchanged request skips the certificate parse": {
+			change:        func(_ *CertificateRequest) {},
+			expectedLoads: 0,
+		},
+		"Changed request parses the certificate": {
+			change:        func(req *CertificateRequest) { req.DNSNames = []string{"example.com"} },
+			expectedLoads: 1,
+		},
+		"Certificate near expiry parses the certificate": {
+			change:        func(req *CertificateRequest) { req.RenewBefore = 2 * time.Hour },
+			expectedLoads: 1,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			loggerOutput()
+			dir := t.TempDir()
+			req := CertificateRequest{
+				OutCAPath:          filepath.Join(dir, "ca.crt"),
+				OutCertPath:        filepath.Join(dir, "tls.crt"),
+				OutKeyPath:         filepath.Join(dir, "tls.key"),
+				OutRequestHashPath: filepath.Join(dir, "request.json"),
+				CommonName:         "test",
+				Duration:           time.Hour,
+				PrivateKey:         PrivateKey{Algorithm: ED25519},
+			}
+			GenerateOutFilesFromRequest(req, nil)
+			tc.change(&req)
+			mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+			loads := 0
+			loadCertFromFile := LoadCertFromFile
+			mock(t, &LoadCertFromFile, func(file string) (*x509.Certificate, error) {
+				loads++
+				return loadCertFromFile(file)
+			})
+
+			HandleCertificateRequestFile("valid.yaml")
+
+			assert.Equal(t, tc.expectedLoads, loads)
+		})
+	}
+}
+
+func TestGenerateOutFilesFromRequest_WithRequestHash(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:          filepath.Join(dir, "ca.crt"),
+		OutCertPath:        filepath.Join(dir, "tls.crt"),
+		OutKeyPath:         filepath.Join(dir, "tls.key"),
+		OutRequestHashPath: filepath.Join(dir, "request.json"),
+		Duration:           time.Hour,
+		PrivateKey:         PrivateKey{Algorithm: ED25519},
+	}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	requestHash, err := readRequestHash(req.OutRequestHashPath)
+	require.NoError(t, err)
+	expectedHash, err := HashRequest(req, issuer)
+	require.NoError(t, err)
+	assert.Equal(t, expectedHash, requestHash.Hash)
+	assert.True(t, cert.NotAfter.Equal(requestHash.NotAfter))
+	assert.Equal(t, cert.SerialNumber, requestHash.SerialNumber)
+	otherHash, err := HashRequest(req, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, expectedHash, otherHash)
+}
+
+func TestUnchangedRequest_WithRenewBeforeSpread(t *testing.T) {
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutRequestHashPath: filepath.Join(dir, "request.json"),
+		RenewBefore:        time.Hour,
+		RenewSpread:        24 * time.Hour,
+	}
+	hash, err := HashRequest(req, nil)
+	require.NoError(t, err)
+	serial := big.NewInt(1234)
+	// Offset of the renewal time moved earlier by the spread for this serial number
+	offset := time.Time{}.Add(-req.RenewBefore).Sub(RenewalTime(req, &x509.Certificate{SerialNumber: serial}))
+	require.Greater(t, offset, time.Minute)
+
+	for name, tt := range map[string]struct {
+		requestHash RequestHash
+		expected    bool
+	}{
+		"Before the spread renewal time": {
+			requestHash: RequestHash{Hash: hash, SerialNumber: serial, NotAfter: time.Now().Add(req.RenewBefore + offset + time.Minute)},
+			expected:    true,
+		},
+		"After the spread renewal time": {
+			requestHash: RequestHash{Hash: hash, SerialNumber: serial, NotAfter: time.Now().Add(req.RenewBefore + offset - time.Minute)},
+			expected:    false,
+		},
+		"Without serial number": {
+			requestHash: RequestHash{Hash: hash, NotAfter: time.Now().Add(req.RenewBefore + req.RenewSpread + time.Hour)},
+			expected:    false,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, WriteRequestHash(tc.requestHash, req.OutRequestHashPath))
+
+			assert.Equal(t, tc.expected, unchangedRequest(req, nil))
+		})
+	}
+}
+
+func TestWriteRequestHash_WithError(t *testing.T) {
+	err := WriteRequestHash(RequestHash{}, filepath.Join(t.TempDir(), "missing", "request.json"))
+
+	assert.ErrorIs(t, err, ErrWriteRequestHash)
+}
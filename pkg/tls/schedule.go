@@ -0,0 +1,63 @@
+package tls
+
+import (
+	"sync"
+	"time"
+)
+
+// requestTimers re-handles the requests checked more often than the global interval, each on its own timer.
+var requestTimers = &timers{timers: make(map[string]*time.Timer)}
+
+type timers struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// schedule calls handle after the request check interval when it is shorter than the delay until the next periodic
+// pass, and cancels the previous timer of the request: each handling of the request postpones its next check.
+func (t *timers) schedule(key string, checkInterval time.Duration, handle func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if timer, ok := t.timers[key]; ok {
+		timer.Stop()
+		delete(t.timers, key)
+	}
+	if checkInterval <= 0 || checkInterval >= globalInterval() {
+		return
+	}
+	t.timers[key] = time.AfterFunc(checkInterval, handle)
+}
+
+func (t *timers) stopAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, timer := range t.timers {
+		timer.Stop()
+		delete(t.timers, key)
+	}
+}
+
+// requestLocks serializes the handlings of a same request by the periodic pass, the watcher and the check interval
+// timer, which run on their own goroutines and would otherwise write the same out files concurrently.
+var requestLocks = &locks{locks: make(map[string]*sync.Mutex)}
+
+type locks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock waits for the lock of the request and returns the function releasing it.
+func (l *locks) lock(key string) func() {
+	l.mu.Lock()
+	m, ok := l.locks[key]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[key] = m
+	}
+	l.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
@@ -0,0 +1,157 @@
+package tls
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+func TestTimersSchedule(t *testing.T) {
+	config.Interval = time.Minute
+	timers := &timers{timers: make(map[string]*time.Timer)}
+	t.Cleanup(timers.stopAll)
+	var count atomic.Int32
+	var handle func()
+	handle = func() {
+		count.Add(1)
+		timers.schedule("request", 10*time.Millisecond, handle)
+	}
+
+	timers.schedule("request", 10*time.Millisecond, handle)
+	time.Sleep(55 * time.Millisecond)
+	timers.stopAll()
+	stopped := count.Load()
+	time.Sleep(30 * time.Millisecond)
+
+	assert.GreaterOrEqual(t, stopped, int32(3))
+	assert.Equal(t, stopped, count.Load())
+}
+
+func TestTimersSchedule_WithoutShortInterval(t *testing.T) {
+	for name, tt := range map[string]struct {
+		checkInterval time.Duration
+	}{
+		"No check interval":           {checkInterval: 0},
+		"Equal to global interval":    {checkInterval: 50 * time.Millisecond},
+		"Longer than global interval": {checkInterval: time.Minute},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			config.Interval = 50 * time.Millisecond
+			timers := &timers{timers: make(map[string]*time.Timer)}
+
+			timers.schedule("request", tc.checkInterval, func() { t.Error("unexpected call") })
+
+			assert.Empty(t, timers.timers)
+		})
+	}
+}
+
+func TestTimersSchedule_CancelsPreviousTimer(t *testing.T) {
+	config.Interval = time.Minute
+	timers := &timers{timers: make(map[string]*time.Timer)}
+	called := make(chan string, 2)
+
+	timers.schedule("request", 20*time.Millisecond, func() { called <- "first" })
+	timers.schedule("request", 10*time.Millisecond, func() { called <- "second" })
+	time.Sleep(40 * time.Millisecond)
+	timers.schedule("request", 0, nil)
+
+	assert.Equal(t, "second", <-called)
+	assert.Empty(t, called)
+	assert.Empty(t, timers.timers)
+}
+
+func TestTimersSchedule_WithSchedule(t *testing.T) {
+	config.Interval = time.Hour
+	mockSchedule(t, "* * * * *")
+	// The next scheduled pass is 10 seconds away, whatever the interval
+	mock(t, &now, func() time.Time { return time.Date(2026, time.October, 16, 10, 40, 50, 0, time.UTC) })
+	timers := &timers{timers: make(map[string]*time.Timer)}
+	t.Cleanup(timers.stopAll)
+
+	timers.schedule("slow", 20*time.Second, func() { t.Error("unexpected call") })
+	timers.schedule("fast", 5*time.Second, func() {})
+
+	assert.NotContains(t, timers.timers, "slow")
+	assert.Contains(t, timers.timers, "fast")
+}
+
+func TestHandleCertificateRequestFile_Serialized(t *testing.T) {
+	loggerOutput()
+	var active, maxActive atomic.Int32
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			current := maxActive.Load()
+			if n <= current || maxActive.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return CertificateRequest{Disabled: true}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handleCertificateRequestFile("valid.yaml")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxActive.Load())
+}
+
+func TestStart_WithCheckInterval(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	fast := filepath.Join(dir, "fast.yaml")
+	slow := filepath.Join(dir, "slow.yaml")
+	require.NoError(t, os.WriteFile(fast, nil, 0600))
+	require.NoError(t, os.WriteFile(slow, nil, 0600))
+	config.Interval = 200 * time.Millisecond
+	config.CertificateRequestsPaths = []string{dir}
+	var mu sync.Mutex
+	loads := make(map[string]int)
+	mock(t, &LoadCertificateRequest, func(file string) (CertificateRequest, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads[file]++
+		req := CertificateRequest{OutCertPath: filepath.Join(dir, "tls", "tls.crt")}
+		if file == fast {
+			req.CheckInterval = 30 * time.Millisecond
+		}
+		return req, nil
+	})
+	mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) {
+		// Lock so that the mock is not restored while a request timer is still running
+		mu.Lock()
+		defer mu.Unlock()
+	})
+	mock(t, &HandleInlineCertificateRequests, func(_ []map[string]any) bool { return true })
+
+	stop := Start()
+	time.Sleep(300 * time.Millisecond)
+	stop()
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, loads[slow])
+	assert.GreaterOrEqual(t, loads[fast], 6)
+	requestTimers.mu.Lock()
+	defer requestTimers.mu.Unlock()
+	assert.Empty(t, requestTimers.timers)
+}
@@ -0,0 +1,114 @@
+package tls
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+const (
+	SerialNumberSourceRandom = "random"
+	SerialNumberSourceFile   = "file"
+)
+
+var (
+	ErrUnsupportedSerialNumberSource = errors.New("unsupported serial number source")
+	ErrOpenSerialNumberFile          = errors.New("open serial number file")
+	ErrLockSerialNumberFile          = errors.New("lock serial number file")
+	ErrReadSerialNumberFile          = errors.New("read serial number file")
+	ErrInvalidSerialNumber           = errors.New("invalid serial number")
+	ErrWriteSerialNumberFile         = errors.New("write serial number file")
+)
+
+// serialNumberFileMutex serializes generations within the process, the file lock protects against other processes.
+var serialNumberFileMutex sync.Mutex
+
+var NextSerialNumber = func(source SerialNumberSource) (*big.Int, error) {
+	switch strings.ToLower(source.Type) {
+	case "", SerialNumberSourceRandom:
+		return randomSerialNumber()
+	case SerialNumberSourceFile:
+		return nextSerialNumberFromFile(source.Path)
+	default:
+		return nil, fmt.Errorf(format.WrapErrorString, ErrUnsupportedSerialNumberSource, source.Type)
+	}
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	return rand.Int(randReader, serialNumberLimit)
+}
+
+// nextSerialNumberFromFile increments the counter stored at path. The new value is written to a temporary file which
+// is synced and renamed over the counter, so that a crash never leaves an empty or stale counter behind. The lock is
+// held on a separate file as the counter itself is replaced on each increment.
+func nextSerialNumberFromFile(path string) (*big.Int, error) {
+	serialNumberFileMutex.Lock()
+	defer serialNumberFileMutex.Unlock()
+
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrOpenSerialNumberFile, err)
+	}
+	defer func() { _ = lock.Close() }()
+
+	if err := lockFile(lock); err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrLockSerialNumberFile, err)
+	}
+	defer func() { _ = unlockFile(lock) }()
+
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf(format.WrapErrors, ErrReadSerialNumberFile, err)
+	}
+
+	serialNumber := big.NewInt(0)
+	if s := strings.TrimSpace(string(b)); s != "" {
+		if _, ok := serialNumber.SetString(s, 10); !ok || serialNumber.Sign() < 0 {
+			return nil, fmt.Errorf(format.WrapErrorString, ErrInvalidSerialNumber, s)
+		}
+	}
+	serialNumber.Add(serialNumber, big.NewInt(1))
+
+	if err := replaceFile(path, []byte(serialNumber.String()+"\n")); err != nil {
+		return nil, fmt.Errorf(format.WrapErrors, ErrWriteSerialNumberFile, err)
+	}
+
+	return serialNumber, nil
+}
+
+func replaceFile(path string, content []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
@@ -0,0 +1,117 @@
+package tls
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextSerialNumber_Random(t *testing.T) {
+	for name, source := range map[string]SerialNumberSource{
+		"Default": {},
+		"Random":  {Type: "random"},
+	} {
+		tc := source // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			serialNumber, err := NextSerialNumber(tc)
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, serialNumber.Sign())
+		})
+	}
+}
+
+func TestNextSerialNumber_WithDeterministicRandReader(t *testing.T) {
+	mock(t, &randReader, deterministicReader())
+	first, err := NextSerialNumber(SerialNumberSource{})
+	require.NoError(t, err)
+	mock(t, &randReader, deterministicReader())
+	second, err := NextSerialNumber(SerialNumberSource{})
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+}
+
+func TestNextSerialNumber_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serial")
+	source := SerialNumberSource{Type: "file", Path: path}
+
+	first, err := NextSerialNumber(source)
+	require.NoError(t, err)
+	second, err := NextSerialNumber(source)
+	require.NoError(t, err)
+
+	assert.Equal(t, big.NewInt(1), first)
+	assert.Equal(t, big.NewInt(2), second)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2\n", string(content))
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"serial", "serial.lock"}, names)
+}
+
+func TestNextSerialNumber_FileConcurrently(t *testing.T) {
+	source := SerialNumberSource{Type: "file", Path: filepath.Join(t.TempDir(), "serial")}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := map[string]bool{}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serialNumber, err := NextSerialNumber(source)
+			assert.NoError(t, err)
+			mu.Lock()
+			seen[serialNumber.String()] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, 20)
+}
+
+func TestNextSerialNumber_WithError(t *testing.T) {
+	invalid := filepath.Join(t.TempDir(), "invalid")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a number"), 0600))
+
+	for name, tt := range map[string]struct {
+		source        SerialNumberSource
+		expectedError error
+	}{
+		"Unsupported source": {
+			source:        SerialNumberSource{Type: "invalid"},
+			expectedError: ErrUnsupportedSerialNumberSource,
+		},
+		"Open file error": {
+			source:        SerialNumberSource{Type: "file", Path: "dir/unknown"},
+			expectedError: ErrOpenSerialNumberFile,
+		},
+		"Read file error": {
+			source:        SerialNumberSource{Type: "file", Path: t.TempDir()},
+			expectedError: ErrReadSerialNumberFile,
+		},
+		"Invalid serial number": {
+			source:        SerialNumberSource{Type: "file", Path: invalid},
+			expectedError: ErrInvalidSerialNumber,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			_, err := NextSerialNumber(tc.source)
+
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+// backupSuffix is appended to the staged file name of an output file moved aside during its promotion.
+const backupSuffix = ".backup"
+
+var (
+	ErrCreateStagingDir   = errors.New("create staging directory")
+	ErrStagedFileConflict = errors.New("output files with the same name cannot be staged")
+	ErrPromoteStagedFiles = errors.New("promote staged files")
+)
+
+// outputPaths lists every file written while generating a request.
+func outputPaths(req *CertificateRequest) []*string {
+	return []*string{&req.OutKeyPath, &req.OutKeyDERPath, &req.OutPublicKeyPath, &req.OutCertPath, &req.OutCAPath, &req.OutChainPath, &req.OutFullChainPath, &req.OutTextPath, &req.OutBundlePath, &req.OutP7BPath}
+}
+
+var CreateStagingDir = func(req CertificateRequest) (string, error) {
+	// The staging directory is created next to the output files so that promotion is a rename on the same filesystem
+	dir, err := FS.MkdirTemp(filepath.Dir(req.OutCertPath), ".staging-")
+	if err != nil {
+		return "", fmt.Errorf(format.WrapErrors, ErrCreateStagingDir, err)
+	}
+	return dir, nil
+}
+
+// StageRequest moves the output files of the request to the staging directory, under their base name. It fails when
+// two different output files share a base name, as they would overwrite each other.
+func StageRequest(req CertificateRequest, stagingDir string) (CertificateRequest, error) {
+	staged := req
+	origins := make(map[string]string)
+	for _, path := range outputPaths(&staged) {
+		if *path == "" {
+			continue
+		}
+		name := filepath.Base(*path)
+		if origin, ok := origins[name]; ok && origin != *path {
+			return CertificateRequest{}, fmt.Errorf("%w: %s and %s", ErrStagedFileConflict, origin, *path)
+		}
+		origins[name] = *path
+		*path = filepath.Join(stagingDir, name)
+	}
+	return staged, nil
+}
+
+func copyFileIfExists(from, to string) error {
+	b, err := FS.ReadFile(from)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrReadFile, err)
+	}
+	file, err := FS.Create(to)
+	if err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrCreateFile, err)
+	}
+	if _, err := file.Write(b); err != nil {
+		_ = file.Close()
+		return fmt.Errorf(format.WrapErrors, ErrCreateFile, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrCreateFile, err)
+	}
+	return nil
+}
+
+// promotedFile is an output file replaced by its staged version, with the path the former file was moved to, if any.
+type promotedFile struct {
+	path   string
+	backup string
+}
+
+// PromoteStagedFiles renames the staged files over the output files. The former output files are first moved aside in
+// the staging directory, so that a failure rolls back the files already promoted and leaves the previous generation
+// in place.
+var PromoteStagedFiles = func(staged, req CertificateRequest) error {
+	stagedPaths := outputPaths(&staged)
+	var promoted []promotedFile
+	for i, path := range outputPaths(&req) {
+		from := *stagedPaths[i]
+		if from == "" || FileDoesNotExists(from) {
+			continue
+		}
+		file := promotedFile{path: *path}
+		if !FileDoesNotExists(*path) {
+			file.backup = from + backupSuffix
+			if err := FS.Rename(*path, file.backup); err != nil {
+				return fmt.Errorf(format.WrapErrors, ErrPromoteStagedFiles, errors.Join(err, rollbackPromotedFiles(promoted)))
+			}
+		}
+		if err := FS.Rename(from, *path); err != nil {
+			return fmt.Errorf(format.WrapErrors, ErrPromoteStagedFiles, errors.Join(err, rollbackPromotedFiles(append(promoted, file))))
+		}
+		promoted = append(promoted, file)
+	}
+	return nil
+}
+
+// rollbackPromotedFiles restores the former output files in the reverse order of their promotion, and removes the
+// promoted files which did not replace any.
+func rollbackPromotedFiles(promoted []promotedFile) error {
+	var errs []error
+	for i := len(promoted) - 1; i >= 0; i-- {
+		file := promoted[i]
+		if file.backup == "" {
+			if err := FS.Remove(file.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := FS.Rename(file.backup, file.path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+package tls
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStageRequest(t *testing.T) {
+	req := CertificateRequest{OutKeyPath: "out/tls.key", OutCertPath: "out/tls.crt", OutCAPath: "out/ca.crt", CommonName: "test"}
+
+	staged, err := StageRequest(req, "out/.staging-1")
+
+	require.NoError(t, err)
+	expected := CertificateRequest{OutKeyPath: "out/.staging-1/tls.key", OutCertPath: "out/.staging-1/tls.crt", OutCAPath: "out/.staging-1/ca.crt", CommonName: "test"}
+	assert.Equal(t, expected, staged)
+	assert.Equal(t, "out/tls.key", req.OutKeyPath)
+}
+
+func TestStageRequest_WithConflictingNames(t *testing.T) {
+	req := CertificateRequest{OutKeyPath: "out/tls.key", OutCertPath: "out/server/tls.crt", OutCAPath: "out/client/tls.crt"}
+
+	_, err := StageRequest(req, "out/.staging-1")
+
+	assert.ErrorIs(t, err, ErrStagedFileConflict)
+	assert.ErrorContains(t, err, "out/server/tls.crt and out/client/tls.crt")
+}
+
+func TestPromoteStagedFiles(t *testing.T) {
+	dir := t.TempDir()
+	req := CertificateRequest{OutKeyPath: filepath.Join(dir, "tls.key"), OutCertPath: filepath.Join(dir, "tls.crt"), OutCAPath: filepath.Join(dir, "ca.crt")}
+	stagingDir, err := CreateStagingDir(req)
+	require.NoError(t, err)
+	staged, err := StageRequest(req, stagingDir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(staged.OutKeyPath, []byte("new key"), 0600))
+	require.NoError(t, os.WriteFile(staged.OutCertPath, []byte("new cert"), 0600))
+	require.NoError(t, os.WriteFile(req.OutCAPath, []byte("old ca"), 0600))
+
+	err = PromoteStagedFiles(staged, req)
+
+	require.NoError(t, err)
+	assertFileContent(t, "new key", req.OutKeyPath)
+	assertFileContent(t, "new cert", req.OutCertPath)
+	assertFileContent(t, "old ca", req.OutCAPath)
+}
+
+func TestPromoteStagedFiles_WithRenameError(t *testing.T) {
+	memFS := newMemFileSystem(t)
+	req := CertificateRequest{OutKeyPath: "out/tls.key", OutCertPath: "out/tls.crt", OutCAPath: "out/ca.crt"}
+	stagingDir, err := CreateStagingDir(req)
+	require.NoError(t, err)
+	staged, err := StageRequest(req, stagingDir)
+	require.NoError(t, err)
+	memFS.files[staged.OutKeyPath] = &fstest.MapFile{Data: []byte("new key")}
+	memFS.files[staged.OutCertPath] = &fstest.MapFile{Data: []byte("new cert")}
+	memFS.files[staged.OutCAPath] = &fstest.MapFile{Data: []byte("new ca")}
+	memFS.files[req.OutCertPath] = &fstest.MapFile{Data: []byte("old cert")}
+	memFS.files[req.OutCAPath] = &fstest.MapFile{Data: []byte("old ca")}
+	renameErr := errors.New("rename error")
+	mock(t, &FS, FileSystem(failingRenameFileSystem{memFileSystem: memFS, oldpath: staged.OutCAPath, err: renameErr}))
+
+	err = PromoteStagedFiles(staged, req)
+
+	assert.ErrorIs(t, err, ErrPromoteStagedFiles)
+	assert.ErrorIs(t, err, renameErr)
+	assert.NotContains(t, memFS.files, req.OutKeyPath)
+	assert.Equal(t, "old cert", string(memFS.files[req.OutCertPath].Data))
+	assert.Equal(t, "old ca", string(memFS.files[req.OutCAPath].Data))
+}
+
+func TestCopyFileIfExists(t *testing.T) {
+	memFS := newMemFileSystem(t)
+	memFS.files["out/tls.key"] = &fstest.MapFile{Data: []byte("key")}
+
+	require.NoError(t, copyFileIfExists("out/tls.key", "out/tls.key.prev"))
+	require.NoError(t, copyFileIfExists("out/unknown", "out/unknown.prev"))
+
+	assert.Equal(t, "key", string(memFS.files["out/tls.key.prev"].Data))
+	assert.NotContains(t, memFS.files, "out/unknown.prev")
+}
+
+func TestCreateStagingDir_WithError(t *testing.T) {
+	_, err := CreateStagingDir(CertificateRequest{OutCertPath: "unknown/tls.crt"})
+
+	assert.ErrorIs(t, err, ErrCreateStagingDir)
+}
+
+func assertFileContent(t *testing.T, expected, file string) {
+	actual, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(actual))
+}
+
+// failingRenameFileSystem fails the renames of a single file.
+type failingRenameFileSystem struct {
+	*memFileSystem
+	oldpath string
+	err     error
+}
+
+func (f failingRenameFileSystem) Rename(oldpath, newpath string) error {
+	if oldpath == f.oldpath {
+		return f.err
+	}
+	return f.memFileSystem.Rename(oldpath, newpath)
+}
@@ -0,0 +1,117 @@
+package tls
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+type CertificateStatus struct {
+	Request               string        `json:"request"`
+	OutCertPath           string        `json:"outCertPath,omitempty"`
+	Exists                bool          `json:"exists"`
+	NotAfter              *time.Time    `json:"notAfter,omitempty"`
+	Fingerprint           string        `json:"fingerprint,omitempty"`
+	SelfSigned            bool          `json:"selfSigned"`
+	LegacySHA1Fingerprint string        `json:"legacySHA1Fingerprint,omitempty"`
+	RenewAt               *time.Time    `json:"renewAt,omitempty"`
+	RenewIn               time.Duration `json:"-"`
+	Error                 string        `json:"error,omitempty"`
+}
+
+// GetCertificateStatuses returns the status of the certificates of the request files of the directories and of the
+// inline requests.
+var GetCertificateStatuses = func(dirs []string, inline []map[string]any) []CertificateStatus {
+	var statuses []CertificateStatus
+	for _, dir := range dirs {
+		files, err := ReadDir(dir)
+		if err != nil {
+			statuses = append(statuses, CertificateStatus{Request: dir, Error: err.Error()})
+			continue
+		}
+		for _, file := range files {
+			// Handle only certificate request files
+			if !config.IsCertificateRequestFile(file) {
+				continue
+			}
+			statuses = append(statuses, GetCertificateStatus(file)...)
+		}
+	}
+	for i, values := range inline {
+		req, err := BuildCertificateRequest(values)
+		statuses = append(statuses, certificateStatuses(fmt.Sprintf("inline #%d", i), req, err)...)
+	}
+	return statuses
+}
+
+// GetCertificateStatus returns the status of the certificate of each key pair of the request file, none when the
+// request is disabled and its certificates not managed.
+var GetCertificateStatus = func(file string) []CertificateStatus {
+	req, err := LoadCertificateRequest(file)
+	return certificateStatuses(file, req, err)
+}
+
+func certificateStatuses(name string, req CertificateRequest, err error) []CertificateStatus {
+	if err != nil {
+		return []CertificateStatus{{Request: name, Error: err.Error()}}
+	}
+	if req.Disabled {
+		return nil
+	}
+	var statuses []CertificateStatus
+	for _, variant := range KeyVariants(req) {
+		statuses = append(statuses, keyVariantStatus(name, variant))
+	}
+	return statuses
+}
+
+func keyVariantStatus(name string, req CertificateRequest) CertificateStatus {
+	status := CertificateStatus{Request: name, OutCertPath: req.OutCertPath}
+
+	if FileDoesNotExists(req.OutCertPath) {
+		return status
+	}
+	status.Exists = true
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	renewAt := RenewalTime(req, cert)
+	status.NotAfter = &cert.NotAfter
+	status.Fingerprint = Fingerprint(cert)
+	status.SelfSigned = IsSelfSigned(cert)
+	if config.LegacySHA1Fingerprint {
+		status.LegacySHA1Fingerprint = LegacySHA1Fingerprint(cert)
+	}
+	status.RenewAt = &renewAt
+	status.RenewIn = time.Until(renewAt).Round(time.Second)
+
+	return status
+}
+
+// LogCertificateStatuses logs a snapshot of the status of every managed certificate, for live debugging.
+var LogCertificateStatuses = func(dirs []string, inline []map[string]any) {
+	statuses := GetCertificateStatuses(dirs, inline)
+	logrus.Infof("Status of %d certificate request(s)", len(statuses))
+	for _, s := range statuses {
+		fields := logrus.Fields{"request": s.Request, "exists": s.Exists}
+		if s.OutCertPath != "" {
+			fields["cert"] = s.OutCertPath
+		}
+		if s.NotAfter != nil {
+			fields["notAfter"] = s.NotAfter.Format(time.RFC3339)
+			fields["renewAt"] = s.RenewAt.Format(time.RFC3339)
+			fields["selfSigned"] = s.SelfSigned
+		}
+		if s.Error != "" {
+			logrus.WithFields(fields).WithField("error", s.Error).Warn("Certificate status")
+			continue
+		}
+		logrus.WithFields(fields).Info("Certificate status")
+	}
+}
@@ -0,0 +1,204 @@
+package tls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+func TestGetCertificateStatuses(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := t.TempDir()
+	valid := writeStatusRequest(t, dir, "valid", "24h", "1h")
+	expired := writeStatusRequest(t, dir, "expired", "-1h", "1h")
+	missing := writeStatusRequest(t, dir, "missing", "24h", "1h")
+	disabled := writeStatusRequest(t, dir, "disabled", "24h", "1h")
+	content, err := os.ReadFile(disabled)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(disabled, append(content, "enabled: false\n"...), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), nil, 0600))
+	generateStatusCertificate(t, dir, "valid", 24*time.Hour)
+	generateStatusCertificate(t, dir, "expired", -time.Hour)
+
+	statuses := GetCertificateStatuses([]string{dir}, nil)
+
+	require.Len(t, statuses, 3)
+	assert.Equal(t, expired, statuses[0].Request)
+	assert.True(t, statuses[0].Exists)
+	assert.True(t, statuses[0].NotAfter.Before(time.Now()))
+	assert.Less(t, statuses[0].RenewIn, -time.Hour)
+	assert.Empty(t, statuses[0].Error)
+	assert.True(t, statuses[0].SelfSigned)
+	assert.Equal(t, CertificateStatus{Request: missing, OutCertPath: filepath.Join(dir, "missing", "tls.crt")}, statuses[1])
+	assert.Equal(t, valid, statuses[2].Request)
+	assert.True(t, statuses[2].Exists)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *statuses[2].NotAfter, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(23*time.Hour), *statuses[2].RenewAt, time.Minute)
+	assert.InDelta(t, 23*time.Hour, statuses[2].RenewIn, float64(time.Minute))
+}
+
+func TestGetCertificateStatuses_WithError(t *testing.T) {
+	viper.Reset()
+
+	statuses := GetCertificateStatuses([]string{"testdata/unknown", "testdata/requests"}, nil)
+
+	require.Len(t, statuses, 3)
+	assert.Equal(t, "testdata/unknown", statuses[0].Request)
+	assert.Contains(t, statuses[0].Error, ErrReadDir.Error())
+	assert.Equal(t, CertificateStatus{Request: "testdata/requests/test1.yaml", OutCertPath: "testdata/tls/server.crt"}, statuses[1])
+}
+
+func TestGetCertificateStatuses_WithInline(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	inline := []map[string]any{
+		{"out": map[string]any{"dir": dir}, "commonName": "inline"},
+		{"out": map[string]any{"dir": dir}, "commonName": "disabled", "enabled": false},
+		{"commonName": "invalid"},
+	}
+
+	statuses := GetCertificateStatuses(nil, inline)
+
+	require.Len(t, statuses, 2)
+	assert.Equal(t, CertificateStatus{Request: "inline #0", OutCertPath: filepath.Join(dir, "tls.crt")}, statuses[0])
+	assert.Equal(t, "inline #2", statuses[1].Request)
+	assert.Contains(t, statuses[1].Error, ErrMissingMandatoryField.Error())
+}
+
+func TestLogCertificateStatuses(t *testing.T) {
+	out := loggerOutput()
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	renewAt := notAfter.Add(-time.Hour)
+	var dirs []string
+	var inline []map[string]any
+	mock(t, &GetCertificateStatuses, func(d []string, i []map[string]any) []CertificateStatus {
+		dirs, inline = d, i
+		return []CertificateStatus{
+			{Request: "requests/valid.yaml", OutCertPath: "tls/valid.crt", Exists: true, NotAfter: &notAfter, RenewAt: &renewAt, SelfSigned: true},
+			{Request: "requests/missing.yaml", OutCertPath: "tls/missing.crt"},
+			{Request: "requests/invalid.yaml", Error: "invalid request"},
+		}
+	})
+
+	LogCertificateStatuses([]string{"requests"}, []map[string]any{{"commonName": "inline"}})
+
+	assert.Equal(t, []string{"requests"}, dirs)
+	assert.Equal(t, []map[string]any{{"commonName": "inline"}}, inline)
+	assert.Equal(t, []string{
+		`level=info msg="Status of 3 certificate request(s)"`,
+		`level=info msg="Certificate status" cert=tls/valid.crt exists=true notAfter="2030-01-02T03:04:05Z" renewAt="2030-01-02T02:04:05Z" request=requests/valid.yaml selfSigned=true`,
+		`level=info msg="Certificate status" cert=tls/missing.crt exists=false request=requests/missing.yaml`,
+		`level=warning msg="Certificate status" error="invalid request" exists=false request=requests/invalid.yaml`,
+	}, splitLogLines(out))
+}
+
+func TestGetCertificateStatus_WithInvalidCertificate(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	file := writeStatusRequest(t, dir, "invalid", "24h", "1h")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "invalid"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid", "tls.crt"), []byte("invalid"), 0600))
+
+	statuses := GetCertificateStatus(file)
+
+	require.Len(t, statuses, 1)
+	status := statuses[0]
+	assert.True(t, status.Exists)
+	assert.Nil(t, status.NotAfter)
+	assert.Equal(t, ErrInvalidPEMBlock.Error(), status.Error)
+}
+
+func TestGetCertificateStatus_WithRenewBeforeSpread(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	file := writeStatusRequest(t, dir, "spread", "24h", "1h")
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, append(content, "renewBeforeSpread: 12h\n"...), 0600))
+	generateStatusCertificate(t, dir, "spread", 24*time.Hour)
+	cert, err := LoadCertFromFile(filepath.Join(dir, "spread", "tls.crt"))
+	require.NoError(t, err)
+
+	statuses := GetCertificateStatus(file)
+
+	require.Len(t, statuses, 1)
+	status := statuses[0]
+	assert.Equal(t, RenewalTime(CertificateRequest{RenewBefore: time.Hour, RenewSpread: 12 * time.Hour}, cert), *status.RenewAt)
+}
+
+func TestGetCertificateStatus_WithDisabledRequest(t *testing.T) {
+	viper.Reset()
+	dir := t.TempDir()
+	file := writeStatusRequest(t, dir, "disabled", "24h", "1h")
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, append(content, "enabled: false\n"...), 0600))
+
+	statuses := GetCertificateStatus(file)
+
+	assert.Empty(t, statuses)
+}
+
+func TestGetCertificateStatus_WithLegacySHA1Fingerprint(t *testing.T) {
+	viper.Reset()
+	mock(t, &config.LegacySHA1Fingerprint, true)
+	dir := t.TempDir()
+	file := writeStatusRequest(t, dir, "valid", "24h", "1h")
+	generateStatusCertificate(t, dir, "valid", 24*time.Hour)
+	cert, err := LoadCertFromFile(filepath.Join(dir, "valid", "tls.crt"))
+	require.NoError(t, err)
+
+	statuses := GetCertificateStatus(file)
+
+	require.Len(t, statuses, 1)
+	status := statuses[0]
+	assert.Equal(t, Fingerprint(cert), status.Fingerprint)
+	assert.Equal(t, LegacySHA1Fingerprint(cert), status.LegacySHA1Fingerprint)
+}
+
+func TestGetCertificateStatus_WithPrivateKeys(t *testing.T) {
+	viper.Reset()
+	loggerOutput()
+	dir := t.TempDir()
+	file := writeStatusRequest(t, dir, "keys", "24h", "1h")
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, append(content, "privateKeys:\n  - algorithm: ed25519\n  - algorithm: ecdsa\n"...), 0600))
+	req, err := LoadCertificateRequest(file)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "keys"), 0755))
+	// Only the first certificate exists
+	GenerateOutFilesFromRequest(KeyVariants(req)[0], nil)
+
+	statuses := GetCertificateStatus(file)
+
+	require.Len(t, statuses, 2)
+	assert.Equal(t, filepath.Join(dir, "keys", "tls.ed25519.crt"), statuses[0].OutCertPath)
+	assert.True(t, statuses[0].Exists)
+	assert.Equal(t, CertificateStatus{Request: file, OutCertPath: filepath.Join(dir, "keys", "tls.ecdsa.crt")}, statuses[1])
+}
+
+func writeStatusRequest(t *testing.T, dir, name, duration, renewBefore string) string {
+	file := filepath.Join(dir, name+".yaml")
+	content := "out:\n  dir: " + filepath.Join(dir, name) + "\ncommonName: " + name + "\nduration: " + duration + "\nrenewBefore: " + renewBefore + "\n"
+	require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+	return file
+}
+
+func generateStatusCertificate(t *testing.T, dir, name string, duration time.Duration) {
+	outDir := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	req := CertificateRequest{OutKeyPath: filepath.Join(outDir, "tls.key"), OutCertPath: filepath.Join(outDir, "tls.crt"), Duration: duration}
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	_, err = GenerateCertificate(req, key, nil)
+	require.NoError(t, err)
+}
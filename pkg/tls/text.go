@@ -0,0 +1,138 @@
+package tls
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var ErrWriteCertificateText = errors.New("write certificate text")
+
+var extensionNames = map[string]string{
+	"2.5.29.14":               "X509v3 Subject Key Identifier",
+	"2.5.29.15":               "X509v3 Key Usage",
+	"2.5.29.17":               "X509v3 Subject Alternative Name",
+	"2.5.29.19":               "X509v3 Basic Constraints",
+	"2.5.29.35":               "X509v3 Authority Key Identifier",
+	"2.5.29.37":               "X509v3 Extended Key Usage",
+	"1.3.6.1.4.1.11129.2.4.2": "CT Precertificate SCTs",
+	"1.3.6.1.5.5.7.1.3":       "qcStatements",
+	"1.3.6.1.5.5.7.1.24":      "TLS Feature",
+	"1.3.6.1.5.5.7.48.1.5":    "OCSP No Check",
+}
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "Digital Signature"},
+	{x509.KeyUsageContentCommitment, "Content Commitment"},
+	{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+	{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+	{x509.KeyUsageKeyAgreement, "Key Agreement"},
+	{x509.KeyUsageCertSign, "Certificate Sign"},
+	{x509.KeyUsageCRLSign, "CRL Sign"},
+	{x509.KeyUsageEncipherOnly, "Encipher Only"},
+	{x509.KeyUsageDecipherOnly, "Decipher Only"},
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "Any Extended Key Usage",
+	x509.ExtKeyUsageServerAuth:                     "TLS Web Server Authentication",
+	x509.ExtKeyUsageClientAuth:                     "TLS Web Client Authentication",
+	x509.ExtKeyUsageCodeSigning:                    "Code Signing",
+	x509.ExtKeyUsageEmailProtection:                "E-mail Protection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "IPSec End System",
+	x509.ExtKeyUsageIPSECTunnel:                    "IPSec Tunnel",
+	x509.ExtKeyUsageIPSECUser:                      "IPSec User",
+	x509.ExtKeyUsageTimeStamping:                   "Time Stamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSP Signing",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "Microsoft Server Gated Crypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "Netscape Server Gated Crypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "Microsoft Commercial Code Signing",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "Microsoft Kernel Code Signing",
+}
+
+// DescribeCertificate formats a certificate in a human-readable form close to `openssl x509 -text`.
+func DescribeCertificate(cert *x509.Certificate) string {
+	var b strings.Builder
+	b.WriteString("Certificate:\n")
+	fmt.Fprintf(&b, "    Serial Number: %s\n", SerialNumber(cert))
+	fmt.Fprintf(&b, "    Signature Algorithm: %s\n", cert.SignatureAlgorithm)
+	fmt.Fprintf(&b, "    Issuer: %s\n", cert.Issuer)
+	b.WriteString("    Validity\n")
+	fmt.Fprintf(&b, "        Not Before: %s\n", cert.NotBefore.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&b, "        Not After : %s\n", cert.NotAfter.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&b, "    Subject: %s\n", cert.Subject)
+	fmt.Fprintf(&b, "    Subject Public Key Algorithm: %s\n", cert.PublicKeyAlgorithm)
+	if len(cert.Extensions) > 0 {
+		b.WriteString("    X509v3 extensions:\n")
+		for _, ext := range cert.Extensions {
+			name, ok := extensionNames[ext.Id.String()]
+			if !ok {
+				name = ext.Id.String()
+			}
+			if ext.Critical {
+				name += ": critical"
+			}
+			fmt.Fprintf(&b, "        %s\n", name)
+			if value := describeExtension(cert, ext.Id); value != "" {
+				fmt.Fprintf(&b, "            %s\n", value)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "SHA256 Fingerprint: %s\n", Fingerprint(cert))
+	if config.LegacySHA1Fingerprint {
+		fmt.Fprintf(&b, "SHA1 Fingerprint (legacy): %s\n", LegacySHA1Fingerprint(cert))
+	}
+	return b.String()
+}
+
+func describeExtension(cert *x509.Certificate, id asn1.ObjectIdentifier) string {
+	switch id.String() {
+	case "2.5.29.15":
+		var usages []string
+		for _, ku := range keyUsageNames {
+			if cert.KeyUsage&ku.usage != 0 {
+				usages = append(usages, ku.name)
+			}
+		}
+		return strings.Join(usages, ", ")
+	case "2.5.29.17":
+		var names []string
+		for _, dnsName := range cert.DNSNames {
+			names = append(names, "DNS:"+dnsName)
+		}
+		for _, ip := range cert.IPAddresses {
+			names = append(names, "IP Address:"+ip.String())
+		}
+		return strings.Join(names, ", ")
+	case "2.5.29.19":
+		return fmt.Sprintf("CA:%s", strings.ToUpper(fmt.Sprint(cert.IsCA)))
+	case "2.5.29.37":
+		var usages []string
+		for _, eku := range cert.ExtKeyUsage {
+			usages = append(usages, extKeyUsageNames[eku])
+		}
+		return strings.Join(usages, ", ")
+	case "2.5.29.14":
+		return colonHex(cert.SubjectKeyId)
+	case "2.5.29.35":
+		return colonHex(cert.AuthorityKeyId)
+	}
+	return ""
+}
+
+var WriteCertificateText = func(cert *x509.Certificate, path string) error {
+	if err := os.WriteFile(path, []byte(DescribeCertificate(cert)), 0644); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteCertificateText, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package tls
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+func TestDescribeCertificate(t *testing.T) {
+	req := CertificateRequest{
+		CommonName:    "test",
+		Organizations: []string{"uCerts"},
+		DNSNames:      []string{"localhost", "example.com"},
+		IPAddresses:   []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:   []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPNoCheck:   true,
+		Duration:      time.Hour,
+	}
+	mock(t, &WritePemToFile, func(_ *pem.Block, _ string) error { return nil })
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	cert, err := GenerateCertificate(req, key, nil)
+	require.NoError(t, err)
+
+	text := DescribeCertificate(cert)
+
+	assert.Contains(t, text, "    Serial Number: "+SerialNumber(cert)+"\n")
+	assert.Contains(t, text, "    Issuer: CN=test,O=uCerts\n")
+	assert.Contains(t, text, "    Subject: CN=test,O=uCerts\n")
+	assert.Contains(t, text, "        Not After : "+cert.NotAfter.UTC().Format(time.RFC1123)+"\n")
+	assert.Contains(t, text, "        X509v3 Subject Alternative Name\n            DNS:localhost, DNS:example.com, IP Address:127.0.0.1\n")
+	assert.Contains(t, text, "        X509v3 Extended Key Usage\n            TLS Web Server Authentication\n")
+	assert.Contains(t, text, "        X509v3 Basic Constraints: critical\n            CA:FALSE\n")
+	assert.Contains(t, text, "        OCSP No Check\n")
+	assert.Contains(t, text, "SHA256 Fingerprint: "+Fingerprint(cert)+"\n")
+	assert.NotContains(t, text, "SHA1")
+
+	mock(t, &config.LegacySHA1Fingerprint, true)
+	assert.Contains(t, DescribeCertificate(cert), "SHA1 Fingerprint (legacy): "+LegacySHA1Fingerprint(cert)+"\n")
+}
+
+func TestGenerateOutFilesFromRequest_WithText(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		OutTextPath: filepath.Join(dir, "tls.txt"),
+		CommonName:  "test",
+		DNSNames:    []string{"localhost"},
+		Duration:    time.Hour,
+	}
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	text, err := os.ReadFile(req.OutTextPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(text), "    Subject: CN=test\n")
+	assert.Contains(t, string(text), "            DNS:localhost\n")
+	assert.Contains(t, out.String(), `msg="Write certificate text to `+req.OutTextPath+`"`)
+}
@@ -1,24 +1,33 @@
 package tls
 
 import (
+	"errors"
+	"math/rand"
 	"time"
 
 	"github.com/goten4/ucerts/internal/config"
 	"github.com/goten4/ucerts/internal/funcs"
 )
 
+var (
+	jitterInt63n = rand.Int63n
+	now          = time.Now
+
+	ErrRunFailed = errors.New("run failed")
+)
+
 func Start() funcs.Stop {
-	ticker := time.NewTicker(config.Interval)
-	stop := make(chan struct{}, 1)
+	timer := time.NewTimer(nextInterval())
+	// Unbuffered so that stopping waits for the pass in progress
+	stop := make(chan struct{})
 
 	go func() {
 		for {
-			for _, dir := range config.CertificateRequestsPaths {
-				LoadCertificateRequests(dir)
-			}
+			handleAllCertificateRequests()
 
 			select {
-			case <-ticker.C:
+			case <-timer.C:
+				timer.Reset(nextInterval())
 				continue
 			case <-stop:
 				return
@@ -27,7 +36,66 @@ func Start() funcs.Stop {
 	}()
 
 	return func() {
-		ticker.Stop()
+		timer.Stop()
 		stop <- struct{}{}
+		requestTimers.stopAll()
+	}
+}
+
+// RunOnce handles all the certificate requests a single time and fails if any of them failed.
+var RunOnce = func() error {
+	// A single pass, requests with a shorter check interval are not handled again
+	defer requestTimers.stopAll()
+
+	if !handleAllCertificateRequests() {
+		return ErrRunFailed
+	}
+	return nil
+}
+
+// handleAllCertificateRequests handles the certificate requests of all the directories and the inline ones, and
+// reports whether they all succeeded.
+func handleAllCertificateRequests() bool {
+	ok := true
+	for _, dir := range config.CertificateRequestsPaths {
+		ok = LoadCertificateRequests(dir) && ok
+	}
+	return HandleInlineCertificateRequests(config.CertificateRequestsInline) && ok
+}
+
+// nextInterval returns the delay until the next activation of the configured schedule or, without schedule, the
+// configured interval shifted by a random offset in [-jitter, +jitter]. An unset interval, when the configuration has
+// not been loaded, falls back to config.MinInterval rather than firing in a loop.
+func nextInterval() time.Duration {
+	if interval, ok := scheduledInterval(); ok {
+		return interval
+	}
+	if config.Interval <= 0 {
+		return config.MinInterval
+	}
+	if config.IntervalJitter <= 0 {
+		return config.Interval
+	}
+	offset := time.Duration(jitterInt63n(int64(2*config.IntervalJitter)+1)) - config.IntervalJitter
+	return config.Interval + offset
+}
+
+// globalInterval returns the delay until the next periodic pass, without jitter.
+func globalInterval() time.Duration {
+	if interval, ok := scheduledInterval(); ok {
+		return interval
+	}
+	return config.Interval
+}
+
+func scheduledInterval() (time.Duration, bool) {
+	if config.Schedule == nil {
+		return 0, false
+	}
+	current := now()
+	next := config.Schedule.Next(current)
+	if next.IsZero() {
+		return 0, false
 	}
+	return next.Sub(current), true
 }
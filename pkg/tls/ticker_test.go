@@ -1,21 +1,30 @@
 package tls
 
 import (
+	"math/rand"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/internal/cron"
 )
 
 func TestStart(t *testing.T) {
-	var loadCount atomic.Int32
+	var loadCount, inlineCount atomic.Int32
 	config.Interval = 100 * time.Millisecond
 	config.CertificateRequestsPaths = []string{"testdata/requests"}
-	mock(t, &LoadCertificateRequests, func(_ string) {
+	mock(t, &LoadCertificateRequests, func(_ string) bool {
 		loadCount.Add(1)
+		return true
+	})
+	mock(t, &HandleInlineCertificateRequests, func(_ []map[string]any) bool {
+		inlineCount.Add(1)
+		return true
 	})
 
 	stop := Start()
@@ -24,4 +33,166 @@ func TestStart(t *testing.T) {
 	time.Sleep(200 * time.Millisecond)
 
 	assert.Equal(t, int32(3), loadCount.Load())
+	assert.Equal(t, int32(3), inlineCount.Load())
+}
+
+func TestStart_WithZeroInterval(t *testing.T) {
+	var inlineCount atomic.Int32
+	config.Interval = 0
+	config.CertificateRequestsPaths = nil
+	mock(t, &HandleInlineCertificateRequests, func(_ []map[string]any) bool {
+		inlineCount.Add(1)
+		return true
+	})
+
+	stop := Start()
+	time.Sleep(100 * time.Millisecond)
+	stop()
+
+	assert.Equal(t, int32(1), inlineCount.Load())
+}
+
+func TestStart_WithSchedule(t *testing.T) {
+	var inlineCount atomic.Int32
+	config.Interval = time.Hour
+	config.CertificateRequestsPaths = nil
+	mockSchedule(t, "* * * * *")
+	// The clock stays 100ms before the next minute, every pass is scheduled 100ms later
+	mock(t, &now, func() time.Time {
+		return time.Date(2026, time.October, 16, 10, 40, 59, int(900*time.Millisecond), time.UTC)
+	})
+	mock(t, &HandleInlineCertificateRequests, func(_ []map[string]any) bool {
+		inlineCount.Add(1)
+		return true
+	})
+
+	stop := Start()
+	time.Sleep(250 * time.Millisecond)
+	stop()
+
+	assert.Equal(t, int32(3), inlineCount.Load())
+}
+
+func TestRunOnce(t *testing.T) {
+	var loadedDirs []string
+	config.CertificateRequestsPaths = []string{"dir1", "dir2"}
+	config.CertificateRequestsInline = []map[string]any{{"commonName": "inline"}}
+	t.Cleanup(func() { config.CertificateRequestsInline = nil })
+	mock(t, &LoadCertificateRequests, func(dir string) bool {
+		loadedDirs = append(loadedDirs, dir)
+		logrus.Infof("Handle %s", dir)
+		return true
+	})
+	var inline []map[string]any
+	mock(t, &HandleInlineCertificateRequests, func(requests []map[string]any) bool {
+		inline = requests
+		return true
+	})
+	loggerOutput()
+
+	err := RunOnce()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dir1", "dir2"}, loadedDirs)
+	assert.Equal(t, config.CertificateRequestsInline, inline)
+}
+
+func TestRunOnce_WithFailures(t *testing.T) {
+	var loadedDirs []string
+	config.CertificateRequestsPaths = []string{"dir1", "dir2"}
+	mock(t, &LoadCertificateRequests, func(dir string) bool {
+		loadedDirs = append(loadedDirs, dir)
+		return dir != "dir1"
+	})
+	mock(t, &HandleInlineCertificateRequests, func(_ []map[string]any) bool { return true })
+
+	err := RunOnce()
+
+	assert.ErrorIs(t, err, ErrRunFailed)
+	assert.Equal(t, []string{"dir1", "dir2"}, loadedDirs)
+}
+
+func TestRunOnce_WithInlineFailure(t *testing.T) {
+	config.CertificateRequestsPaths = nil
+	mock(t, &HandleInlineCertificateRequests, func(_ []map[string]any) bool { return false })
+
+	err := RunOnce()
+
+	assert.ErrorIs(t, err, ErrRunFailed)
+}
+
+func TestNextInterval(t *testing.T) {
+	config.Interval = time.Minute
+	config.IntervalJitter = 10 * time.Second
+	t.Cleanup(func() { config.IntervalJitter = 0 })
+	mock(t, &jitterInt63n, rand.New(rand.NewSource(42)).Int63n)
+
+	var intervals []time.Duration
+	for i := 0; i < 10; i++ {
+		intervals = append(intervals, nextInterval())
+	}
+
+	for i, interval := range intervals {
+		assert.GreaterOrEqual(t, interval, 50*time.Second)
+		assert.LessOrEqual(t, interval, 70*time.Second)
+		if i > 0 {
+			assert.NotEqual(t, intervals[i-1], interval)
+		}
+	}
+}
+
+func TestNextInterval_Bounds(t *testing.T) {
+	config.Interval = time.Minute
+	config.IntervalJitter = 10 * time.Second
+	t.Cleanup(func() { config.IntervalJitter = 0 })
+
+	mock(t, &jitterInt63n, func(_ int64) int64 { return 0 })
+	assert.Equal(t, 50*time.Second, nextInterval())
+
+	mock(t, &jitterInt63n, func(n int64) int64 { return n - 1 })
+	assert.Equal(t, 70*time.Second, nextInterval())
+}
+
+func TestNextInterval_WithoutJitter(t *testing.T) {
+	config.Interval = time.Minute
+	config.IntervalJitter = 0
+
+	assert.Equal(t, time.Minute, nextInterval())
+}
+
+func TestNextInterval_WithZeroInterval(t *testing.T) {
+	config.Interval = 0
+	config.IntervalJitter = 0
+
+	assert.Equal(t, config.MinInterval, nextInterval())
+}
+
+func TestNextInterval_WithSchedule(t *testing.T) {
+	config.Interval = time.Minute
+	config.IntervalJitter = 10 * time.Second
+	t.Cleanup(func() { config.IntervalJitter = 0 })
+	mockSchedule(t, "*/15 9-10 * * 1-5")
+	clock := time.Date(2026, time.October, 16, 10, 20, 10, 0, time.UTC) // Friday
+	mock(t, &now, func() time.Time { return clock })
+
+	var scans []time.Time
+	for i := 0; i < 6; i++ {
+		clock = clock.Add(nextInterval())
+		scans = append(scans, clock)
+	}
+
+	assert.Equal(t, []time.Time{
+		time.Date(2026, time.October, 16, 10, 30, 0, 0, time.UTC),
+		time.Date(2026, time.October, 16, 10, 45, 0, 0, time.UTC),
+		time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, time.October, 19, 9, 15, 0, 0, time.UTC),
+		time.Date(2026, time.October, 19, 9, 30, 0, 0, time.UTC),
+		time.Date(2026, time.October, 19, 9, 45, 0, 0, time.UTC),
+	}, scans)
+}
+
+func mockSchedule(t *testing.T, expression string) {
+	schedule, err := cron.Parse(expression)
+	require.NoError(t, err)
+	mock(t, &config.Schedule, schedule)
 }
@@ -1,93 +1,541 @@
 package tls
 
 import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/goten4/ucerts/internal/config"
+	"github.com/goten4/ucerts/internal/format"
 )
 
 var (
 	ErrInvalidPEMBlock = errors.New("invalid PEM block")
 )
 
-var LoadCertificateRequests = func(dir string) {
+// PreviousSuffix is appended to the certificate and key file names to keep them on renewal (out.keepPrevious).
+const PreviousSuffix = ".prev"
+
+// CorruptSuffix is appended to the name of an unparseable certificate moved aside (onCorruptCert: quarantine).
+const CorruptSuffix = ".corrupt"
+
+// LoadCertificateRequests handles the certificate request files of a directory and reports whether they all succeeded.
+var LoadCertificateRequests = func(dir string) bool {
 	files, err := ReadDir(dir)
 	if err != nil {
 		logrus.Errorf("Failed to read directory %s: %v", dir, err)
-		return
+		return false
 	}
+	ok := true
 	for _, file := range files {
-		HandleCertificateRequestFile(file)
+		ok = HandleCertificateRequestFile(file) && ok
 	}
+	return ok
 }
 
-var HandleCertificateRequestFile = func(file string) {
-	// Handle only files with compatible extension
-	if _, err := config.GetExtension(file); err != nil {
-		return
+// LoadCertificateRequestsFromDir parses the certificate request files of a directory without generating anything. It
+// returns the valid requests along with an error per invalid file.
+func LoadCertificateRequestsFromDir(dir string) ([]CertificateRequest, []error) {
+	files, err := ReadDir(dir)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var requests []CertificateRequest
+	var errs []error
+	for _, file := range files {
+		if !config.IsCertificateRequestFile(file) {
+			continue
+		}
+		req, err := LoadCertificateRequest(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		requests = append(requests, req)
+	}
+	return requests, errs
+}
+
+var HandleCertificateRequestFile = handleCertificateRequestFile
+
+// handleCertificateRequestFile handles a certificate request file and reports whether it succeeded: files which are not
+// certificate requests and disabled requests succeed, while invalid and quarantined requests fail.
+func handleCertificateRequestFile(file string) bool {
+	// Handle only certificate request files
+	if !config.IsCertificateRequestFile(file) {
+		return true
+	}
+	defer requestLocks.lock(file)()
+
+	fingerprint := fileFingerprint(file)
+	if requestFailures.isQuarantined(file, fingerprint) {
+		requestTimers.schedule(file, 0, nil)
+		logrus.Debugf("Certificate request %s quarantined, skipping", file)
+		return false
 	}
 
 	logrus.Infof("Handle certificate request %s", file)
 	req, err := LoadCertificateRequest(file)
 	if err != nil {
+		requestTimers.schedule(file, 0, nil)
 		logrus.Errorf("Failed to load certificate request: %v", err)
-		return
+		return false
+	}
+	if req.Disabled {
+		requestTimers.schedule(file, 0, nil)
+		logrus.Infof("Certificate request %s disabled, skipping", file)
+		return true
 	}
 
+	ok := handleCertificateRequest(req)
+	if requestFailures.record(file, fingerprint, ok, req.MaxFailures) {
+		requestTimers.schedule(file, 0, nil)
+		logrus.Errorf("Certificate request %s quarantined after %d consecutive failures, waiting for it to change", file, req.MaxFailures)
+		return false
+	}
+	requestTimers.schedule(file, req.CheckInterval, func() { handleCertificateRequestFile(file) })
+	return ok
+}
+
+// HandleInlineCertificateRequests handles the certificate requests of the configuration and reports whether they all
+// succeeded.
+var HandleInlineCertificateRequests = func(requests []map[string]any) bool {
+	ok := true
+	for i, values := range requests {
+		ok = handleInlineCertificateRequest(i, values) && ok
+	}
+	return ok
+}
+
+func handleInlineCertificateRequest(i int, values map[string]any) bool {
+	key := fmt.Sprintf("inline #%d", i)
+	defer requestLocks.lock(key)()
+	logrus.Infof("Handle inline certificate request #%d", i)
+	req, err := BuildCertificateRequest(values)
+	if err != nil {
+		requestTimers.schedule(key, 0, nil)
+		logrus.Errorf("Failed to load inline certificate request #%d: %v", i, err)
+		return false
+	}
+	if req.Disabled {
+		requestTimers.schedule(key, 0, nil)
+		logrus.Infof("Inline certificate request #%d disabled, skipping", i)
+		return true
+	}
+	fingerprint := requestFingerprint(req)
+	if requestFailures.isQuarantined(key, fingerprint) {
+		requestTimers.schedule(key, 0, nil)
+		logrus.Debugf("Inline certificate request #%d quarantined, skipping", i)
+		return false
+	}
+
+	ok := handleCertificateRequest(req)
+	if requestFailures.record(key, fingerprint, ok, req.MaxFailures) {
+		requestTimers.schedule(key, 0, nil)
+		logrus.Errorf("Inline certificate request #%d quarantined after %d consecutive failures, waiting for it to change", i, req.MaxFailures)
+		return false
+	}
+	requestTimers.schedule(key, req.CheckInterval, func() { handleInlineCertificateRequest(i, values) })
+	return ok
+}
+
+// handleCertificateRequest handles all the key variants of the request and reports whether they all succeeded.
+func handleCertificateRequest(req CertificateRequest) bool {
+	ok := true
+	for _, variant := range KeyVariants(req) {
+		ok = handleKeyVariant(variant) && ok
+	}
+	return ok
+}
+
+func handleKeyVariant(req CertificateRequest) bool {
+	log := RequestLogger(req)
+
 	issuer, err := LoadIssuer(req.IssuerPath)
 	if err != nil {
-		logrus.Errorf("Invalid issuer: %v", err)
+		log.Errorf("Invalid issuer: %v", err)
+		return false
+	}
+
+	d := decideCertificate(req, issuer)
+	switch d.state {
+	case certificateMissing:
+		if err := MakeParentsDirectories(req.OutCertPath); err != nil {
+			log.Errorf("Cannot create output directory %s: %v", filepath.Dir(req.OutCertPath), err)
+			return false
+		}
+		logOrphanKey(log, req)
+		return generate(req, issuer)
+	case certificateUnchanged:
+		log.Debugf("Unchanged request for certificate %s", req.OutCertPath)
+	case certificateCorrupt:
+		log.Errorf("Invalid certificate %s: %v", req.OutCertPath, d.err)
+		return handleCorruptCert(log, req, issuer)
+	case certificateExpired:
+		log.Infof("Expired certificate %s", req.OutCertPath)
+		return generate(req, issuer)
+	case certificateNotRenewable:
+		log.Warnf("Certificate %s expires at %s, not renewable with a fixed notAfter", req.OutCertPath, d.cert.NotAfter.Format(time.RFC3339))
+	case certificateIssuerChanged:
+		log.Infof("Issuer changed for certificate %s", req.OutCertPath)
+		return generate(req, issuer)
+	case certificateRequestChanged:
+		log.Infof("Request changed for certificate %s: %s", req.OutCertPath, strings.Join(d.diffs, ", "))
+		// A new key algorithm or size cannot reuse the existing key
+		req.PrivateKey.Reuse = req.PrivateKey.Reuse || !d.keyChanged
+		return generate(req, issuer)
+	case certificateChainBroken:
+		log.Warnf("Certificate %s does not chain to issuer: %v", req.OutCertPath, d.err)
+		return generate(req, issuer)
+	}
+	return true
+}
+
+// logOrphanKey reports the key left without certificate, e.g. by a crash between their writes, as it may already be
+// registered somewhere: it is reused when the request reuses its key, and overwritten otherwise.
+func logOrphanKey(log *logrus.Entry, req CertificateRequest) {
+	if req.OutKeyInMemory || FileDoesNotExists(req.OutKeyPath) {
+		return
+	}
+	if req.PrivateKey.Reuse {
+		log.Infof("Found orphan key %s without certificate %s, reusing it", req.OutKeyPath, req.OutCertPath)
 		return
 	}
+	log.Warnf("Found orphan key %s without certificate %s, overwriting it", req.OutKeyPath, req.OutCertPath)
+}
+
+// handleCorruptCert regenerates the certificate that could not be parsed, after moving it aside for forensics when
+// quarantined, or leaves it for an operator to look at.
+func handleCorruptCert(log *logrus.Entry, req CertificateRequest, issuer *Issuer) bool {
+	switch req.OnCorruptCert {
+	case OnCorruptFail:
+		log.Errorf("Refuse to regenerate corrupt certificate %s", req.OutCertPath)
+		return false
+	case OnCorruptQuarantine:
+		log.Warnf("Quarantine corrupt certificate %s to %s", req.OutCertPath, req.OutCertPath+CorruptSuffix)
+		if err := FS.Rename(req.OutCertPath, req.OutCertPath+CorruptSuffix); err != nil {
+			log.Errorf("Cannot quarantine corrupt certificate %s: %v", req.OutCertPath, err)
+			return false
+		}
+	}
+	return generate(req, issuer)
+}
+
+// generate generates the out files of the request and reports whether it succeeded.
+func generate(req CertificateRequest, issuer *Issuer) bool {
+	GenerateOutFilesFromRequest(req, issuer)
+	return !generationFailures.take(req.OutCertPath)
+}
+
+var GenerateOutFilesFromRequest = func(req CertificateRequest, issuer *Issuer) {
+	for _, variant := range KeyVariants(req) {
+		generateKeyVariantOutFiles(variant, issuer)
+	}
+}
+
+func generateKeyVariantOutFiles(req CertificateRequest, issuer *Issuer) {
+	log := RequestLogger(req)
+	previousKey, _ := LoadPrivateKeyFromFile(req.OutKeyPath)
 
-	if FileDoesNotExists(req.OutCertPath) {
-		if ok := MakeParentsDirectories(req.OutCertPath); !ok {
+	if req.OutKeepPrevious {
+		if err := keepPreviousFiles(log, req); err != nil {
+			logError(log, req, err)
 			return
 		}
-		GenerateOutFilesFromRequest(req, issuer)
-		return
 	}
 
-	cert, err := LoadCertFromFile(req.OutCertPath)
+	key, cert, err := generateRequestOutFiles(log, req, issuer)
 	if err != nil {
-		logrus.Errorf("Invalid certificate %s: %v", req.OutCertPath, err)
-		GenerateOutFilesFromRequest(req, issuer)
+		logError(log, req, err)
 		return
 	}
 
-	if cert.NotAfter.Before(time.Now().Add(req.RenewBefore)) {
-		logrus.Infof("Expired certificate %s", req.OutCertPath)
-		GenerateOutFilesFromRequest(req, issuer)
+	if req.OutTarPath != "" {
+		log.Infof("Write archive to %s", req.OutTarPath)
+		if err := WriteArchive(req, issuer, req.OutTarPath); err != nil {
+			logError(log, req, err)
+			return
+		}
+	}
+
+	if req.OutManifestPath != "" {
+		log.Infof("Write manifest to %s", req.OutManifestPath)
+		if err := writeManifest(req, key, cert, issuer); err != nil {
+			logError(log, req, err)
+			return
+		}
+	}
+
+	if req.OutRequestHashPath != "" {
+		log.Infof("Write request hash to %s", req.OutRequestHashPath)
+		if err := writeRequestHash(req, cert, issuer); err != nil {
+			logError(log, req, err)
+			return
+		}
+	}
+
+	if previousKey == nil {
 		return
 	}
+	if samePublicKey(previousKey, key) {
+		log.WithField("event", "cert renewed").Infof("Certificate %s renewed with the same key", req.OutCertPath)
+	} else {
+		log.WithField("event", "key rotated").Infof("Key %s rotated", req.OutKeyPath)
+	}
 }
 
-var GenerateOutFilesFromRequest = func(req CertificateRequest, issuer *Issuer) {
-	logrus.Infof("Generate key to %s", req.OutKeyPath)
-	key, err := GeneratePrivateKey(req)
+func generateRequestOutFiles(log *logrus.Entry, req CertificateRequest, issuer *Issuer) (crypto.PrivateKey, *x509.Certificate, error) {
+	if !req.OutStaging {
+		return generateOutFiles(log, req, issuer)
+	}
+
+	stagingDir, err := CreateStagingDir(req)
 	if err != nil {
-		logError(err)
-		return
+		return nil, nil, err
 	}
+	defer func() { _ = FS.RemoveAll(stagingDir) }()
 
-	logrus.Infof("Generate certificate to %s", req.OutCertPath)
-	if err := GenerateCertificate(req, key, issuer); err != nil {
-		logError(err)
-		return
+	staged, err := StageRequest(req, stagingDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.PrivateKey.Reuse {
+		if err := copyFileIfExists(req.OutKeyPath, staged.OutKeyPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	key, cert, err := generateOutFiles(log, staged, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Infof("Promote staged files from %s", stagingDir)
+	if err := PromoteStagedFiles(staged, req); err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+func generateOutFiles(log *logrus.Entry, req CertificateRequest, issuer *Issuer) (crypto.PrivateKey, *x509.Certificate, error) {
+	if req.RequireIssuer && issuer == nil {
+		return nil, nil, fmt.Errorf(format.WrapErrorString, ErrIssuerRequired, req.OutCertPath)
+	}
+
+	key, cert, err := obtainKeyAndCertificate(log, req, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := logrus.Fields{
+		"fingerprint": Fingerprint(cert),
+		"serial":      SerialNumber(cert),
+		"self_signed": issuer == nil,
+	}
+	if config.LegacySHA1Fingerprint {
+		fields["fingerprint_sha1_legacy"] = LegacySHA1Fingerprint(cert)
+	}
+	log.WithFields(fields).Infof("Generate certificate to %s", req.OutCertPath)
+
+	if req.OutTextPath != "" {
+		log.Infof("Write certificate text to %s", req.OutTextPath)
+		if err := WriteCertificateText(cert, req.OutTextPath); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	if issuer != nil {
-		logrus.Infof("Copy CA to %s", req.OutCAPath)
+		log.Infof("Copy CA to %s", req.OutCAPath)
 		if err := CopyCA(issuer, req.OutCAPath); err != nil {
-			logError(err)
-			return
+			return nil, nil, err
+		}
+	}
+
+	if issuer != nil && req.OutChainPath != "" {
+		log.Infof("Write chain to %s", req.OutChainPath)
+		if err := WriteChain(issuer, req.OutChainPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if req.OutFullChainPath != "" {
+		log.Infof("Write fullchain to %s", req.OutFullChainPath)
+		if err := WriteFullChain(cert, issuer, req.OutFullChainPath, req.OutPEMHeaders); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if req.OutP7BPath != "" {
+		log.Infof("Write PKCS#7 to %s", req.OutP7BPath)
+		if err := WritePKCS7(cert, issuer, req.OutP7BPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if req.OutBundlePath != "" {
+		log.Infof("Write bundle to %s", req.OutBundlePath)
+		bundleKey := key
+		if req.OutBundleWithoutKey {
+			bundleKey = nil
+		}
+		if err := WriteBundle(bundleKey, req.PrivateKey.Format, cert, issuer, req.OutBundlePath, req.OutPEMHeaders); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return key, cert, nil
+}
+
+// keepPreviousFiles copies the current certificate and key to .prev files, replacing the former ones, so that a
+// consumer can still use them while switching to the renewed ones.
+func keepPreviousFiles(log *logrus.Entry, req CertificateRequest) error {
+	paths := []string{req.OutCertPath}
+	if !req.OutKeyInMemory {
+		paths = append(paths, req.OutKeyPath)
+	}
+	for _, path := range paths {
+		if FileDoesNotExists(path) {
+			continue
+		}
+		log.Infof("Keep previous %s to %s", path, path+PreviousSuffix)
+		if err := copyFileIfExists(path, path+PreviousSuffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeManifest(req CertificateRequest, key crypto.PrivateKey, cert *x509.Certificate, issuer *Issuer) error {
+	manifest, err := BuildManifest(req, key, cert, issuer)
+	if err != nil {
+		return err
+	}
+	return WriteManifest(manifest, req.OutManifestPath)
+}
+
+// obtainKeyAndCertificate generates the key, unless reused, and the certificate of the request. With CT logs, both
+// are only written once the certificate is submitted: a failed submission leaves the previous pair untouched, rather
+// than a new key next to the previous certificate.
+func obtainKeyAndCertificate(log *logrus.Entry, req CertificateRequest, issuer *Issuer) (crypto.PrivateKey, *x509.Certificate, error) {
+	if len(req.CTLogs) > 0 {
+		return obtainSubmittedKeyAndCertificate(log, req, issuer)
+	}
+	key, err := timed(log, "key generation", func() (crypto.PrivateKey, error) { return obtainPrivateKey(log, req) })
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeKeyFiles(log, req, key); err != nil {
+		return nil, nil, err
+	}
+	cert, err := timed(log, "certificate generation", func() (*x509.Certificate, error) { return GenerateCertificate(req, key, issuer) })
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+func obtainSubmittedKeyAndCertificate(log *logrus.Entry, req CertificateRequest, issuer *Issuer) (crypto.PrivateKey, *x509.Certificate, error) {
+	key := reusablePrivateKey(log, req)
+	var pemBlock *pem.Block
+	if key == nil {
+		var err error
+		if key, pemBlock, err = newPrivateKey(req); err != nil {
+			return nil, nil, err
+		}
+	}
+	cert, err := newCertificate(req, key, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := SubmitToCTLogs(req, cert, issuer); err != nil {
+		return nil, nil, err
+	}
+
+	if pemBlock != nil {
+		logKeyGeneration(log, req)
+		if err := writePrivateKey(req, key, pemBlock); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := writeKeyFiles(log, req, key); err != nil {
+		return nil, nil, err
+	}
+	if err := writeCertificate(req, cert, issuer); err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// writeKeyFiles writes the other encodings of the key: out.keyDer and out.publicKey.
+func writeKeyFiles(log *logrus.Entry, req CertificateRequest, key crypto.PrivateKey) error {
+	if req.OutKeyDERPath != "" {
+		log.Infof("Write DER key to %s", req.OutKeyDERPath)
+		if err := WritePrivateKeyDER(key, req.OutKeyDERPath); err != nil {
+			return err
 		}
 	}
+	if req.OutPublicKeyPath != "" {
+		log.Infof("Write public key to %s", req.OutPublicKeyPath)
+		if err := WritePublicKey(key, req.OutPublicKeyPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func obtainPrivateKey(log *logrus.Entry, req CertificateRequest) (crypto.PrivateKey, error) {
+	if key := reusablePrivateKey(log, req); key != nil {
+		return key, nil
+	}
+	logKeyGeneration(log, req)
+	return GeneratePrivateKey(req)
+}
+
+// reusablePrivateKey returns the existing key when the request reuses it, nil otherwise.
+func reusablePrivateKey(log *logrus.Entry, req CertificateRequest) crypto.PrivateKey {
+	if !req.PrivateKey.Reuse || FileDoesNotExists(req.OutKeyPath) {
+		return nil
+	}
+	key, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+	if err != nil {
+		log.Warnf("Cannot reuse key %s: %v", req.OutKeyPath, err)
+		return nil
+	}
+	log.Infof("Reuse key %s", req.OutKeyPath)
+	return key
+}
+
+func logKeyGeneration(log *logrus.Entry, req CertificateRequest) {
+	if req.OutKeyInMemory {
+		log.Info("Generate key in memory")
+	} else {
+		log.Infof("Generate key to %s", req.OutKeyPath)
+	}
+}
+
+// timed logs at debug level how long a generation step took.
+func timed[T any](log *logrus.Entry, step string, fn func() (T, error)) (T, error) {
+	start := time.Now()
+	v, err := fn()
+	log.WithFields(logrus.Fields{"step": step, "duration": time.Since(start)}).Debugf("Step %s done", step)
+	return v, err
+}
+
+func RequestLogger(req CertificateRequest) *logrus.Entry {
+	fields := make(logrus.Fields, len(req.Labels))
+	for k, v := range req.Labels {
+		fields[k] = v
+	}
+	return logrus.WithFields(fields)
 }
 
-func logError(err error) {
-	logrus.Errorf("Failure: %v", err)
+func logError(log *logrus.Entry, req CertificateRequest, err error) {
+	log.Errorf("Failure: %v", err)
+	generationFailures.add(req.OutCertPath)
 }
@@ -3,24 +3,85 @@ package tls
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/x509"
+	"encoding/pem"
 	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
 )
 
 func TestLoadCertificateRequests(t *testing.T) {
 	var handledFiles []string
-	mock(t, &HandleCertificateRequestFile, func(file string) { handledFiles = append(handledFiles, file) })
+	mock(t, &HandleCertificateRequestFile, func(file string) bool {
+		handledFiles = append(handledFiles, file)
+		return true
+	})
 
-	LoadCertificateRequests("testdata/requests")
+	ok := LoadCertificateRequests("testdata/requests")
+
+	assert.True(t, ok)
+	assert.Equal(t, []string{"testdata/requests/test1.yaml", "testdata/requests/test2.yaml"}, handledFiles)
+}
 
+func TestLoadCertificateRequests_WithFailure(t *testing.T) {
+	var handledFiles []string
+	mock(t, &HandleCertificateRequestFile, func(file string) bool {
+		handledFiles = append(handledFiles, file)
+		return file != "testdata/requests/test1.yaml"
+	})
+
+	ok := LoadCertificateRequests("testdata/requests")
+
+	assert.False(t, ok)
 	assert.Equal(t, []string{"testdata/requests/test1.yaml", "testdata/requests/test2.yaml"}, handledFiles)
 }
 
+func TestLoadCertificateRequests_WithReadDirError(t *testing.T) {
+	loggerOutput()
+
+	ok := LoadCertificateRequests("testdata/unknown")
+
+	assert.False(t, ok)
+}
+
+func TestLoadCertificateRequestsFromDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "valid.yaml"), []byte("out:\n  dir: tls\ncommonName: valid\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.yaml"), []byte("commonName: invalid\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a request\n"), 0600))
+	var generated bool
+	mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { generated = true })
+
+	requests, errs := LoadCertificateRequestsFromDir(dir)
+
+	require.Len(t, requests, 1)
+	assert.Equal(t, "valid", requests[0].CommonName)
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrMissingMandatoryField)
+	assert.Contains(t, errs[0].Error(), filepath.Join(dir, "invalid.yaml"))
+	assert.False(t, generated)
+	assert.NoDirExists(t, "tls")
+}
+
+func TestLoadCertificateRequestsFromDir_WithReadDirError(t *testing.T) {
+	requests, errs := LoadCertificateRequestsFromDir("testdata/unknown")
+
+	assert.Empty(t, requests)
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrReadDir)
+}
+
 func TestHandleCertificateRequestFile_WithInvalidExtension(t *testing.T) {
 	out := loggerOutput()
 
@@ -29,14 +90,43 @@ func TestHandleCertificateRequestFile_WithInvalidExtension(t *testing.T) {
 	assert.Empty(t, out.String())
 }
 
+func TestLoadCertificateRequests_WithFilePattern(t *testing.T) {
+	mock(t, &config.CertificateRequestsPattern, "test2.*")
+	var loadedFiles []string
+	mock(t, &LoadCertificateRequest, func(file string) (CertificateRequest, error) {
+		loadedFiles = append(loadedFiles, file)
+		return CertificateRequest{}, errors.New("stop")
+	})
+	loggerOutput()
+
+	LoadCertificateRequests("testdata/requests")
+
+	assert.Equal(t, []string{"testdata/requests/test2.yaml"}, loadedFiles)
+}
+
+func TestLoadCertificateRequests_WithIgnore(t *testing.T) {
+	mock(t, &config.CertificateRequestsIgnore, []string{"test1.*"})
+	var loadedFiles []string
+	mock(t, &LoadCertificateRequest, func(file string) (CertificateRequest, error) {
+		loadedFiles = append(loadedFiles, file)
+		return CertificateRequest{}, errors.New("stop")
+	})
+	loggerOutput()
+
+	LoadCertificateRequests("testdata/requests")
+
+	assert.Equal(t, []string{"testdata/requests/test2.yaml"}, loadedFiles)
+}
+
 func TestHandleCertificateRequestFile_WithLoadCertificateRequestError(t *testing.T) {
 	out := loggerOutput()
 	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) {
 		return CertificateRequest{}, errors.New("LoadCertificateRequest error")
 	})
 
-	HandleCertificateRequestFile("valid.yaml")
+	ok := HandleCertificateRequestFile("valid.yaml")
 
+	assert.False(t, ok)
 	expectedLogs := []string{
 		`level=info msg="Handle certificate request valid.yaml"`,
 		`level=error msg="Failed to load certificate request: LoadCertificateRequest error"`,
@@ -44,6 +134,61 @@ func TestHandleCertificateRequestFile_WithLoadCertificateRequestError(t *testing
 	assert.Equal(t, expectedLogs, splitLogLines(out))
 }
 
+func TestHandleCertificateRequestFile_WithEnabled(t *testing.T) {
+	for name, tt := range map[string]struct {
+		enabled      any
+		expectedLogs []string
+	}{
+		"Disabled": {
+			enabled: false,
+			expectedLogs: []string{
+				`level=info msg="Handle certificate request valid.yaml"`,
+				`level=info msg="Certificate request valid.yaml disabled, skipping"`,
+			},
+		},
+		"Enabled": {
+			enabled:      true,
+			expectedLogs: []string{`level=info msg="Handle certificate request valid.yaml"`},
+		},
+		"Enabled by default": {
+			enabled:      nil,
+			expectedLogs: []string{`level=info msg="Handle certificate request valid.yaml"`},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+			values := map[string]any{"out": map[string]any{"dir": t.TempDir()}, "commonName": "test"}
+			if tc.enabled != nil {
+				values["enabled"] = tc.enabled
+			}
+			mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return BuildCertificateRequest(values) })
+			var generated bool
+			mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { generated = true })
+
+			HandleCertificateRequestFile("valid.yaml")
+
+			assert.Equal(t, tc.enabled != false, generated)
+			assert.Equal(t, tc.expectedLogs, splitLogLines(out))
+		})
+	}
+}
+
+func TestHandleInlineCertificateRequests_WithDisabledRequest(t *testing.T) {
+	out := loggerOutput()
+	var generated bool
+	mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { generated = true })
+
+	ok := HandleInlineCertificateRequests([]map[string]any{{"out": map[string]any{"dir": t.TempDir()}, "commonName": "test", "enabled": false}})
+
+	assert.True(t, ok)
+	assert.False(t, generated)
+	assert.Equal(t, []string{
+		`level=info msg="Handle inline certificate request #0"`,
+		`level=info msg="Inline certificate request #0 disabled, skipping"`,
+	}, splitLogLines(out))
+}
+
 func TestHandleCertificateRequestFile_WithLoadIssuerError(t *testing.T) {
 	out := loggerOutput()
 	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return CertificateRequest{}, nil })
@@ -58,6 +203,22 @@ func TestHandleCertificateRequestFile_WithLoadIssuerError(t *testing.T) {
 	assert.Equal(t, expectedLogs, splitLogLines(out))
 }
 
+func TestHandleCertificateRequestFile_WithLabels(t *testing.T) {
+	out := loggerOutput()
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) {
+		return CertificateRequest{Labels: map[string]string{"env": "test"}}, nil
+	})
+	mock(t, &LoadIssuer, func(_ IssuerPath) (*Issuer, error) { return nil, errors.New("LoadIssuer error") })
+
+	HandleCertificateRequestFile("valid.yaml")
+
+	expectedLogs := []string{
+		`level=info msg="Handle certificate request valid.yaml"`,
+		`level=error msg="Invalid issuer: LoadIssuer error" env=test`,
+	}
+	assert.Equal(t, expectedLogs, splitLogLines(out))
+}
+
 func TestHandleCertificateRequestFile_WithLoadCertFromFileError(t *testing.T) {
 	out := loggerOutput()
 	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return CertificateRequest{OutCertPath: "tls.crt"}, nil })
@@ -75,11 +236,388 @@ func TestHandleCertificateRequestFile_WithLoadCertFromFileError(t *testing.T) {
 	assert.Equal(t, expectedLogs, splitLogLines(out))
 }
 
+func TestHandleCertificateRequestFile_WithCorruptCert(t *testing.T) {
+	for name, tt := range map[string]struct {
+		onCorruptCert       string
+		expectedGenerated   bool
+		expectedCorruptFile bool
+		expectedLogs        []string
+	}{
+		"Regenerate by default": {
+			onCorruptCert:     "",
+			expectedGenerated: true,
+		},
+		"Regenerate": {
+			onCorruptCert:     OnCorruptRegenerate,
+			expectedGenerated: true,
+		},
+		"Quarantine": {
+			onCorruptCert:       OnCorruptQuarantine,
+			expectedGenerated:   true,
+			expectedCorruptFile: true,
+			expectedLogs:        []string{`level=warning msg="Quarantine corrupt certificate {{cert}} to {{cert}}.corrupt"`},
+		},
+		"Fail": {
+			onCorruptCert: OnCorruptFail,
+			expectedLogs:  []string{`level=error msg="Refuse to regenerate corrupt certificate {{cert}}"`},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+			certPath := filepath.Join(t.TempDir(), "tls.crt")
+			require.NoError(t, os.WriteFile(certPath, []byte("corrupt"), 0600))
+			mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) {
+				return CertificateRequest{OutCertPath: certPath, OnCorruptCert: tc.onCorruptCert}, nil
+			})
+			mock(t, &LoadIssuer, func(_ IssuerPath) (*Issuer, error) { return nil, nil })
+			var generated bool
+			mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { generated = true })
+
+			HandleCertificateRequestFile("valid.yaml")
+
+			assert.Equal(t, tc.expectedGenerated, generated)
+			if tc.expectedCorruptFile {
+				assert.NoFileExists(t, certPath)
+				assertFileContent(t, "corrupt", certPath+CorruptSuffix)
+			} else {
+				assertFileContent(t, "corrupt", certPath)
+				assert.NoFileExists(t, certPath+CorruptSuffix)
+			}
+			expectedLogs := []string{
+				`level=info msg="Handle certificate request valid.yaml"`,
+				`level=error msg="Invalid certificate ` + certPath + `: invalid PEM block"`,
+			}
+			for _, log := range tc.expectedLogs {
+				expectedLogs = append(expectedLogs, strings.ReplaceAll(log, "{{cert}}", certPath))
+			}
+			assert.Equal(t, expectedLogs, splitLogLines(out))
+		})
+	}
+}
+
+func TestHandleInlineCertificateRequests(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	requests := []map[string]any{
+		{"out": map[string]any{"dir": filepath.Join(dir, "first")}, "commonName": "first", "duration": "24h"},
+		{"out": map[string]any{"dir": filepath.Join(dir, "second")}, "commonName": "second", "duration": "24h"},
+	}
+
+	HandleInlineCertificateRequests(requests)
+
+	for _, name := range []string{"first", "second"} {
+		cert, err := LoadCertFromFile(filepath.Join(dir, name, "tls.crt"))
+		require.NoError(t, err)
+		assert.Equal(t, name, cert.Subject.CommonName)
+		assert.FileExists(t, filepath.Join(dir, name, "tls.key"))
+	}
+	assert.Contains(t, out.String(), `msg="Handle inline certificate request #0"`)
+	assert.Contains(t, out.String(), `msg="Handle inline certificate request #1"`)
+}
+
+func TestHandleInlineCertificateRequests_WithBuildCertificateRequestError(t *testing.T) {
+	out := loggerOutput()
+	var handled int
+	mock(t, &BuildCertificateRequest, func(_ map[string]any) (CertificateRequest, error) {
+		return CertificateRequest{}, errors.New("BuildCertificateRequest error")
+	})
+	mock(t, &LoadIssuer, func(_ IssuerPath) (*Issuer, error) {
+		handled++
+		return nil, nil
+	})
+
+	HandleInlineCertificateRequests([]map[string]any{{}})
+
+	expectedLogs := []string{
+		`level=info msg="Handle inline certificate request #0"`,
+		`level=error msg="Failed to load inline certificate request #0: BuildCertificateRequest error"`,
+	}
+	assert.Equal(t, expectedLogs, splitLogLines(out))
+	assert.Zero(t, handled)
+}
+
+func TestHandleCertificateRequestFile_WithVerifyChain(t *testing.T) {
+	dir := t.TempDir()
+	otherCA := CertificateRequest{OutKeyPath: filepath.Join(dir, "other.key"), OutCertPath: filepath.Join(dir, "other.crt"), CommonName: "other", IsCA: true, Duration: time.Hour}
+	otherCAKey, err := GeneratePrivateKey(otherCA)
+	require.NoError(t, err)
+	otherCACert, err := GenerateCertificate(otherCA, otherCAKey, nil)
+	require.NoError(t, err)
+
+	for name, tt := range map[string]struct {
+		signer             *Issuer
+		verifyChain        bool
+		systemRoots        bool
+		expectedRegenerate bool
+	}{
+		"Signed by current CA":                   {verifyChain: true, expectedRegenerate: false},
+		"Signed by current CA with system roots": {verifyChain: true, systemRoots: true, expectedRegenerate: false},
+		"Signed by another CA":                   {signer: &Issuer{PublicKey: otherCACert, PrivateKey: otherCAKey}, verifyChain: true, expectedRegenerate: true},
+		"Signed by another CA without verify":    {signer: &Issuer{PublicKey: otherCACert, PrivateKey: otherCAKey}, verifyChain: false, expectedRegenerate: false},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+			req := CertificateRequest{
+				OutKeyPath:        filepath.Join(t.TempDir(), "tls.key"),
+				OutCertPath:       filepath.Join(t.TempDir(), "tls.crt"),
+				CommonName:        "test",
+				Duration:          24 * time.Hour,
+				RenewBefore:       time.Hour,
+				IssuerPath:        IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"},
+				VerifyChain:       tc.verifyChain,
+				VerifySystemRoots: tc.systemRoots,
+			}
+			signer := tc.signer
+			if signer == nil {
+				signer, err = LoadIssuer(req.IssuerPath)
+				require.NoError(t, err)
+			}
+			key, err := GeneratePrivateKey(req)
+			require.NoError(t, err)
+			_, err = GenerateCertificate(req, key, signer)
+			require.NoError(t, err)
+			mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+			var regenerated bool
+			mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { regenerated = true })
+
+			HandleCertificateRequestFile("valid.yaml")
+
+			assert.Equal(t, tc.expectedRegenerate, regenerated)
+			if tc.expectedRegenerate {
+				assert.Contains(t, out.String(), `level=warning msg="Certificate `+req.OutCertPath+` does not chain to issuer: verify chain: x509: certificate signed by unknown authority`)
+			}
+		})
+	}
+}
+
+func TestHandleCertificateRequestFile_WithReadOnlyOutDir(t *testing.T) {
+	out := loggerOutput()
+	// A regular file as parent cannot be turned into a directory, even when running as root
+	parent := filepath.Join(t.TempDir(), "readonly")
+	require.NoError(t, os.WriteFile(parent, nil, 0400))
+	outDir := filepath.Join(parent, "tls")
+	mock(t, &FileDoesNotExists, func(_ string) bool { return true })
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) {
+		return CertificateRequest{OutCertPath: filepath.Join(outDir, "tls.crt")}, nil
+	})
+	mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { t.Error("unexpected generation") })
+
+	ok := HandleCertificateRequestFile("valid.yaml")
+
+	assert.False(t, ok)
+	logs := splitLogLines(out)
+	require.Len(t, logs, 2)
+	assert.Equal(t, `level=error msg="Cannot create output directory `+outDir+`: create directory: mkdir `+parent+`: not a directory"`, logs[1])
+}
+
+func TestHandleCertificateRequestFile_WithGenerationFailure(t *testing.T) {
+	out := loggerOutput()
+	outCertPath := filepath.Join(t.TempDir(), "tls.crt")
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) {
+		return CertificateRequest{OutCertPath: outCertPath}, nil
+	})
+	// The failure is reported without being logged as an error
+	mock(t, &GenerateOutFilesFromRequest, func(req CertificateRequest, _ *Issuer) { generationFailures.add(req.OutCertPath) })
+
+	ok := HandleCertificateRequestFile("valid.yaml")
+
+	assert.False(t, ok)
+	assert.NotContains(t, out.String(), "level=error")
+}
+
+func TestHandleCertificateRequestFile_WithRegenerateOnIssuerChange(t *testing.T) {
+	dir := t.TempDir()
+	otherCA := CertificateRequest{OutKeyPath: filepath.Join(dir, "other.key"), OutCertPath: filepath.Join(dir, "other.crt"), CommonName: "other", IsCA: true, Duration: time.Hour}
+	otherCAKey, err := GeneratePrivateKey(otherCA)
+	require.NoError(t, err)
+	otherCACert, err := GenerateCertificate(otherCA, otherCAKey, nil)
+	require.NoError(t, err)
+
+	for name, tt := range map[string]struct {
+		changedIssuer      bool
+		regenerate         bool
+		expectedRegenerate bool
+	}{
+		"Same issuer":                  {changedIssuer: false, regenerate: true, expectedRegenerate: false},
+		"Changed issuer with flag on":  {changedIssuer: true, regenerate: true, expectedRegenerate: true},
+		"Changed issuer with flag off": {changedIssuer: true, regenerate: false, expectedRegenerate: false},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+			req := CertificateRequest{
+				OutKeyPath:         filepath.Join(t.TempDir(), "tls.key"),
+				OutCertPath:        filepath.Join(t.TempDir(), "tls.crt"),
+				CommonName:         "test",
+				Duration:           24 * time.Hour,
+				RenewBefore:        time.Hour,
+				IssuerPath:         IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"},
+				RegenerateOnIssuer: tc.regenerate,
+			}
+			signer, err := LoadIssuer(req.IssuerPath)
+			require.NoError(t, err)
+			if tc.changedIssuer {
+				// The certificate was signed by the previous CA, before the issuer files were replaced
+				signer = &Issuer{PublicKey: otherCACert, PrivateKey: otherCAKey}
+			}
+			key, err := GeneratePrivateKey(req)
+			require.NoError(t, err)
+			_, err = GenerateCertificate(req, key, signer)
+			require.NoError(t, err)
+			mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+			var regenerated bool
+			mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { regenerated = true })
+
+			HandleCertificateRequestFile("valid.yaml")
+
+			assert.Equal(t, tc.expectedRegenerate, regenerated)
+			if tc.expectedRegenerate {
+				assert.Contains(t, out.String(), `level=info msg="Issuer changed for certificate `+req.OutCertPath+`"`)
+			}
+		})
+	}
+}
+
+func TestHandleCertificateRequestFile_WithRegenerateCertOnly(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutCAPath:   filepath.Join(dir, "ca.crt"),
+		CommonName:  "test",
+		Duration:    24 * time.Hour,
+		DNSNames:    []string{"localhost"},
+		PrivateKey:  PrivateKey{Algorithm: ED25519},
+		Regenerate:  RegenerateCertOnly,
+	}
+	GenerateOutFilesFromRequest(req, nil)
+	previousKey, err := os.ReadFile(req.OutKeyPath)
+	require.NoError(t, err)
+	req.DNSNames = []string{"localhost", "example.com"}
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+
+	HandleCertificateRequestFile("valid.yaml")
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	assert.Equal(t, req.DNSNames, cert.DNSNames)
+	key, err := os.ReadFile(req.OutKeyPath)
+	require.NoError(t, err)
+	assert.Equal(t, previousKey, key)
+	assert.Contains(t, out.String(), `level=info msg="Request changed for certificate `+req.OutCertPath+`: dnsNames: [localhost] -> [localhost example.com]"`)
+	assert.Contains(t, out.String(), `msg="Reuse key `+req.OutKeyPath+`"`)
+}
+
+func TestHandleCertificateRequestFile_WithFixedNotAfterInRenewalWindow(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutCAPath:   filepath.Join(dir, "ca.crt"),
+		CommonName:  "test",
+		NotAfter:    time.Now().Add(time.Hour),
+		RenewBefore: 2 * time.Hour,
+		PrivateKey:  PrivateKey{Algorithm: ED25519},
+	}
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+	generations := 0
+	generate := GenerateOutFilesFromRequest
+	mock(t, &GenerateOutFilesFromRequest, func(req CertificateRequest, issuer *Issuer) {
+		generations++
+		generate(req, issuer)
+	})
+
+	HandleCertificateRequestFile("valid.yaml")
+	ok := HandleCertificateRequestFile("valid.yaml")
+
+	assert.True(t, ok)
+	assert.Equal(t, 1, generations)
+	assert.Contains(t, out.String(), `level=warning msg="Certificate `+req.OutCertPath+` expires at `)
+	assert.Contains(t, out.String(), `not renewable with a fixed notAfter"`)
+}
+
+func TestHandleCertificateRequestFile_WithRegenerateCertOnlyAndKeyChange(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutCAPath:   filepath.Join(dir, "ca.crt"),
+		CommonName:  "test",
+		Duration:    24 * time.Hour,
+		PrivateKey:  PrivateKey{Algorithm: ED25519},
+		Regenerate:  RegenerateCertOnly,
+	}
+	GenerateOutFilesFromRequest(req, nil)
+	req.PrivateKey = PrivateKey{Algorithm: ECDSA}
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+
+	HandleCertificateRequestFile("valid.yaml")
+
+	key, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+	require.NoError(t, err)
+	assert.IsType(t, &ecdsa.PrivateKey{}, key)
+}
+
+func TestHandleCertificateRequestFile_WithOrphanKey(t *testing.T) {
+	for name, tt := range map[string]struct {
+		reuse        bool
+		expectedKept bool
+		expectedLog  string
+	}{
+		"Reuse": {
+			reuse:        true,
+			expectedKept: true,
+			expectedLog:  `level=info msg="Found orphan key {{key}} without certificate {{cert}}, reusing it"`,
+		},
+		"Overwrite": {
+			reuse:       false,
+			expectedLog: `level=warning msg="Found orphan key {{key}} without certificate {{cert}}, overwriting it"`,
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+			dir := t.TempDir()
+			req := CertificateRequest{
+				OutKeyPath:  filepath.Join(dir, "tls.key"),
+				OutCertPath: filepath.Join(dir, "tls.crt"),
+				OutCAPath:   filepath.Join(dir, "ca.crt"),
+				CommonName:  "test",
+				Duration:    24 * time.Hour,
+				PrivateKey:  PrivateKey{Algorithm: ED25519, Reuse: tc.reuse},
+			}
+			GenerateOutFilesFromRequest(req, nil)
+			orphanKey, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+			require.NoError(t, err)
+			require.NoError(t, os.Remove(req.OutCertPath))
+			mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+
+			HandleCertificateRequestFile("valid.yaml")
+
+			cert, err := LoadCertFromFile(req.OutCertPath)
+			require.NoError(t, err)
+			key, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedKept, samePublicKey(key, orphanKey))
+			assert.Equal(t, publicKey(key), cert.PublicKey)
+			expectedLog := strings.NewReplacer("{{key}}", req.OutKeyPath, "{{cert}}", req.OutCertPath).Replace(tc.expectedLog)
+			assert.Contains(t, out.String(), expectedLog)
+		})
+	}
+}
+
 func TestGenerateOutFilesFromRequest(t *testing.T) {
 	out := loggerOutput()
 	req := CertificateRequest{OutCAPath: "ca.crt", OutCertPath: "tls.crt", OutKeyPath: "tls.key"}
 	mock(t, &GeneratePrivateKey, func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil })
-	mock(t, &GenerateCertificate, func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) error { return nil })
+	mock(t, &GenerateCertificate, func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) (*x509.Certificate, error) {
+		return testCert(t), nil
+	})
 	mock(t, &CopyCA, func(_ *Issuer, _ string) error { return nil })
 
 	GenerateOutFilesFromRequest(req, &Issuer{PublicKey: &x509.Certificate{}})
@@ -87,34 +625,568 @@ func TestGenerateOutFilesFromRequest(t *testing.T) {
 	actualLogs := splitLogLines(out)
 	expectedLogs := []string{
 		`level=info msg="Generate key to tls.key"`,
-		`level=info msg="Generate certificate to tls.crt"`,
+		`level=info msg="Generate certificate to tls.crt" fingerprint="` + testCertFingerprint + `" self_signed=false serial="` + testCertSerial + `"`,
 		`level=info msg="Copy CA to ca.crt"`,
 	}
 	assert.Equal(t, expectedLogs, actualLogs)
 }
 
+func TestGenerateOutFilesFromRequest_WithKeepPrevious(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:       filepath.Join(dir, "ca.crt"),
+		OutCertPath:     filepath.Join(dir, "tls.crt"),
+		OutKeyPath:      filepath.Join(dir, "tls.key"),
+		OutKeepPrevious: true,
+		CommonName:      "test",
+		Duration:        time.Hour,
+		PrivateKey:      PrivateKey{Algorithm: ED25519},
+	}
+	GenerateOutFilesFromRequest(req, nil)
+	assert.True(t, FileDoesNotExists(req.OutCertPath+PreviousSuffix))
+	assert.True(t, FileDoesNotExists(req.OutKeyPath+PreviousSuffix))
+
+	for i := 0; i < 2; i++ {
+		formerCert, err := os.ReadFile(req.OutCertPath)
+		require.NoError(t, err)
+		formerKey, err := os.ReadFile(req.OutKeyPath)
+		require.NoError(t, err)
+
+		GenerateOutFilesFromRequest(req, nil)
+
+		previousCert, err := os.ReadFile(req.OutCertPath + PreviousSuffix)
+		require.NoError(t, err)
+		previousKey, err := os.ReadFile(req.OutKeyPath + PreviousSuffix)
+		require.NoError(t, err)
+		cert, err := os.ReadFile(req.OutCertPath)
+		require.NoError(t, err)
+		key, err := os.ReadFile(req.OutKeyPath)
+		require.NoError(t, err)
+		assert.Equal(t, formerCert, previousCert)
+		assert.Equal(t, formerKey, previousKey)
+		assert.NotEqual(t, formerCert, cert)
+		assert.NotEqual(t, formerKey, key)
+	}
+	assert.Contains(t, out.String(), `level=info msg="Keep previous `+req.OutCertPath+` to `+req.OutCertPath+`.prev"`)
+}
+
+func TestGenerateOutFilesFromRequest_WithDebugTimings(t *testing.T) {
+	out := loggerOutput()
+	logrus.SetLevel(logrus.DebugLevel)
+	t.Cleanup(func() { logrus.SetLevel(logrus.InfoLevel) })
+	req := CertificateRequest{OutCertPath: "tls.crt", OutKeyPath: "tls.key"}
+	mock(t, &GeneratePrivateKey, func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil })
+	mock(t, &GenerateCertificate, func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) (*x509.Certificate, error) {
+		time.Sleep(10 * time.Millisecond)
+		return testCert(t), nil
+	})
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	logs := splitLogLines(out)
+	require.Len(t, logs, 4)
+	assert.Regexp(t, `^level=debug msg="Step key generation done" duration="?[0-9.]+[µn]?s"? step="key generation"$`, logs[1])
+	assert.Regexp(t, `^level=debug msg="Step certificate generation done" duration=[0-9.]+ms step="certificate generation"$`, logs[2])
+}
+
+func TestGenerateOutFilesFromRequest_WithLegacySHA1Fingerprint(t *testing.T) {
+	out := loggerOutput()
+	mock(t, &config.LegacySHA1Fingerprint, true)
+	req := CertificateRequest{OutCertPath: "tls.crt", OutKeyPath: "tls.key"}
+	mock(t, &GeneratePrivateKey, func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil })
+	mock(t, &GenerateCertificate, func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) (*x509.Certificate, error) {
+		return testCert(t), nil
+	})
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	expectedLogs := []string{
+		`level=info msg="Generate key to tls.key"`,
+		`level=info msg="Generate certificate to tls.crt" fingerprint="` + testCertFingerprint + `" fingerprint_sha1_legacy="` + testCertSHA1 + `" self_signed=true serial="` + testCertSerial + `"`,
+	}
+	assert.Equal(t, expectedLogs, splitLogLines(out))
+}
+
 func TestGenerateOutFilesFromRequest_WithoutIssuer(t *testing.T) {
 	out := loggerOutput()
 	req := CertificateRequest{OutCAPath: "ca.crt", OutCertPath: "tls.crt", OutKeyPath: "tls.key"}
 	mock(t, &GeneratePrivateKey, func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil })
-	mock(t, &GenerateCertificate, func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) error { return nil })
+	mock(t, &GenerateCertificate, func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) (*x509.Certificate, error) {
+		return testCert(t), nil
+	})
 
 	GenerateOutFilesFromRequest(req, nil)
 
 	actualLogs := splitLogLines(out)
 	expectedLogs := []string{
 		`level=info msg="Generate key to tls.key"`,
-		`level=info msg="Generate certificate to tls.crt"`,
+		`level=info msg="Generate certificate to tls.crt" fingerprint="` + testCertFingerprint + `" self_signed=true serial="` + testCertSerial + `"`,
 	}
 	assert.Equal(t, expectedLogs, actualLogs)
 }
 
+func TestGenerateOutFilesFromRequest_WithPublicKey(t *testing.T) {
+	out := loggerOutput()
+	req := CertificateRequest{OutCertPath: "tls.crt", OutKeyPath: "tls.key", OutPublicKeyPath: "tls.pub"}
+	var publicKeyPath string
+	mock(t, &GeneratePrivateKey, func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil })
+	mock(t, &WritePublicKey, func(_ crypto.PrivateKey, path string) error {
+		publicKeyPath = path
+		return errors.New("WritePublicKey error")
+	})
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	expectedLogs := []string{
+		`level=info msg="Generate key to tls.key"`,
+		`level=info msg="Write public key to tls.pub"`,
+		`level=error msg="Failure: WritePublicKey error"`,
+	}
+	assert.Equal(t, expectedLogs, splitLogLines(out))
+	assert.Equal(t, "tls.pub", publicKeyPath)
+}
+
+func TestGenerateOutFilesFromRequest_WithRequireIssuer(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCertPath:   filepath.Join(dir, "tls.crt"),
+		OutKeyPath:    filepath.Join(dir, "tls.key"),
+		Duration:      time.Hour,
+		RequireIssuer: true,
+	}
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	assert.Equal(t, []string{`level=error msg="Failure: issuer required, refuse to self-sign: ` + req.OutCertPath + `"`}, splitLogLines(out))
+	assert.NoFileExists(t, req.OutCertPath)
+	assert.NoFileExists(t, req.OutKeyPath)
+}
+
+func TestGenerateOutFilesFromRequest_WithKeyDER(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCertPath:   filepath.Join(dir, "tls.crt"),
+		OutKeyPath:    filepath.Join(dir, "tls.key"),
+		OutKeyDERPath: filepath.Join(dir, "tls.der"),
+		Duration:      time.Hour,
+		PrivateKey:    PrivateKey{Algorithm: ECDSA},
+	}
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	der, err := os.ReadFile(req.OutKeyDERPath)
+	require.NoError(t, err)
+	derKey, err := x509.ParsePKCS8PrivateKey(der)
+	require.NoError(t, err)
+	pemKey, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+	require.NoError(t, err)
+	assert.Equal(t, pemKey, derKey)
+}
+
+func TestWritePrivateKeyDER_WithError(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	err = WritePrivateKeyDER(key, filepath.Join(t.TempDir(), "missing", "tls.der"))
+
+	assert.ErrorIs(t, err, ErrWritePrivateKeyDER)
+	assert.ErrorIs(t, err, ErrCreateFile)
+}
+
+func TestGenerateOutFilesFromRequest_WithLabels(t *testing.T) {
+	out := loggerOutput()
+	req := CertificateRequest{OutCAPath: "ca.crt", OutCertPath: "tls.crt", OutKeyPath: "tls.key", Labels: map[string]string{"team": "security"}}
+	mock(t, &GeneratePrivateKey, func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil })
+	mock(t, &GenerateCertificate, func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) (*x509.Certificate, error) {
+		return testCert(t), nil
+	})
+	mock(t, &CopyCA, func(_ *Issuer, _ string) error { return errors.New("CopyCA error") })
+
+	GenerateOutFilesFromRequest(req, &Issuer{PublicKey: &x509.Certificate{}})
+
+	expectedLogs := []string{
+		`level=info msg="Generate key to tls.key" team=security`,
+		`level=info msg="Generate certificate to tls.crt" fingerprint="` + testCertFingerprint + `" self_signed=false serial="` + testCertSerial + `" team=security`,
+		`level=info msg="Copy CA to ca.crt" team=security`,
+		`level=error msg="Failure: CopyCA error" team=security`,
+	}
+	assert.Equal(t, expectedLogs, splitLogLines(out))
+}
+
+func TestGenerateOutFilesFromRequest_WithCTLogsError(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:   filepath.Join(dir, "ca.crt"),
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		PrivateKey:  PrivateKey{Algorithm: ECDSA},
+		Duration:    time.Hour,
+		CTLogs:      []string{"https://ct.example.com"},
+		CTRequired:  true,
+	}
+	mock(t, &SubmitToCTLogs, func(_ CertificateRequest, _ *x509.Certificate, _ *Issuer) ([]SignedCertificateTimestamp, error) {
+		return nil, errors.New("SubmitToCTLogs error")
+	})
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	expectedLogs := []string{
+		`level=error msg="Failure: SubmitToCTLogs error"`,
+	}
+	assert.Equal(t, expectedLogs, splitLogLines(out))
+	assert.NoFileExists(t, req.OutKeyPath)
+	assert.NoFileExists(t, req.OutCertPath)
+}
+
+func TestGenerateOutFilesFromRequest_WithCTLogsErrorOnRenewal(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:   filepath.Join(dir, "ca.crt"),
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		PrivateKey:  PrivateKey{Algorithm: ECDSA},
+		Duration:    time.Hour,
+	}
+	GenerateOutFilesFromRequest(req, nil)
+	previousKey, err := os.ReadFile(req.OutKeyPath)
+	require.NoError(t, err)
+	previousCert, err := os.ReadFile(req.OutCertPath)
+	require.NoError(t, err)
+	req.CTLogs, req.CTRequired = []string{"https://ct.example.com"}, true
+	mock(t, &SubmitToCTLogs, func(_ CertificateRequest, _ *x509.Certificate, _ *Issuer) ([]SignedCertificateTimestamp, error) {
+		return nil, errors.New("SubmitToCTLogs error")
+	})
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	assertFileContent(t, string(previousKey), req.OutKeyPath)
+	assertFileContent(t, string(previousCert), req.OutCertPath)
+}
+
+func TestGenerateOutFilesFromRequest_WithCTLogs(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:   filepath.Join(dir, "ca.crt"),
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		PrivateKey:  PrivateKey{Algorithm: ECDSA},
+		Duration:    time.Hour,
+		CTLogs:      []string{"https://ct.example.com"},
+	}
+	var submitted *x509.Certificate
+	mock(t, &SubmitToCTLogs, func(_ CertificateRequest, cert *x509.Certificate, _ *Issuer) ([]SignedCertificateTimestamp, error) {
+		assert.NoFileExists(t, req.OutKeyPath)
+		assert.NoFileExists(t, req.OutCertPath)
+		submitted = cert
+		return nil, nil
+	})
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	require.NotNil(t, submitted)
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	assert.Equal(t, submitted.Raw, cert.Raw)
+	key, err := LoadPrivateKeyFromFile(req.OutKeyPath)
+	require.NoError(t, err)
+	assert.Equal(t, cert.PublicKey, key.(crypto.Signer).Public())
+	assert.Contains(t, out.String(), `level=info msg="Generate key to `+req.OutKeyPath+`"`)
+}
+
+func TestGenerateOutFilesFromRequest_WithStaging(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{OutCAPath: filepath.Join(dir, "ca.crt"), OutCertPath: filepath.Join(dir, "tls.crt"), OutKeyPath: filepath.Join(dir, "tls.key"), OutStaging: true, Duration: time.Hour}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	assert.NoError(t, cert.CheckSignatureFrom(issuer.PublicKey))
+	assert.FileExists(t, req.OutKeyPath)
+	assert.FileExists(t, req.OutCAPath)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestGenerateOutFilesFromRequest_WithPrivateKeys(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		DNSNames:    []string{"localhost"},
+		Duration:    time.Hour,
+		PrivateKeys: []PrivateKey{{Algorithm: RSA, Size: 2048}, {Algorithm: ECDSA}},
+	}
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	rsaCert, err := LoadCertFromFile(filepath.Join(dir, "tls.rsa.crt"))
+	require.NoError(t, err)
+	ecdsaCert, err := LoadCertFromFile(filepath.Join(dir, "tls.ecdsa.crt"))
+	require.NoError(t, err)
+	assert.Equal(t, x509.RSA, rsaCert.PublicKeyAlgorithm)
+	assert.Equal(t, x509.ECDSA, ecdsaCert.PublicKeyAlgorithm)
+	assert.Equal(t, []string{"localhost"}, rsaCert.DNSNames)
+	assert.Equal(t, []string{"localhost"}, ecdsaCert.DNSNames)
+	assert.FileExists(t, filepath.Join(dir, "tls.rsa.key"))
+	assert.FileExists(t, filepath.Join(dir, "tls.ecdsa.key"))
+	assert.NoFileExists(t, req.OutCertPath)
+}
+
+func TestHandleCertificateRequestFile_WithPrivateKeys(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCertPath: filepath.Join(dir, "tls.crt"),
+		OutKeyPath:  filepath.Join(dir, "tls.key"),
+		Duration:    time.Hour,
+		PrivateKeys: []PrivateKey{{Algorithm: RSA, Size: 2048}, {Algorithm: ECDSA}},
+	}
+	mock(t, &LoadCertificateRequest, func(_ string) (CertificateRequest, error) { return req, nil })
+	var generated []string
+	mock(t, &GenerateOutFilesFromRequest, func(variant CertificateRequest, _ *Issuer) { generated = append(generated, variant.OutCertPath) })
+
+	HandleCertificateRequestFile("valid.yaml")
+
+	assert.Equal(t, []string{filepath.Join(dir, "tls.rsa.crt"), filepath.Join(dir, "tls.ecdsa.crt")}, generated)
+}
+
+func TestGenerateOutFilesFromRequest_WithInMemoryKey(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{OutCertPath: filepath.Join(dir, "tls.crt"), OutKeyInMemory: true, Duration: time.Hour}
+	var delivered crypto.PrivateKey
+	mock(t, &PrivateKeyHook, func(_ CertificateRequest, key crypto.PrivateKey, _ []byte) error {
+		delivered = key
+		return nil
+	})
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	cert, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	require.NotNil(t, delivered)
+	assert.True(t, cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(publicKey(delivered)))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, `level=info msg="Generate key in memory"`, splitLogLines(out)[0])
+}
+
+func TestGenerateOutFilesFromRequest_WithChainAndFullChain(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:        filepath.Join(dir, "ca.crt"),
+		OutCertPath:      filepath.Join(dir, "cert.pem"),
+		OutKeyPath:       filepath.Join(dir, "privkey.pem"),
+		OutChainPath:     filepath.Join(dir, "chain.pem"),
+		OutFullChainPath: filepath.Join(dir, "fullchain.pem"),
+		Duration:         time.Hour,
+	}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	leaf, err := os.ReadFile(req.OutCertPath)
+	require.NoError(t, err)
+	ca, err := os.ReadFile("testdata/ca.crt")
+	require.NoError(t, err)
+	assertFileContent(t, string(ca), req.OutChainPath)
+	assertFileContent(t, string(leaf)+string(ca), req.OutFullChainPath)
+	logs := splitLogLines(out)
+	assert.Equal(t, []string{
+		`level=info msg="Write chain to ` + req.OutChainPath + `"`,
+		`level=info msg="Write fullchain to ` + req.OutFullChainPath + `"`,
+	}, logs[len(logs)-2:])
+}
+
+func TestGenerateOutFilesFromRequest_WithCertIncludeChain(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCAPath:           filepath.Join(dir, "ca.crt"),
+		OutCertPath:         filepath.Join(dir, "tls.crt"),
+		OutCertIncludeChain: true,
+		OutKeyPath:          filepath.Join(dir, "tls.key"),
+		Duration:            time.Hour,
+	}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	b, err := os.ReadFile(req.OutCertPath)
+	require.NoError(t, err)
+	leafBlock, rest := pem.Decode(b)
+	require.NotNil(t, leafBlock)
+	issuerBlock, rest := pem.Decode(rest)
+	require.NotNil(t, issuerBlock)
+	assert.Empty(t, rest)
+	assert.Equal(t, issuer.PublicKey.Raw, issuerBlock.Bytes)
+	leaf, err := LoadCertFromFile(req.OutCertPath)
+	require.NoError(t, err)
+	assert.Equal(t, leaf.Raw, leafBlock.Bytes)
+	assert.True(t, IssuedBy(leaf, issuer))
+}
+
+func TestGenerateOutFilesFromRequest_WithFullChainWithoutIssuer(t *testing.T) {
+	loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{
+		OutCertPath:      filepath.Join(dir, "cert.pem"),
+		OutKeyPath:       filepath.Join(dir, "privkey.pem"),
+		OutChainPath:     filepath.Join(dir, "chain.pem"),
+		OutFullChainPath: filepath.Join(dir, "fullchain.pem"),
+		Duration:         time.Hour,
+	}
+
+	GenerateOutFilesFromRequest(req, nil)
+
+	leaf, err := os.ReadFile(req.OutCertPath)
+	require.NoError(t, err)
+	assertFileContent(t, string(leaf), req.OutFullChainPath)
+	assert.NoFileExists(t, req.OutChainPath)
+}
+
+func TestGenerateOutFilesFromRequest_WithBundle(t *testing.T) {
+	for name, tt := range map[string]struct {
+		algorithm     string
+		keyFormat     string
+		withoutKey    bool
+		expectedTypes []string
+	}{
+		"RSA":          {algorithm: RSA, expectedTypes: []string{"RSA PRIVATE KEY", "CERTIFICATE", "CERTIFICATE"}},
+		"RSA PKCS#8":   {algorithm: RSA, keyFormat: PrivateKeyFormatPKCS8, expectedTypes: []string{"PRIVATE KEY", "CERTIFICATE", "CERTIFICATE"}},
+		"ECDSA":        {algorithm: ECDSA, expectedTypes: []string{"EC PRIVATE KEY", "CERTIFICATE", "CERTIFICATE"}},
+		"ECDSA PKCS#8": {algorithm: ECDSA, keyFormat: PrivateKeyFormatPKCS8, expectedTypes: []string{"PRIVATE KEY", "CERTIFICATE", "CERTIFICATE"}},
+		"ED25519":      {algorithm: ED25519, expectedTypes: []string{"PRIVATE KEY", "CERTIFICATE", "CERTIFICATE"}},
+		"Without key":  {algorithm: ECDSA, withoutKey: true, expectedTypes: []string{"CERTIFICATE", "CERTIFICATE"}},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+			dir := t.TempDir()
+			req := CertificateRequest{
+				OutCAPath:           filepath.Join(dir, "ca.crt"),
+				OutCertPath:         filepath.Join(dir, "tls.crt"),
+				OutKeyPath:          filepath.Join(dir, "tls.key"),
+				OutBundlePath:       filepath.Join(dir, "bundle.pem"),
+				OutBundleWithoutKey: tc.withoutKey,
+				PrivateKey:          PrivateKey{Algorithm: tc.algorithm, Format: tc.keyFormat},
+				Duration:            time.Hour,
+			}
+			issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+			require.NoError(t, err)
+
+			GenerateOutFilesFromRequest(req, issuer)
+
+			bundle, err := os.ReadFile(req.OutBundlePath)
+			require.NoError(t, err)
+			var blocks []*pem.Block
+			for block, rest := pem.Decode(bundle); block != nil; block, rest = pem.Decode(rest) {
+				blocks = append(blocks, block)
+			}
+			var types []string
+			for _, block := range blocks {
+				types = append(types, block.Type)
+			}
+			require.Equal(t, tc.expectedTypes, types)
+			certs := blocks[len(blocks)-2:]
+			leaf, err := LoadCertFromFile(req.OutCertPath)
+			require.NoError(t, err)
+			assert.Equal(t, leaf.Raw, certs[0].Bytes)
+			assert.Equal(t, issuer.PublicKey.Raw, certs[1].Bytes)
+			if !tc.withoutKey {
+				keyPEM, err := os.ReadFile(req.OutKeyPath)
+				require.NoError(t, err)
+				assert.Equal(t, string(keyPEM), string(pem.EncodeToMemory(blocks[0])))
+			}
+			logs := splitLogLines(out)
+			assert.Equal(t, `level=info msg="Write bundle to `+req.OutBundlePath+`"`, logs[len(logs)-1])
+		})
+	}
+}
+
+func TestGenerateOutFilesFromRequest_WithStagingAndCopyCAError(t *testing.T) {
+	out := loggerOutput()
+	dir := t.TempDir()
+	req := CertificateRequest{OutCAPath: filepath.Join(dir, "ca.crt"), OutCertPath: filepath.Join(dir, "tls.crt"), OutKeyPath: filepath.Join(dir, "tls.key"), OutStaging: true, Duration: time.Hour}
+	for _, path := range []string{req.OutKeyPath, req.OutCertPath, req.OutCAPath} {
+		require.NoError(t, os.WriteFile(path, []byte("previous"), 0600))
+	}
+	issuer, err := LoadIssuer(IssuerPath{PublicKey: "testdata/ca.crt", PrivateKey: "testdata/ca.key"})
+	require.NoError(t, err)
+	mock(t, &CopyCA, func(_ *Issuer, _ string) error { return errors.New("CopyCA error") })
+
+	GenerateOutFilesFromRequest(req, issuer)
+
+	for _, path := range []string{req.OutKeyPath, req.OutCertPath, req.OutCAPath} {
+		assertFileContent(t, "previous", path)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+	logs := splitLogLines(out)
+	assert.Equal(t, `level=error msg="Failure: CopyCA error"`, logs[len(logs)-1])
+}
+
+func TestGenerateOutFilesFromRequest_WithRenewal(t *testing.T) {
+	for name, tt := range map[string]struct {
+		reuse         bool
+		staging       bool
+		expectedEvent string
+	}{
+		"Key rotated":              {expectedEvent: `event="key rotated"`},
+		"Key reused":               {reuse: true, expectedEvent: `event="cert renewed"`},
+		"Key reused with staging":  {reuse: true, staging: true, expectedEvent: `event="cert renewed"`},
+		"Key rotated with staging": {staging: true, expectedEvent: `event="key rotated"`},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			req := CertificateRequest{
+				OutCertPath: filepath.Join(dir, "tls.crt"),
+				OutKeyPath:  filepath.Join(dir, "tls.key"),
+				OutStaging:  tc.staging,
+				Duration:    time.Hour,
+				PrivateKey:  PrivateKey{Algorithm: ECDSA, Reuse: tc.reuse},
+			}
+			loggerOutput()
+			GenerateOutFilesFromRequest(req, nil)
+			previousKey, err := os.ReadFile(req.OutKeyPath)
+			require.NoError(t, err)
+			out := loggerOutput()
+
+			GenerateOutFilesFromRequest(req, nil)
+
+			logs := splitLogLines(out)
+			assert.Contains(t, logs[len(logs)-1], tc.expectedEvent)
+			currentKey, err := os.ReadFile(req.OutKeyPath)
+			require.NoError(t, err)
+			assert.Equal(t, tc.reuse, bytes.Equal(previousKey, currentKey))
+		})
+	}
+}
+
 func TestGenerateOutFilesFromRequest_WithError(t *testing.T) {
 	req := CertificateRequest{OutCAPath: "ca.crt", OutCertPath: "tls.crt", OutKeyPath: "tls.key"}
 
 	for name, tt := range map[string]struct {
 		generatePrivateKey  func(_ CertificateRequest) (crypto.PrivateKey, error)
-		generateCertificate func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) error
+		generateCertificate func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) (*x509.Certificate, error)
 		copyCA              func(_ *Issuer, _ string) error
 		expectedLogs        []string
 	}{
@@ -129,22 +1201,23 @@ func TestGenerateOutFilesFromRequest_WithError(t *testing.T) {
 		},
 		"GenerateCertificate error": {
 			generatePrivateKey: func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil },
-			generateCertificate: func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) error {
-				return errors.New("GenerateCertificate error")
+			generateCertificate: func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) (*x509.Certificate, error) {
+				return nil, errors.New("GenerateCertificate error")
 			},
 			expectedLogs: []string{
 				`level=info msg="Generate key to tls.key"`,
-				`level=info msg="Generate certificate to tls.crt"`,
 				`level=error msg="Failure: GenerateCertificate error"`,
 			},
 		},
 		"CopyCA error": {
-			generatePrivateKey:  func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil },
-			generateCertificate: func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) error { return nil },
-			copyCA:              func(_ *Issuer, _ string) error { return errors.New("CopyCA error") },
+			generatePrivateKey: func(_ CertificateRequest) (crypto.PrivateKey, error) { return nil, nil },
+			generateCertificate: func(_ CertificateRequest, _ crypto.PrivateKey, _ *Issuer) (*x509.Certificate, error) {
+				return testCert(t), nil
+			},
+			copyCA: func(_ *Issuer, _ string) error { return errors.New("CopyCA error") },
 			expectedLogs: []string{
 				`level=info msg="Generate key to tls.key"`,
-				`level=info msg="Generate certificate to tls.crt"`,
+				`level=info msg="Generate certificate to tls.crt" fingerprint="` + testCertFingerprint + `" self_signed=false serial="` + testCertSerial + `"`,
 				`level=info msg="Copy CA to ca.crt"`,
 				`level=error msg="Failure: CopyCA error"`,
 			},
@@ -164,6 +1237,18 @@ func TestGenerateOutFilesFromRequest_WithError(t *testing.T) {
 	}
 }
 
+const (
+	testCertFingerprint = "3A:07:D3:EA:A5:A0:5B:3B:FF:CB:79:3F:D6:0D:1E:D4:3D:62:9A:E7:11:DE:19:80:67:2A:59:5F:9B:77:FE:7B"
+	testCertSerial      = "E8:A4:75:B4:D3:64:91:D8:67:D3:0A:2E:20:7F:E1:01"
+	testCertSHA1        = "ED:1D:70:E4:F0:47:F1:72:5A:46:4E:E9:89:C9:95:DA:6F:B1:A6:09"
+)
+
+func testCert(t *testing.T) *x509.Certificate {
+	cert, err := LoadCertFromFile("testdata/test.crt")
+	require.NoError(t, err)
+	return cert
+}
+
 func loggerOutput() *bytes.Buffer {
 	var out bytes.Buffer
 	logrus.SetOutput(&out)
@@ -0,0 +1,60 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/goten4/ucerts/internal/format"
+)
+
+var (
+	ErrReadTrustedCertificate = errors.New("read trusted certificate")
+	ErrWriteTrustBundle       = errors.New("write trust bundle")
+)
+
+// LoadTrustedCertificates reads all the certificates of the given PEM files, skipping the duplicates.
+func LoadTrustedCertificates(files []string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf(format.WrapErrors, ErrReadTrustedCertificate, err)
+		}
+		found := false
+		for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			found = true
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %w", ErrReadTrustedCertificate, file, err)
+			}
+			if slices.ContainsFunc(certs, func(c *x509.Certificate) bool { return bytes.Equal(c.Raw, cert.Raw) }) {
+				continue
+			}
+			certs = append(certs, cert)
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s: %w", ErrReadTrustedCertificate, file, ErrInvalidPEMBlock)
+		}
+	}
+	return certs, nil
+}
+
+// WriteTrustBundle writes the given certificates to a single PEM file.
+var WriteTrustBundle = func(certs []*x509.Certificate, path string) error {
+	blocks := make([]*pem.Block, 0, len(certs))
+	for _, cert := range certs {
+		blocks = append(blocks, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	if err := WritePemBlocksToFile(blocks, path); err != nil {
+		return fmt.Errorf(format.WrapErrors, ErrWriteTrustBundle, err)
+	}
+	return nil
+}
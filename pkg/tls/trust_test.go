@@ -0,0 +1,82 @@
+package tls
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTrustedCertificates(t *testing.T) {
+	ca, err := LoadCertFromFile("testdata/ca.crt")
+	require.NoError(t, err)
+	otherCA := generateTrustedCA(t)
+
+	certs, err := LoadTrustedCertificates([]string{"testdata/ca.crt", otherCA, "testdata/test-ca.crt"})
+
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+	assert.Equal(t, ca.Raw, certs[0].Raw)
+	assert.Equal(t, "other CA", certs[1].Subject.CommonName)
+}
+
+func TestLoadTrustedCertificates_WithError(t *testing.T) {
+	for name, file := range map[string]string{
+		"Missing file":        "testdata/unknown.crt",
+		"Not a PEM file":      "testdata/invalid.crt",
+		"Invalid certificate": "testdata/truncated.crt",
+	} {
+		tc := file // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadTrustedCertificates([]string{"testdata/ca.crt", tc})
+
+			assert.ErrorIs(t, err, ErrReadTrustedCertificate)
+		})
+	}
+}
+
+func TestWriteTrustBundle(t *testing.T) {
+	certs, err := LoadTrustedCertificates([]string{"testdata/ca.crt", generateTrustedCA(t)})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "trust.pem")
+
+	err = WriteTrustBundle(certs, path)
+
+	require.NoError(t, err)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var blocks []*pem.Block
+	for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+		blocks = append(blocks, block)
+	}
+	require.Len(t, blocks, 2)
+	assert.Equal(t, certs[0].Raw, blocks[0].Bytes)
+	assert.Equal(t, certs[1].Raw, blocks[1].Bytes)
+}
+
+func TestWriteTrustBundle_WithError(t *testing.T) {
+	err := WriteTrustBundle(nil, filepath.Join(t.TempDir(), "missing", "trust.pem"))
+
+	assert.ErrorIs(t, err, ErrWriteTrustBundle)
+}
+
+func generateTrustedCA(t *testing.T) string {
+	dir := t.TempDir()
+	req := CertificateRequest{
+		CommonName:  "other CA",
+		IsCA:        true,
+		OutCertPath: filepath.Join(dir, "ca.crt"),
+		OutKeyPath:  filepath.Join(dir, "ca.key"),
+		Duration:    time.Hour,
+		PrivateKey:  PrivateKey{Algorithm: ED25519},
+	}
+	key, err := GeneratePrivateKey(req)
+	require.NoError(t, err)
+	_, err = GenerateCertificate(req, key, nil)
+	require.NoError(t, err)
+	return req.OutCertPath
+}
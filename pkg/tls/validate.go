@@ -0,0 +1,57 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+var ErrInvalidCertificateRequests = errors.New("invalid certificate requests")
+
+// ValidateOnStart loads all the certificate requests without generating anything, logging each invalid one. It only
+// fails when validateOnStart is fatal, so that the daemon refuses to start.
+var ValidateOnStart = func() error {
+	if config.ValidateOnStart == "" {
+		return nil
+	}
+	count := validateAllCertificateRequests()
+	if count == 0 {
+		return nil
+	}
+	if config.ValidateOnStart == config.ValidateOnStartFatal {
+		return fmt.Errorf("%w: %d invalid request(s)", ErrInvalidCertificateRequests, count)
+	}
+	logrus.Warnf("%d invalid certificate request(s) found at startup", count)
+	return nil
+}
+
+func validateAllCertificateRequests() int {
+	count := 0
+	for _, dir := range config.CertificateRequestsPaths {
+		files, err := ReadDir(dir)
+		if err != nil {
+			logrus.Errorf("Failed to read directory %s: %v", dir, err)
+			count++
+			continue
+		}
+		for _, file := range files {
+			if !config.IsCertificateRequestFile(file) {
+				continue
+			}
+			if _, err := LoadCertificateRequest(file); err != nil {
+				logrus.Errorf("Invalid certificate request %s: %v", file, err)
+				count++
+			}
+		}
+	}
+	for i, values := range config.CertificateRequestsInline {
+		if _, err := BuildCertificateRequest(values); err != nil {
+			logrus.Errorf("Invalid inline certificate request #%d: %v", i, err)
+			count++
+		}
+	}
+	return count
+}
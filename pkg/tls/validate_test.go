@@ -0,0 +1,71 @@
+package tls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+func TestValidateOnStart(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "valid.yaml"), []byte("out:\n  dir: tls\ncommonName: test\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "malformed.yaml"), []byte("commonName: test\n"), 0600))
+	mock(t, &config.CertificateRequestsPaths, []string{dir})
+	mock(t, &config.CertificateRequestsInline, nil)
+	invalidLog := `level=error msg="Invalid certificate request ` + filepath.Join(dir, "malformed.yaml") + `: missing mandatory field: out.dir"`
+
+	for name, tt := range map[string]struct {
+		mode         string
+		expectedErr  error
+		expectedLogs []string
+	}{
+		"Disabled": {
+			mode:         "",
+			expectedLogs: []string{""},
+		},
+		"Fatal": {
+			mode:         config.ValidateOnStartFatal,
+			expectedErr:  ErrInvalidCertificateRequests,
+			expectedLogs: []string{invalidLog},
+		},
+		"Warn only": {
+			mode:         config.ValidateOnStartWarn,
+			expectedLogs: []string{invalidLog, `level=warning msg="1 invalid certificate request(s) found at startup"`},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+			mock(t, &config.ValidateOnStart, tc.mode)
+			var generated bool
+			mock(t, &GenerateOutFilesFromRequest, func(_ CertificateRequest, _ *Issuer) { generated = true })
+
+			err := ValidateOnStart()
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedLogs, splitLogLines(out))
+			assert.False(t, generated)
+		})
+	}
+}
+
+func TestValidateOnStart_WithInvalidInlineRequest(t *testing.T) {
+	out := loggerOutput()
+	mock(t, &config.ValidateOnStart, config.ValidateOnStartFatal)
+	mock(t, &config.CertificateRequestsPaths, nil)
+	mock(t, &config.CertificateRequestsInline, []map[string]any{{"commonName": "test"}})
+
+	err := ValidateOnStart()
+
+	assert.ErrorIs(t, err, ErrInvalidCertificateRequests)
+	assert.Equal(t, []string{`level=error msg="Invalid inline certificate request #0: missing mandatory field: out.dir"`}, splitLogLines(out))
+}
@@ -0,0 +1,79 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+var (
+	createTempFile = os.CreateTemp
+
+	ErrUnwritableOutputDirectories = errors.New("unwritable output directories")
+)
+
+// CheckWritableOnStart creates and removes a temporary file in the output directory of every certificate request, so
+// that a permission problem is reported at startup rather than by the first failing generation.
+var CheckWritableOnStart = func() error {
+	if !config.CheckWritableOnStart {
+		return nil
+	}
+	count := 0
+	for _, dir := range outputDirectories() {
+		if err := checkWritable(dir); err != nil {
+			logrus.Errorf("Output directory %s is not writable: %v", dir, err)
+			count++
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d directory(ies)", ErrUnwritableOutputDirectories, count)
+	}
+	return nil
+}
+
+// outputDirectories returns the sorted output directories of the valid certificate requests, the invalid ones being
+// reported when handled.
+func outputDirectories() []string {
+	var dirs []string
+	add := func(req CertificateRequest) {
+		for _, variant := range KeyVariants(req) {
+			if dir := filepath.Dir(variant.OutCertPath); !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	for _, dir := range config.CertificateRequestsPaths {
+		requests, _ := LoadCertificateRequestsFromDir(dir)
+		for _, req := range requests {
+			if !req.Disabled {
+				add(req)
+			}
+		}
+	}
+	for _, values := range config.CertificateRequestsInline {
+		if req, err := BuildCertificateRequest(values); err == nil && !req.Disabled {
+			add(req)
+		}
+	}
+	slices.Sort(dirs)
+	return dirs
+}
+
+// checkWritable probes the nearest existing ancestor of a directory not created yet, as it is created on generation.
+func checkWritable(dir string) error {
+	for FileDoesNotExists(dir) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+	}
+	file, err := createTempFile(dir, ".ucerts-write-check-")
+	if err != nil {
+		return err
+	}
+	_ = file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,84 @@
+package tls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goten4/ucerts/internal/config"
+)
+
+func TestCheckWritableOnStart(t *testing.T) {
+	readOnly := filepath.Join(t.TempDir(), "readonly")
+	require.NoError(t, os.Mkdir(readOnly, 0o500))
+	writable := t.TempDir()
+	notCreated := filepath.Join(t.TempDir(), "not", "created")
+	// Permissions are not enforced for root, so the probe fails like it would for any other user
+	mock(t, &createTempFile, func(dir, pattern string) (*os.File, error) {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().Perm()&0o200 == 0 {
+			return nil, &os.PathError{Op: "open", Path: filepath.Join(dir, pattern), Err: os.ErrPermission}
+		}
+		return os.CreateTemp(dir, pattern)
+	})
+	mock(t, &config.CertificateRequestsPaths, nil)
+	inline := func(dirs ...string) []map[string]any {
+		var requests []map[string]any
+		for _, dir := range dirs {
+			requests = append(requests, map[string]any{"out": map[string]any{"dir": dir}, "commonName": "test"})
+		}
+		return requests
+	}
+
+	for name, tt := range map[string]struct {
+		enabled      bool
+		requests     []map[string]any
+		expectedErr  error
+		expectedLogs []string
+	}{
+		"Disabled": {
+			enabled:      false,
+			requests:     inline(readOnly),
+			expectedLogs: []string{""},
+		},
+		"Writable directories": {
+			enabled:      true,
+			requests:     inline(writable, notCreated),
+			expectedLogs: []string{""},
+		},
+		"Read-only directory": {
+			enabled:     true,
+			requests:    inline(writable, readOnly),
+			expectedErr: ErrUnwritableOutputDirectories,
+			expectedLogs: []string{
+				`level=error msg="Output directory ` + readOnly + ` is not writable: open ` + filepath.Join(readOnly, ".ucerts-write-check-") + `: permission denied"`,
+			},
+		},
+	} {
+		tc := tt // Use local variable to avoid closure-caused race condition
+		t.Run(name, func(t *testing.T) {
+			out := loggerOutput()
+			mock(t, &config.CheckWritableOnStart, tc.enabled)
+			mock(t, &config.CertificateRequestsInline, tc.requests)
+
+			err := CheckWritableOnStart()
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedLogs, splitLogLines(out))
+			entries, err := os.ReadDir(writable)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+			assert.NoDirExists(t, notCreated)
+		})
+	}
+}